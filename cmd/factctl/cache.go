@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WhyIsSandwich/factctl/internal/cache"
+	"github.com/WhyIsSandwich/factctl/internal/instance"
+)
+
+const cacheUsage = "Usage: factctl cache <list|stats|verify|prune|clear> [options]"
+
+// handleCache inspects and cleans up the download cache
+func handleCache(modManager *instance.ModManager, baseDir string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("cache subcommand is required\n%s", cacheUsage)
+	}
+
+	store := modManager.Cache()
+
+	switch args[0] {
+	case "list":
+		entries, err := store.List()
+		if err != nil {
+			return fmt.Errorf("listing cache: %w", err)
+		}
+		if len(entries) == 0 {
+			fmt.Println("Cache is empty")
+			return nil
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s  %9s  %s  %s\n", entry.SHA256[:12], formatSize(entry.Size),
+				entry.LastUsed.Local().Format("2006-01-02 15:04"), entry.Key)
+		}
+		return nil
+
+	case "stats":
+		stats, err := store.Stats()
+		if err != nil {
+			return fmt.Errorf("reading cache: %w", err)
+		}
+		fmt.Printf("Location: %s\n", store.Dir())
+		fmt.Printf("Entries:  %d\n", stats.Entries)
+		fmt.Printf("Blobs:    %d\n", stats.Blobs)
+		fmt.Printf("Size:     %s\n", formatSize(stats.Size))
+		return nil
+
+	case "verify":
+		fmt.Println("Verifying cache...")
+		problems, err := store.Verify()
+		if err != nil {
+			return fmt.Errorf("verifying cache: %w", err)
+		}
+		for _, problem := range problems {
+			if problem.Key != "" {
+				fmt.Printf("  → Removed entry '%s': %s\n", problem.Key, problem.Reason)
+			} else {
+				fmt.Printf("  → Removed blob %s: %s\n", problem.SHA256[:12], problem.Reason)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("cache had %d problems; affected downloads will be fetched again", len(problems))
+		}
+		fmt.Println("Cache is intact")
+		return nil
+
+	case "prune":
+		const pruneUsage = "Usage: factctl cache prune [--older-than <age>] [--max-size <size>] [--force]"
+		var opts cache.PruneOptions
+		force := false
+		for i := 1; i < len(args); i++ {
+			if args[i] == "--force" {
+				force = true
+				continue
+			}
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value\n%s", args[i], pruneUsage)
+			}
+			var err error
+			switch args[i] {
+			case "--older-than":
+				opts.OlderThan, err = parseAge(args[i+1])
+			case "--max-size":
+				opts.MaxSize, err = parseSize(args[i+1])
+			default:
+				return fmt.Errorf("unknown option %s\n%s", args[i], pruneUsage)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid %s value: %w", args[i], err)
+			}
+			i++
+		}
+
+		// Instances in symlink mode point straight at their blobs
+		linked, err := modManager.LinkedBlobs()
+		if err != nil {
+			return fmt.Errorf("finding linked mods: %w", err)
+		}
+		if !force {
+			opts.Keep = make(map[string]bool)
+			for sha := range linked {
+				opts.Keep[sha] = true
+			}
+		}
+
+		result, err := store.Prune(opts)
+		if err != nil {
+			return fmt.Errorf("pruning cache: %w", err)
+		}
+		fmt.Printf("Removed %d entries and %d blobs, freed %s\n", result.Entries, result.Blobs, formatSize(result.Freed))
+		if result.Kept > 0 {
+			fmt.Printf("  → Kept %d blobs that instances link to (%s); use --force to remove them\n",
+				result.Kept, strings.Join(linkingInstances(linked), ", "))
+		}
+		return nil
+
+	case "clear":
+		force := false
+		for _, arg := range args[1:] {
+			if arg != "--force" {
+				return fmt.Errorf("unknown option %s\nUsage: factctl cache clear [--force]", arg)
+			}
+			force = true
+		}
+
+		if !force {
+			linked, err := modManager.LinkedBlobs()
+			if err != nil {
+				return fmt.Errorf("finding linked mods: %w", err)
+			}
+			if len(linked) > 0 {
+				return fmt.Errorf("%d cached mods are symlinked into instances: %s\nHint: Use --force to clear anyway; the links work again after the next 'factctl up'",
+					len(linked), strings.Join(linkingInstances(linked), ", "))
+			}
+		}
+
+		if err := store.Clear(); err != nil {
+			return fmt.Errorf("clearing cache: %w", err)
+		}
+		// Downloads cached by older versions of factctl
+		if err := os.RemoveAll(filepath.Join(baseDir, "cache", "downloads")); err != nil {
+			return fmt.Errorf("clearing old cache: %w", err)
+		}
+		fmt.Println("Cache cleared")
+		return nil
+
+	default:
+		return fmt.Errorf("unknown cache subcommand: %s\n%s", args[0], cacheUsage)
+	}
+}
+
+// linkingInstances returns the sorted names of the instances that link to any
+// of the blobs
+func linkingInstances(linked map[string][]string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, users := range linked {
+		for _, name := range users {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseAge parses a duration such as "30d", "12h" or "90m"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseSize parses a size such as "500MB", "2GB" or a plain number of bytes
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40}, {"B", 1},
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			upper, multiplier = number, unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatSize formats a number of bytes for display
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
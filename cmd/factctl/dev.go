@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/WhyIsSandwich/factctl/internal/instance"
+	"github.com/WhyIsSandwich/factctl/internal/rcon"
+)
+
+const devUsage = "Usage: factctl dev <instance-name> [--debounce <duration>] [--rcon]"
+
+// handleDev runs an instance as a headless server and reloads it whenever one
+// of its local mods changes, showing the errors Factorio logs while loading
+func handleDev(runtimeManager *instance.RuntimeManager, manager *instance.Manager, logManager *instance.LogManager, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("instance name is required\n%s", devUsage)
+	}
+
+	instanceName := args[0]
+	debounce := 500 * time.Millisecond
+	useRCON := false
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--rcon":
+			useRCON = true
+		case "--debounce":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--debounce requires a duration\n%s", devUsage)
+			}
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid debounce %q\nHint: Use a duration such as 500ms or 2s", args[i+1])
+			}
+			debounce = d
+			i++
+		default:
+			return fmt.Errorf("unknown option %q\n%s", args[i], devUsage)
+		}
+	}
+
+	if err := validateInstanceName(instanceName); err != nil {
+		return fmt.Errorf("invalid instance name: %w", err)
+	}
+
+	inst, err := manager.Load(instanceName)
+	if err != nil {
+		return fmt.Errorf("%w\nHint: Use 'factctl up %s' to create it first", err, instanceName)
+	}
+
+	// Watch mode restarts a server, not a game client
+	inst.Config.Headless = true
+
+	if useRCON && inst.Config.RCONPort == 0 {
+		return fmt.Errorf("--rcon needs RCON enabled for the instance\nHint: Set rcon_port and rcon_password in %s", instance.ConfigPath(inst))
+	}
+
+	mods, err := instance.LocalMods(inst)
+	if err != nil {
+		return err
+	}
+	if len(mods) == 0 {
+		return fmt.Errorf("instance '%s' has no local mods to watch\nHint: Link a mod directory into the instance with a file: source, such as file:/path/to/my-mod", instanceName)
+	}
+
+	names := make([]string, 0, len(mods))
+	for name := range mods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  → Watching '%s' in %s\n", name, mods[name])
+		if _, err := instance.ValidateLocalMod(inst, name, mods[name]); err != nil {
+			fmt.Printf("  → Warning: '%s': %v\n", name, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Follow the log from before the first start so no load error is missed.
+	// Only errors are shown; the full log is in factorio.log.
+	logPath := filepath.Join(inst.Dir, "factorio.log")
+	if f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+		f.Close()
+	}
+	handler := func(entry instance.LogEntry) {
+		if instance.IsLoadError(entry) {
+			fmt.Printf("  ! %s\n", strings.TrimSpace(entry.Message))
+		}
+	}
+	logManager.Subscribe(instanceName, handler)
+	defer logManager.Unsubscribe(instanceName, handler)
+	if err := logManager.StreamLogs(ctx, instanceName); err != nil {
+		fmt.Printf("  → Warning: Not showing load errors: %v\n", err)
+	}
+
+	fmt.Printf("Starting instance '%s'...\n", instanceName)
+	if err := runtimeManager.Start(context.Background(), inst); err != nil {
+		return fmt.Errorf("failed to start instance: %w\nHint: Check that Factorio is installed and accessible", err)
+	}
+	defer func() {
+		if runtimeManager.IsRunning(instanceName) {
+			fmt.Printf("Stopping instance '%s'...\n", instanceName)
+			if err := runtimeManager.Stop(instanceName); err != nil {
+				fmt.Printf("  → Warning: %v\n", err)
+			}
+		}
+	}()
+
+	fmt.Printf("Watching %d local mods (press Ctrl+C to stop)...\n", len(mods))
+	return instance.WatchLocalMods(ctx, mods, debounce, func(changed []string) {
+		fmt.Printf("Changed: %s\n", strings.Join(changed, ", "))
+
+		// A broken info.json would only make Factorio refuse to start
+		for _, name := range changed {
+			if _, err := instance.ValidateLocalMod(inst, name, mods[name]); err != nil {
+				fmt.Printf("  → Not reloading, '%s' is invalid: %v\n", name, err)
+				return
+			}
+		}
+
+		if useRCON && runtimeManager.IsRunning(instanceName) {
+			err := reloadOverRCON(ctx, inst)
+			if err == nil {
+				fmt.Println("  → Reloaded mods over RCON")
+				return
+			}
+			fmt.Printf("  → Warning: RCON reload failed (%v), restarting instead\n", err)
+		}
+
+		fmt.Printf("  → Restarting instance '%s'...\n", instanceName)
+		if err := runtimeManager.Restart(context.Background(), inst); err != nil {
+			fmt.Printf("  → Warning: Restart failed: %v\n", err)
+		}
+	})
+}
+
+// reloadOverRCON asks the running server to reload its mods
+func reloadOverRCON(ctx context.Context, inst *instance.Instance) error {
+	addr := fmt.Sprintf("127.0.0.1:%d", inst.Config.RCONPort)
+	client, err := rcon.Dial(ctx, addr, inst.Config.RCONPassword)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	out, err := client.Execute("/silent-command game.reload_mods()")
+	if err != nil {
+		return err
+	}
+	// Lua errors come back as the command's output
+	if out = strings.TrimSpace(out); out != "" {
+		return fmt.Errorf("%s", out)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/WhyIsSandwich/factctl/internal/instance"
+)
+
+const exportUsage = "Usage: factctl export-modpack <instance-name> [--bundle] [--name <mod-name>] [--version <version>] [--title <title>] [--output <file>]"
+
+// handleExportModpack writes an instance's mod set as a modpack mod, or as a
+// bundle of the modpack and every mod zip, for players without factctl
+func handleExportModpack(manager *instance.Manager, modManager *instance.ModManager, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("instance name is required\n%s", exportUsage)
+	}
+
+	instanceName := args[0]
+	opts := instance.ModpackOptions{}
+	output := ""
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--bundle":
+			opts.Bundle = true
+		case "--name", "--version", "--title", "--output", "-o":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value\n%s", args[i], exportUsage)
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--name":
+				opts.Name = value
+			case "--version":
+				opts.Version = value
+			case "--title":
+				opts.Title = value
+			default:
+				output = value
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option %q\n%s", args[i], exportUsage)
+		}
+	}
+
+	if err := validateInstanceName(instanceName); err != nil {
+		return fmt.Errorf("invalid instance name: %w", err)
+	}
+
+	inst, err := manager.Load(instanceName)
+	if err != nil {
+		return fmt.Errorf("%w\nHint: Use 'factctl up %s' to create it first", err, instanceName)
+	}
+
+	// Check the mod set before creating the output file
+	pack, err := modManager.Modpack(inst, opts)
+	if err != nil {
+		return fmt.Errorf("building modpack: %w\nHint: Run 'factctl up %s' to install the enabled mods", err, instanceName)
+	}
+	opts.Name, opts.Version = pack.Name, pack.Version
+	if output == "" {
+		output = opts.FileName()
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", output, err)
+	}
+	if err := modManager.ExportModpack(inst, f, opts); err != nil {
+		f.Close()
+		os.Remove(output)
+		return fmt.Errorf("exporting modpack: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(output)
+		return fmt.Errorf("writing %s: %w", output, err)
+	}
+
+	fmt.Printf("Exported '%s' %s with %d dependencies to %s\n", pack.Name, pack.Version, len(pack.Dependencies), output)
+	if opts.Bundle {
+		fmt.Println("Extract it into a Factorio mods directory to use it")
+	} else {
+		fmt.Println("Put it in a Factorio mods directory next to the pinned mods")
+	}
+	return nil
+}
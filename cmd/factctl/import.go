@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/WhyIsSandwich/factctl/internal/instance"
+)
+
+const importUsage = "Usage: factctl import <instance-name> --from <profile-dir> [--version <version>]"
+
+// handleImport creates an instance from an existing Factorio profile, such as
+// ~/.factorio, keeping its mods, which of them are enabled, and their settings
+func handleImport(manager *instance.Manager, modManager *instance.ModManager, args []string, headless bool) error {
+	if len(args) < 1 {
+		return fmt.Errorf("instance name is required\n%s", importUsage)
+	}
+
+	instanceName := args[0]
+	from := ""
+	version := ""
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--from", "--version":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value\n%s", args[i], importUsage)
+			}
+			if args[i] == "--from" {
+				from = args[i+1]
+			} else {
+				version = args[i+1]
+			}
+			i++
+		default:
+			return fmt.Errorf("unknown option %q\n%s", args[i], importUsage)
+		}
+	}
+
+	if err := validateInstanceName(instanceName); err != nil {
+		return fmt.Errorf("invalid instance name: %w", err)
+	}
+	if from == "" {
+		return fmt.Errorf("profile directory is required\n%s", importUsage)
+	}
+	if _, err := manager.Load(instanceName); err == nil {
+		return fmt.Errorf("instance '%s' already exists\nHint: Import under another name, or remove it with 'factctl down %s'", instanceName, instanceName)
+	}
+
+	if rest, ok := strings.CutPrefix(from, "~"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("finding home directory: %w", err)
+		}
+		from = filepath.Join(home, rest)
+	}
+
+	fmt.Printf("Reading Factorio profile %s...\n", from)
+	profile, err := modManager.ReadProfile(context.Background(), from)
+	if err != nil {
+		return fmt.Errorf("reading profile: %w\nHint: Point --from at the directory holding the mods folder", err)
+	}
+	for _, warning := range profile.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	if version == "" && profile.Settings != nil {
+		version = profile.Settings.Version.String()
+	}
+	if version == "" {
+		return fmt.Errorf("could not tell which Factorio version the profile is for\nHint: Pass --version <version>")
+	}
+
+	cfg := profile.Config(instanceName, version)
+	cfg.Headless = headless
+
+	fmt.Printf("Creating instance '%s' for Factorio %s...\n", instanceName, version)
+	inst, err := manager.Create(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create instance: %w\nHint: Check that the Factorio runtime is installed, or pass --factorio-path", err)
+	}
+
+	if err := updatePlayerDataWithCredentials(manager, inst); err != nil {
+		fmt.Printf("Warning: Could not update player-data.json with credentials: %v\n", err)
+	}
+
+	if err := modManager.ImportProfile(inst, profile); err != nil {
+		return fmt.Errorf("importing mods: %w", err)
+	}
+
+	portal := 0
+	for _, mod := range profile.Mods {
+		source := "file"
+		if mod.Portal {
+			source = "portal"
+			portal++
+		}
+		state := ""
+		if !mod.Enabled {
+			state = ", disabled"
+		}
+		fmt.Printf("  → %s %s (%s%s)\n", mod.Name, mod.Version, source, state)
+	}
+
+	fmt.Printf("Imported %d mods (%d from the mod portal, %d as local files)\n", len(profile.Mods), portal, len(profile.Mods)-portal)
+	fmt.Printf("Configuration: %s\n", instance.ConfigPath(inst))
+	fmt.Printf("Run 'factctl up %s' to resolve dependencies and write the lockfile\n", instanceName)
+	return nil
+}
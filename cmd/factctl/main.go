@@ -4,16 +4,19 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/WhyIsSandwich/factctl/internal/auth"
 	"github.com/WhyIsSandwich/factctl/internal/instance"
+	"github.com/WhyIsSandwich/factctl/internal/savegame"
 	"golang.org/x/term"
 )
 
@@ -35,9 +38,14 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Commands:\n")
 		fmt.Fprintf(os.Stderr, "  up      Create or update an instance\n")
 		fmt.Fprintf(os.Stderr, "  down    Remove an instance\n")
+		fmt.Fprintf(os.Stderr, "  import  Create an instance from an existing Factorio profile\n")
+		fmt.Fprintf(os.Stderr, "  export-modpack Export an instance's mods as a modpack\n")
 		fmt.Fprintf(os.Stderr, "  run     Launch Factorio with the specified instance\n")
+		fmt.Fprintf(os.Stderr, "  dev     Run an instance and reload it when its local mods change\n")
 		fmt.Fprintf(os.Stderr, "  logs    Stream instance logs\n")
-		fmt.Fprintf(os.Stderr, "  auth    Configure Factorio portal credentials\n")
+		fmt.Fprintf(os.Stderr, "  auth    Configure Factorio portal credentials (auth github: GitHub token)\n")
+		fmt.Fprintf(os.Stderr, "  mods    Manage an instance's mods (list|add|remove|enable|disable|outdated|update|graph|why|settings|pack)\n")
+		fmt.Fprintf(os.Stderr, "  cache   Manage the download cache (list|stats|verify|prune|clear)\n")
 		fmt.Fprintf(os.Stderr, "  download Download Factorio to runtimes (usage: <build-type> [version])\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
@@ -90,6 +98,16 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "import":
+		if err := handleImport(manager, modManager, args[1:], *headless); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "export-modpack":
+		if err := handleExportModpack(manager, modManager, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	case "down":
 		if err := handleDown(manager, args[1:]); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -100,6 +118,11 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "dev":
+		if err := handleDev(runtimeManager, manager, logManager, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	case "logs":
 		if err := handleLogs(logManager, args[1:]); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -110,6 +133,16 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "mods":
+		if err := handleMods(manager, modManager, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "cache":
+		if err := handleCache(modManager, baseDirPath, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	case "download":
 		if err := handleDownload(baseDirPath, args[1:]); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -122,13 +155,55 @@ func main() {
 	}
 }
 
+const upUsage = "Usage: factctl up <instance-name> [--config <file>] [--headless] [--frozen] [--prune] [--jobs <n>] [--from-save <save.zip>]"
+
 // handleUp creates or updates an instance
 func handleUp(manager *instance.Manager, modManager *instance.ModManager, args []string, configPath string, headless bool) error {
 	if len(args) < 1 {
-		return fmt.Errorf("instance name is required\nUsage: factctl up <instance-name> [options]")
+		return fmt.Errorf("instance name is required\n%s", upUsage)
 	}
 
 	instanceName := args[0]
+	frozen := false
+	prune := false
+	fromSave := ""
+
+	// Check for flags. --config and --headless are also accepted here, after
+	// the instance name, as well as before the command.
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--config":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--config requires a file\n%s", upUsage)
+			}
+			i++
+			configPath = args[i]
+		case "--headless":
+			headless = true
+		case "--frozen":
+			frozen = true
+		case "--prune":
+			prune = true
+		case "--from-save":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--from-save requires a save file\n%s", upUsage)
+			}
+			i++
+			fromSave = args[i]
+		case "--jobs", "-j":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--jobs requires a number\n%s", upUsage)
+			}
+			i++
+			jobs, err := strconv.Atoi(args[i])
+			if err != nil || jobs < 1 {
+				return fmt.Errorf("invalid --jobs value %q\nHint: Use a positive number of parallel downloads", args[i])
+			}
+			modManager.SetWorkers(jobs)
+		default:
+			return fmt.Errorf("unknown option %q\n%s", args[i], upUsage)
+		}
+	}
 
 	// Validate instance name
 	if err := validateInstanceName(instanceName); err != nil {
@@ -152,6 +227,13 @@ func handleUp(manager *instance.Manager, modManager *instance.ModManager, args [
 		// Override the name from the config file with the command-line argument
 		// This allows using the same config file for multiple instances with different names
 		cfg.Name = instanceName
+	} else if frozen {
+		// The lockfile was written for the instance's own config
+		existing, err := manager.Load(instanceName)
+		if err != nil {
+			return fmt.Errorf("%w\nHint: --frozen needs an existing instance or --config", err)
+		}
+		cfg = existing.Config
 	} else {
 		// Create default configuration
 		cfg = &instance.Config{
@@ -165,6 +247,19 @@ func handleUp(manager *instance.Manager, modManager *instance.ModManager, args [
 		}
 	}
 
+	// Take the Factorio version and exact mod versions from the save
+	if fromSave != "" {
+		if frozen {
+			return fmt.Errorf("--from-save cannot be combined with --frozen\nHint: The save decides the mod versions, so the lockfile is rewritten from it")
+		}
+		header, err := savegame.Read(fromSave)
+		if err != nil {
+			return fmt.Errorf("reading save: %w\nHint: Pass a save zip from Factorio's saves directory", err)
+		}
+		cfg.ApplySave(header, fromSave)
+		fmt.Printf("Save '%s' was made with Factorio %s and %d mods\n", filepath.Base(fromSave), header.Version, len(header.Mods))
+	}
+
 	// Override headless mode if specified
 	if headless {
 		cfg.Headless = true
@@ -183,8 +278,27 @@ func handleUp(manager *instance.Manager, modManager *instance.ModManager, args [
 		fmt.Printf("Warning: Could not update player-data.json with credentials: %v\n", err)
 	}
 
-	// Install mods if specified
-	if len(cfg.Mods.Enabled) > 0 {
+	if fromSave != "" {
+		if err := instance.CopySave(inst, fromSave); err != nil {
+			return err
+		}
+		fmt.Printf("  → Copied %s to saves/\n", filepath.Base(fromSave))
+	}
+
+	// Install exactly the locked mod set if requested
+	installFailed := false
+	if frozen {
+		fmt.Println("Installing mods from lockfile...")
+		ctx := context.Background()
+
+		installedMods, err := modManager.InstallModsFrozen(ctx, inst)
+		if err != nil {
+			return fmt.Errorf("frozen install failed: %w", err)
+		}
+
+		fmt.Printf("Successfully installed %d locked mods\n", len(installedMods))
+	} else if len(cfg.Mods.Enabled) > 0 {
+		// Install mods if specified
 		fmt.Println("Installing mods and dependencies...")
 		ctx := context.Background()
 
@@ -192,16 +306,59 @@ func handleUp(manager *instance.Manager, modManager *instance.ModManager, args [
 		installedMods, err := modManager.InstallModsRecursively(ctx, inst, cfg.Mods.Enabled)
 		if err != nil {
 			fmt.Printf("Warning: Some mods failed to install: %v\n", err)
+			installFailed = true
 		}
 
 		fmt.Printf("Successfully installed %d mods total\n", len(installedMods))
 	}
 
+	// Find mods left over from earlier configurations. A failed install would
+	// make the mods it needs look unused, so nothing is removed then.
+	if !frozen && !installFailed {
+		if err := reconcileMods(modManager, inst, prune); err != nil {
+			return err
+		}
+	}
+
+	// Write configured mod settings into mod-settings.dat
+	if count, err := instance.WriteModSettings(inst); err != nil {
+		return fmt.Errorf("writing mod settings: %w\nHint: Check the mods.settings section of the configuration", err)
+	} else if count > 0 {
+		fmt.Printf("Wrote %d mod settings to mod-settings.dat\n", count)
+	}
+
 	fmt.Printf("Instance '%s' created successfully!\n", instanceName)
 	fmt.Printf("Instance directory: %s\n", inst.Dir)
 	return nil
 }
 
+// reconcileMods lists the installed mods that nothing in the config needs
+// any more, and removes them if prune is set
+func reconcileMods(modManager *instance.ModManager, inst *instance.Instance, prune bool) error {
+	orphans, err := modManager.Orphans(inst)
+	if err != nil {
+		return fmt.Errorf("finding unused mods: %w", err)
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	fmt.Printf("%d installed mods are no longer needed:\n", len(orphans))
+	for _, name := range orphans {
+		fmt.Printf("  → %s\n", name)
+	}
+	if !prune {
+		fmt.Printf("Run 'factctl up %s --prune' to remove them\n", inst.Config.Name)
+		return nil
+	}
+
+	if err := modManager.PruneMods(inst, orphans); err != nil {
+		return fmt.Errorf("removing unused mods: %w", err)
+	}
+	fmt.Printf("Removed %d unused mods\n", len(orphans))
+	return nil
+}
+
 // handleDown removes an instance
 func handleDown(manager *instance.Manager, args []string) error {
 	if len(args) < 1 {
@@ -401,6 +558,13 @@ func validateInstanceName(name string) error {
 
 // handleAuth configures Factorio portal credentials
 func handleAuth(baseDir string, args []string) error {
+	if len(args) > 0 {
+		if args[0] == "github" {
+			return handleAuthGitHub(baseDir)
+		}
+		return fmt.Errorf("unknown auth target %q\nUsage: factctl auth [github]", args[0])
+	}
+
 	fmt.Println("Configuring Factorio portal credentials...")
 	fmt.Println("You'll need your Factorio username and password to authenticate with the Factorio API.")
 	fmt.Println()
@@ -438,16 +602,17 @@ func handleAuth(baseDir string, args []string) error {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
 
-	// Create credentials
-	creds := &auth.Credentials{
-		FactorioUsername: username,
-		FactorioToken:    token,
-	}
-
-	// Save credentials to config directory
+	// Save credentials to config directory, keeping a stored GitHub token
 	configDir := filepath.Join(baseDir, "config")
 	store := auth.NewStore(configDir)
 
+	creds, err := loadCredentials(store)
+	if err != nil {
+		return err
+	}
+	creds.FactorioUsername = username
+	creds.FactorioToken = token
+
 	if err := store.Save(creds); err != nil {
 		return fmt.Errorf("saving credentials: %w", err)
 	}
@@ -458,6 +623,55 @@ func handleAuth(baseDir string, args []string) error {
 	return nil
 }
 
+// handleAuthGitHub stores a GitHub token for GitHub sources. GITHUB_TOKEN
+// takes precedence over it.
+func handleAuthGitHub(baseDir string) error {
+	fmt.Println("Configuring a GitHub token...")
+	fmt.Println("GitHub allows 60 API requests per hour without one, which a modded 'factctl up' can use up.")
+	fmt.Println("Create a token at https://github.com/settings/tokens; it needs no scopes for public repositories.")
+	fmt.Println()
+
+	fmt.Print("GitHub token: ")
+	tokenBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("reading token: %w", err)
+	}
+	token := strings.TrimSpace(string(tokenBytes))
+	fmt.Println() // Add newline after masked input
+
+	if token == "" {
+		return fmt.Errorf("token cannot be empty")
+	}
+
+	configDir := filepath.Join(baseDir, "config")
+	store := auth.NewStore(configDir)
+
+	creds, err := loadCredentials(store)
+	if err != nil {
+		return err
+	}
+	creds.GitHubToken = token
+
+	if err := store.Save(creds); err != nil {
+		return fmt.Errorf("saving credentials: %w", err)
+	}
+
+	fmt.Printf("GitHub token saved to %s\n", filepath.Join(configDir, "credentials.json"))
+	return nil
+}
+
+// loadCredentials returns the stored credentials, or empty ones if there are none
+func loadCredentials(store *auth.Store) (*auth.Credentials, error) {
+	creds, err := store.Load()
+	if errors.Is(err, auth.ErrNoCredentials) {
+		return &auth.Credentials{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading credentials: %w", err)
+	}
+	return creds, nil
+}
+
 // authenticateWithFactorio authenticates with the Factorio API and returns a token
 func authenticateWithFactorio(username, password string) (string, error) {
 	// Prepare form data for the authentication request
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/WhyIsSandwich/factctl/internal/instance"
+)
+
+const modsUsage = "Usage: factctl mods <list|add|remove|enable|disable|outdated|update|graph|why|settings|pack> <instance-name> [mod] [options]"
+
+const packUsage = "Usage: factctl mods pack <mod-directory> [--output <file-or-directory>]"
+
+const settingsUsage = "Usage: factctl mods settings <get|set|dump> <instance-name> [setting] [value] [--scope <scope>]"
+
+// maxChangelogLines is how much of each mod's changelog outdated prints
+const maxChangelogLines = 8
+
+// handleMods lists and changes the mods of an instance, keeping instance.json,
+// mod-list.json and the mods directory in step
+func handleMods(manager *instance.Manager, modManager *instance.ModManager, args []string) error {
+	// Packing works on a mod's folder rather than an instance
+	if len(args) > 0 && args[0] == "pack" {
+		return packMod(args[1:])
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("mods subcommand and instance name are required\n%s", modsUsage)
+	}
+
+	// Settings take their own subcommand before the instance name
+	if args[0] == "settings" {
+		return handleModSettings(manager, args[1:])
+	}
+
+	subcommand, instanceName := args[0], args[1]
+	if err := validateInstanceName(instanceName); err != nil {
+		return fmt.Errorf("invalid instance name: %w", err)
+	}
+
+	inst, err := manager.Load(instanceName)
+	if err != nil {
+		return fmt.Errorf("%w\nHint: Use 'factctl up %s' to create it first", err, instanceName)
+	}
+
+	switch subcommand {
+	case "list":
+		return listMods(modManager, inst)
+	case "outdated":
+		return listOutdatedMods(modManager, inst)
+	case "update":
+		return updateMods(modManager, inst, args[2:])
+	case "graph":
+		return showModGraph(modManager, inst, args[2:])
+	}
+
+	if len(args) < 3 {
+		return fmt.Errorf("mod name is required\nUsage: factctl mods %s <instance-name> <mod>", subcommand)
+	}
+	modName := args[2]
+
+	switch subcommand {
+	case "add":
+		sourceSpec := ""
+		for i := 3; i < len(args); i++ {
+			switch args[i] {
+			case "--source":
+				if i+1 >= len(args) {
+					return fmt.Errorf("--source requires a source specification\nHint: For example --source github:user/repo")
+				}
+				i++
+				sourceSpec = args[i]
+			default:
+				return fmt.Errorf("unknown option %s\nUsage: factctl mods add <instance-name> <mod> [--source <spec>]", args[i])
+			}
+		}
+
+		fmt.Printf("Adding mod '%s' to instance '%s'...\n", modName, instanceName)
+		installed, err := modManager.AddMod(context.Background(), inst, modName, sourceSpec)
+		if err != nil {
+			return fmt.Errorf("adding mod: %w\nHint: The config was updated; run 'factctl up %s' to retry the install", err, instanceName)
+		}
+		fmt.Printf("Mod '%s' added (%d mods installed)\n", modName, len(installed))
+
+	case "remove":
+		prune, dryRun := false, false
+		for _, arg := range args[3:] {
+			switch arg {
+			case "--prune":
+				prune = true
+			case "--dry-run":
+				dryRun = true
+			default:
+				return fmt.Errorf("unknown option %s\nUsage: factctl mods remove <instance-name> <mod> [--prune] [--dry-run]", arg)
+			}
+		}
+		return removeMod(modManager, inst, modName, prune, dryRun)
+
+	case "why":
+		return explainMod(modManager, inst, modName)
+
+	case "enable", "disable":
+		enable := subcommand == "enable"
+		if err := modManager.SetModEnabled(inst, modName, enable); err != nil {
+			if enable {
+				return fmt.Errorf("enabling mod: %w\nHint: Use 'factctl mods add %s %s' to install it", err, instanceName, modName)
+			}
+			return fmt.Errorf("disabling mod: %w", err)
+		}
+		fmt.Printf("Mod '%s' %sd\n", modName, subcommand)
+
+	default:
+		return fmt.Errorf("unknown mods subcommand: %s\n%s", subcommand, modsUsage)
+	}
+
+	return nil
+}
+
+// packMod builds the release zip of a mod from its folder
+func packMod(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("mod directory is required\n%s", packUsage)
+	}
+
+	dir := args[0]
+	output := ""
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--output", "-o":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value\n%s", args[i], packUsage)
+			}
+			i++
+			output = args[i]
+		default:
+			return fmt.Errorf("unknown option %q\n%s", args[i], packUsage)
+		}
+	}
+
+	// Check the mod before creating the output file
+	info, err := instance.CheckModFolder(dir)
+	if err != nil {
+		return fmt.Errorf("checking mod in %s: %w\nHint: Fix info.json; Factorio and the mod portal would reject the zip", dir, err)
+	}
+	fileName := info.Name + "_" + info.Version + ".zip"
+	if output == "" {
+		output = fileName
+	} else if stat, err := os.Stat(output); err == nil && stat.IsDir() {
+		output = filepath.Join(output, fileName)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", output, err)
+	}
+	if _, err := instance.PackMod(dir, f); err != nil {
+		f.Close()
+		os.Remove(output)
+		return fmt.Errorf("packing mod: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(output)
+		return fmt.Errorf("writing %s: %w", output, err)
+	}
+
+	if stat, err := os.Stat(output); err == nil {
+		fmt.Printf("Packed '%s' %s to %s (%.1f KB)\n", info.Name, info.Version, output, float64(stat.Size())/1024)
+	}
+	return nil
+}
+
+// listMods prints every mod of an instance with its version, source and state
+func listMods(modManager *instance.ModManager, inst *instance.Instance) error {
+	statuses, err := modManager.ModStatuses(inst)
+	if err != nil {
+		return fmt.Errorf("listing mods: %w", err)
+	}
+	if len(statuses) == 0 {
+		fmt.Printf("No mods in instance '%s'\n", inst.Config.Name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tSOURCE\tSTATE")
+	for _, s := range statuses {
+		version, source := s.Version, s.Source
+		if version == "" {
+			version = "-"
+			if (s.Requested || s.Disabled) && !s.Builtin {
+				version = "not installed"
+			}
+		}
+		if source == "" {
+			source = "-"
+		}
+
+		state := "disabled"
+		switch {
+		case (s.Enabled || s.Builtin) && s.Requested:
+			state = "enabled"
+		case s.Enabled:
+			state = "enabled (dependency)"
+		case s.Requested:
+			state = "enabled in config only"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, version, source, state)
+	}
+	return w.Flush()
+}
+
+// listOutdatedMods prints the installed mods that have updates, followed by
+// the changelog entries of each update
+func listOutdatedMods(modManager *instance.ModManager, inst *instance.Instance) error {
+	fmt.Printf("Checking for updates to mods in instance '%s'...\n", inst.Config.Name)
+	outdated, err := modManager.Outdated(context.Background(), inst)
+	if err != nil {
+		return fmt.Errorf("checking for updates: %w\nHint: Check your network connection and the instance's mod sources", err)
+	}
+	if len(outdated) == 0 {
+		fmt.Println("All mods are up to date")
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tINSTALLED\tLATEST\tSOURCE")
+	for _, mod := range outdated {
+		latest := mod.Latest
+		if mod.Latest == mod.Installed && mod.Revision != "" {
+			latest = fmt.Sprintf("%s (new commit %.7s)", mod.Latest, mod.Revision)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mod.Name, mod.Installed, latest, mod.Source)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, mod := range outdated {
+		if mod.Changelog == "" {
+			continue
+		}
+		fmt.Printf("\n%s %s → %s:\n", mod.Name, mod.Installed, mod.Latest)
+		lines := strings.Split(mod.Changelog, "\n")
+		for i, line := range lines {
+			if i == maxChangelogLines {
+				fmt.Printf("  ... (%d more lines)\n", len(lines)-i)
+				break
+			}
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	fmt.Printf("\nRun 'factctl mods update %s' to update\n", inst.Config.Name)
+	return nil
+}
+
+// updateMods updates the named mods, or all mods, rolling back on failure
+func updateMods(modManager *instance.ModManager, inst *instance.Instance, modNames []string) error {
+	fmt.Printf("Updating mods in instance '%s'...\n", inst.Config.Name)
+	updates, backupDir, err := modManager.UpdateMods(context.Background(), inst, modNames)
+	if err != nil {
+		if backupDir != "" {
+			return fmt.Errorf("updating mods: %w\nHint: The previous mods were restored; saves are backed up in %s", err, backupDir)
+		}
+		return fmt.Errorf("updating mods: %w", err)
+	}
+
+	if len(updates) == 0 {
+		fmt.Println("All mods are up to date")
+		return nil
+	}
+	for _, update := range updates {
+		fmt.Printf("  → %s %s → %s\n", update.Name, update.From, update.To)
+	}
+	fmt.Printf("Updated %d mods. Saves and the previous mods are backed up in %s\n", len(updates), backupDir)
+	return nil
+}
+
+// removeMod removes a mod and, with prune, the mods only it needed. The mods
+// to be removed are listed first; a dry run stops there.
+func removeMod(modManager *instance.ModManager, inst *instance.Instance, modName string, prune, dryRun bool) error {
+	var orphans []string
+	if prune {
+		var err error
+		if orphans, err = modManager.Orphans(inst, modName); err != nil {
+			return fmt.Errorf("finding unused mods: %w", err)
+		}
+	}
+
+	fmt.Printf("Removing from instance '%s':\n", inst.Config.Name)
+	fmt.Printf("  → %s\n", modName)
+	for _, name := range orphans {
+		fmt.Printf("  → %s (no longer needed)\n", name)
+	}
+	if dryRun {
+		fmt.Println("Dry run: nothing was removed")
+		return nil
+	}
+
+	if err := modManager.RemoveMod(inst, modName); err != nil {
+		return fmt.Errorf("removing mod: %w\nHint: Use 'factctl mods list %s' to see the instance's mods", err, inst.Config.Name)
+	}
+	if err := modManager.PruneMods(inst, orphans); err != nil {
+		return fmt.Errorf("removing unused mods: %w", err)
+	}
+	fmt.Printf("Removed %d mods from instance '%s'\n", len(orphans)+1, inst.Config.Name)
+	return nil
+}
+
+// showModGraph prints the dependency graph of an instance as a tree, Graphviz
+// dot or JSON
+func showModGraph(modManager *instance.ModManager, inst *instance.Instance, args []string) error {
+	format := "tree"
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--format requires a value\nHint: Use tree, dot or json")
+			}
+			i++
+			format = args[i]
+		default:
+			return fmt.Errorf("unknown option %s\nUsage: factctl mods graph <instance-name> [--format tree|dot|json]", args[i])
+		}
+	}
+
+	graph, err := modManager.DependencyGraph(inst)
+	if err != nil {
+		return fmt.Errorf("building dependency graph: %w", err)
+	}
+
+	switch format {
+	case "tree":
+		return graph.WriteTree(os.Stdout)
+	case "dot":
+		return graph.WriteDot(os.Stdout, inst.Config.Name)
+	case "json":
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding dependency graph: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	default:
+		return fmt.Errorf("unknown graph format: %s\nHint: Use tree, dot or json", format)
+	}
+}
+
+// explainMod prints the chains of dependencies that bring a mod into an instance
+func explainMod(modManager *instance.ModManager, inst *instance.Instance, modName string) error {
+	graph, err := modManager.DependencyGraph(inst)
+	if err != nil {
+		return fmt.Errorf("building dependency graph: %w", err)
+	}
+
+	node := graph.Node(modName)
+	if node == nil {
+		return fmt.Errorf("mod '%s' is not part of instance '%s'\nHint: Use 'factctl mods list %s' to see the instance's mods", modName, inst.Config.Name, inst.Config.Name)
+	}
+
+	chains := graph.Why(modName)
+	switch {
+	case node.Requested:
+		fmt.Printf("'%s' is listed in mods.enabled\n", modName)
+	case node.Disabled:
+		fmt.Printf("'%s' is listed in mods.disabled\n", modName)
+	case !node.Installed:
+		fmt.Printf("'%s' is not installed\n", modName)
+	case len(chains) == 0:
+		fmt.Printf("'%s' is installed, but no mod in the config needs it\n", modName)
+	}
+
+	if len(chains) > 0 {
+		fmt.Printf("'%s' is needed by:\n", modName)
+		for _, chain := range chains {
+			fmt.Printf("  → %s\n", instance.FormatChain(chain))
+		}
+	}
+	return nil
+}
+
+// handleModSettings inspects and edits an instance's mod-settings.dat
+func handleModSettings(manager *instance.Manager, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("settings subcommand and instance name are required\n%s", settingsUsage)
+	}
+
+	subcommand, instanceName := args[0], args[1]
+	if err := validateInstanceName(instanceName); err != nil {
+		return fmt.Errorf("invalid instance name: %w", err)
+	}
+
+	// Positional arguments and --scope may be given in any order
+	var positional []string
+	scope := ""
+	for i := 2; i < len(args); i++ {
+		if args[i] == "--scope" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("--scope requires a value\nHint: Use startup, runtime-global or runtime-per-user")
+			}
+			i++
+			scope = args[i]
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+
+	inst, err := manager.Load(instanceName)
+	if err != nil {
+		return fmt.Errorf("%w\nHint: Use 'factctl up %s' to create it first", err, instanceName)
+	}
+
+	switch subcommand {
+	case "dump":
+		settings, err := instance.LoadModSettings(inst)
+		if err != nil {
+			return err
+		}
+		dump := settings.Settings
+		if scope != "" {
+			dump = map[string]map[string]any{scope: settings.Settings[scope]}
+		}
+		data, err := json.MarshalIndent(dump, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding settings: %w", err)
+		}
+		fmt.Println(string(data))
+
+	case "get":
+		if len(positional) != 1 {
+			return fmt.Errorf("setting name is required\nUsage: factctl mods settings get <instance-name> <setting>")
+		}
+		settings, err := instance.LoadModSettings(inst)
+		if err != nil {
+			return err
+		}
+		found, value, ok := settings.Get(positional[0])
+		if !ok || (scope != "" && found != scope) {
+			return fmt.Errorf("setting '%s' is not set\nHint: Use 'factctl mods settings dump %s' to see all settings", positional[0], instanceName)
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("encoding setting: %w", err)
+		}
+		fmt.Println(string(data))
+
+	case "set":
+		if len(positional) != 2 {
+			return fmt.Errorf("setting name and value are required\nUsage: factctl mods settings set <instance-name> <setting> <value> [--scope <scope>]")
+		}
+		// Values are JSON; anything else is taken as a string
+		var value any
+		if err := json.Unmarshal([]byte(positional[1]), &value); err != nil {
+			value = positional[1]
+		}
+		if err := instance.SetModSetting(inst, scope, positional[0], value); err != nil {
+			return fmt.Errorf("setting '%s': %w", positional[0], err)
+		}
+		fmt.Printf("Set '%s' to %s in mod-settings.dat and instance.json\n", positional[0], positional[1])
+
+	default:
+		return fmt.Errorf("unknown settings subcommand: %s\n%s", subcommand, settingsUsage)
+	}
+
+	return nil
+}
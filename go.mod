@@ -5,9 +5,6 @@ go 1.24.5
 require (
 	github.com/blang/semver v3.5.1+incompatible
 	github.com/ulikunitz/xz v0.5.15
-)
-
-require (
-	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/term v0.36.0 // indirect
+	golang.org/x/sys v0.37.0
+	golang.org/x/term v0.36.0
 )
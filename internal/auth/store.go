@@ -12,6 +12,7 @@ import (
 type Credentials struct {
 	FactorioUsername string `json:"factorio_username,omitempty"`
 	FactorioToken    string `json:"factorio_token,omitempty"`
+	GitHubToken      string `json:"github_token,omitempty"`
 }
 
 var (
@@ -0,0 +1,553 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// staleTempAge is how old an unfinished download must be before prune removes it
+const staleTempAge = 24 * time.Hour
+
+// Entry describes one cached download
+type Entry struct {
+	// Key identifies the download, such as a source pinned to a revision
+	Key string `json:"key"`
+
+	// SHA256 of the content, which is also the name of its blob
+	SHA256 string `json:"sha256"`
+
+	// Size of the content in bytes
+	Size int64 `json:"size"`
+
+	// When the content was downloaded
+	CachedAt time.Time `json:"cached_at"`
+
+	// When the entry was last read or written
+	LastUsed time.Time `json:"last_used"`
+}
+
+// Stats summarizes the contents of a store
+type Stats struct {
+	Entries int   // Number of keys
+	Blobs   int   // Number of distinct blobs
+	Size    int64 // Total size of all blobs in bytes
+}
+
+// Problem is an inconsistency found by Verify
+type Problem struct {
+	Key    string
+	SHA256 string
+	Reason string
+}
+
+// PruneOptions selects what Prune removes. Zero values disable a limit.
+type PruneOptions struct {
+	// Remove entries not used for this long
+	OlderThan time.Duration
+
+	// Evict least recently used entries until the blobs fit in this many bytes
+	MaxSize int64
+
+	// SHA256s of blobs that must stay, such as ones instances link to. Their
+	// entries are kept as well.
+	Keep map[string]bool
+}
+
+// PruneResult reports what Prune removed
+type PruneResult struct {
+	Entries int
+	Blobs   int
+	Freed   int64
+	Kept    int // Blobs that would have been removed but are in Keep
+}
+
+// Store is a content-addressed download cache. Content is stored once per
+// SHA256 under blobs/, and each key maps to a blob through a small file in
+// keys/, so identical downloads from different URLs share one blob. All files
+// are written atomically, and a lock file coordinates concurrent processes.
+type Store struct {
+	dir string
+}
+
+// New returns a store rooted at dir. Directories are created on first write.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the root directory of the store
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// BlobPath returns where the blob with the given SHA256 is stored
+func (s *Store) BlobPath(sha string) string {
+	return filepath.Join(s.dir, "blobs", sha[:2], sha)
+}
+
+// keyPath returns the file that records an entry's key
+func (s *Store) keyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, "keys", hex.EncodeToString(sum[:])+".json")
+}
+
+// Get looks up a key and returns its entry and the path of its blob
+func (s *Store) Get(key string) (*Entry, string, bool) {
+	// Exclusive because the entry is rewritten with its new LastUsed
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, "", false
+	}
+	defer unlock()
+
+	entry, err := s.readEntry(s.keyPath(key))
+	if err != nil || entry.Key != key {
+		return nil, "", false
+	}
+
+	path := s.BlobPath(entry.SHA256)
+	if _, err := os.Stat(path); err != nil {
+		return nil, "", false
+	}
+
+	// Recording the use only affects pruning, so failures are ignored
+	entry.LastUsed = time.Now().UTC()
+	s.writeEntry(entry)
+
+	return entry, path, true
+}
+
+// Writer streams a download into the store
+type Writer struct {
+	store *Store
+	key   string
+	file  *os.File
+	hash  hash.Hash
+	size  int64
+}
+
+// Create starts a download for key. Write the content to the returned Writer,
+// then call Commit to add it to the store or Abort to discard it.
+func (s *Store) Create(key string) (*Writer, error) {
+	tmpDir := filepath.Join(s.dir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(tmpDir, "download-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating download file: %w", err)
+	}
+
+	return &Writer{store: s, key: key, file: f, hash: sha256.New()}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+// Abort discards the download
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// Commit moves the download into its blob and points the key at it. If the
+// blob already exists, the existing copy is kept. It returns the new entry
+// and the path of the blob.
+func (w *Writer) Commit() (*Entry, string, error) {
+	defer os.Remove(w.file.Name())
+
+	if err := w.file.Close(); err != nil {
+		return nil, "", fmt.Errorf("writing download: %w", err)
+	}
+
+	s := w.store
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, "", err
+	}
+	defer unlock()
+
+	sha := hex.EncodeToString(w.hash.Sum(nil))
+	path := s.BlobPath(sha)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, "", fmt.Errorf("creating blob directory: %w", err)
+		}
+		if err := os.Chmod(w.file.Name(), 0644); err != nil {
+			return nil, "", fmt.Errorf("storing blob: %w", err)
+		}
+		if err := os.Rename(w.file.Name(), path); err != nil {
+			return nil, "", fmt.Errorf("storing blob: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	entry := &Entry{
+		Key:      w.key,
+		SHA256:   sha,
+		Size:     w.size,
+		CachedAt: now,
+		LastUsed: now,
+	}
+	if err := s.writeEntry(entry); err != nil {
+		return nil, "", err
+	}
+
+	return entry, path, nil
+}
+
+// List returns every entry, sorted by key
+func (s *Store) List() ([]*Entry, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return s.entries()
+}
+
+// Stats counts the entries and blobs in the store
+func (s *Store) Stats() (*Stats, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := s.blobs()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Entries: len(entries), Blobs: len(blobs)}
+	for _, size := range blobs {
+		stats.Size += size
+	}
+	return stats, nil
+}
+
+// Verify re-hashes every blob and checks that every entry points at one.
+// Corrupt blobs and broken entries are removed so they are downloaded again.
+func (s *Store) Verify() ([]Problem, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	blobs, err := s.blobs()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	corrupt := make(map[string]bool)
+	for sha := range blobs {
+		got, err := hashFile(s.BlobPath(sha))
+		if err != nil || got != sha {
+			reason := fmt.Sprintf("content hashes to %s", got)
+			if err != nil {
+				reason = err.Error()
+			}
+			problems = append(problems, Problem{SHA256: sha, Reason: reason})
+			corrupt[sha] = true
+			os.Remove(s.BlobPath(sha))
+		}
+	}
+
+	entries, err := s.entries()
+	if err != nil {
+		return problems, err
+	}
+	for _, entry := range entries {
+		_, exists := blobs[entry.SHA256]
+		switch {
+		case corrupt[entry.SHA256]:
+			problems = append(problems, Problem{Key: entry.Key, SHA256: entry.SHA256, Reason: "blob is corrupt"})
+		case !exists:
+			problems = append(problems, Problem{Key: entry.Key, SHA256: entry.SHA256, Reason: "blob is missing"})
+		default:
+			continue
+		}
+		os.Remove(s.keyPath(entry.Key))
+	}
+
+	return problems, nil
+}
+
+// Prune removes entries that are too old or don't fit in the size limit, least
+// recently used first, and then deletes blobs no entry refers to. Blobs in
+// opts.Keep and their entries are left alone.
+func (s *Store) Prune(opts PruneOptions) (*PruneResult, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := s.blobs()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	// References keep a blob alive until its last entry is removed
+	refs := make(map[string]int)
+	var size int64
+	for _, entry := range entries {
+		if refs[entry.SHA256] == 0 {
+			size += blobs[entry.SHA256]
+		}
+		refs[entry.SHA256]++
+	}
+
+	result := &PruneResult{}
+	kept := make(map[string]bool)
+	cutoff := time.Now().Add(-opts.OlderThan)
+	for _, entry := range entries {
+		expired := opts.OlderThan > 0 && entry.LastUsed.Before(cutoff)
+		oversize := opts.MaxSize > 0 && size > opts.MaxSize
+		if !expired && !oversize {
+			continue
+		}
+		if opts.Keep[entry.SHA256] {
+			kept[entry.SHA256] = true
+			continue
+		}
+
+		if err := os.Remove(s.keyPath(entry.Key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, fmt.Errorf("removing cache entry: %w", err)
+		}
+		result.Entries++
+
+		refs[entry.SHA256]--
+		if refs[entry.SHA256] == 0 {
+			size -= blobs[entry.SHA256]
+		}
+	}
+
+	// Delete every blob without references, including ones left by failed runs
+	for sha, blobSize := range blobs {
+		if refs[sha] > 0 {
+			continue
+		}
+		if opts.Keep[sha] {
+			kept[sha] = true
+			continue
+		}
+		if err := os.Remove(s.BlobPath(sha)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, fmt.Errorf("removing blob: %w", err)
+		}
+		result.Blobs++
+		result.Freed += blobSize
+	}
+
+	result.Kept = len(kept)
+	s.removeStaleTemp()
+	return result, nil
+}
+
+// Clear removes every entry and blob
+func (s *Store) Clear() error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, dir := range []string{"keys", "blobs", "tmp"} {
+		if err := os.RemoveAll(filepath.Join(s.dir, dir)); err != nil {
+			return fmt.Errorf("clearing cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// entries reads every key file, sorted by key. Unreadable files are skipped.
+func (s *Store) entries() ([]*Entry, error) {
+	files, err := os.ReadDir(filepath.Join(s.dir, "keys"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache entries: %w", err)
+	}
+
+	var entries []*Entry
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		entry, err := s.readEntry(filepath.Join(s.dir, "keys", file.Name()))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+// blobs returns the size of every blob on disk by SHA256
+func (s *Store) blobs() (map[string]int64, error) {
+	blobs := make(map[string]int64)
+	root := filepath.Join(s.dir, "blobs")
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs[d.Name()] = info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading blobs: %w", err)
+	}
+	return blobs, nil
+}
+
+// readEntry reads one key file
+func (s *Store) readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if len(entry.SHA256) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid cache entry %s", path)
+	}
+	return &entry, nil
+}
+
+// writeEntry atomically writes the key file of an entry
+func (s *Store) writeEntry(entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cache entry: %w", err)
+	}
+	if err := writeFileAtomic(s.keyPath(entry.Key), data); err != nil {
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	return nil
+}
+
+// removeStaleTemp deletes unfinished downloads left behind by crashed runs
+func (s *Store) removeStaleTemp() {
+	tmpDir := filepath.Join(s.dir, "tmp")
+	files, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if info, err := file.Info(); err == nil && time.Since(info.ModTime()) > staleTempAge {
+			os.Remove(filepath.Join(tmpDir, file.Name()))
+		}
+	}
+}
+
+// lock takes the store's lock file, shared for readers and exclusive for
+// writers, and returns a function that releases it
+func (s *Store) lock(exclusive bool) (func(), error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, "lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening cache lock: %w", err)
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking cache: %w", err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place,
+// so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// hashFile returns the hex-encoded SHA256 of a file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
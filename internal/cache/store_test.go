@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// put stores content under key and fails the test on error
+func put(t *testing.T, s *Store, key, content string) (*Entry, string) {
+	t.Helper()
+
+	w, err := s.Create(key)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	entry, path, err := w.Commit()
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	return entry, path
+}
+
+// age makes an entry look unused for d
+func age(t *testing.T, s *Store, key string, d time.Duration) {
+	t.Helper()
+
+	entry, err := s.readEntry(s.keyPath(key))
+	if err != nil {
+		t.Fatalf("readEntry() error = %v", err)
+	}
+	entry.LastUsed = time.Now().Add(-d)
+	if err := s.writeEntry(entry); err != nil {
+		t.Fatalf("writeEntry() error = %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := New(t.TempDir())
+
+	t.Run("put and get", func(t *testing.T) {
+		entry, path := put(t, s, "github:a/b:abc", "mod content")
+
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != "mod content" {
+			t.Fatalf("blob = %q, %v", data, err)
+		}
+		if path != s.BlobPath(entry.SHA256) || entry.Size != int64(len("mod content")) {
+			t.Errorf("Commit() = %+v at %s", entry, path)
+		}
+
+		got, gotPath, ok := s.Get("github:a/b:abc")
+		if !ok || got.SHA256 != entry.SHA256 || gotPath != path {
+			t.Errorf("Get() = %+v, %s, %v", got, gotPath, ok)
+		}
+		if _, _, ok := s.Get("github:a/b:def"); ok {
+			t.Error("Get() found a key that was never stored")
+		}
+	})
+
+	t.Run("identical content shares a blob", func(t *testing.T) {
+		put(t, s, "url:https://mirror/mod.zip", "mod content")
+
+		stats, err := s.Stats()
+		if err != nil {
+			t.Fatalf("Stats() error = %v", err)
+		}
+		if stats.Entries != 2 || stats.Blobs != 1 || stats.Size != int64(len("mod content")) {
+			t.Errorf("Stats() = %+v, want 2 entries sharing 1 blob", stats)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		w, err := s.Create("portal:x:1.0.0")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		w.Write([]byte("partial"))
+		w.Abort()
+
+		if _, _, ok := s.Get("portal:x:1.0.0"); ok {
+			t.Error("Get() found an aborted download")
+		}
+		files, _ := os.ReadDir(filepath.Join(s.Dir(), "tmp"))
+		if len(files) != 0 {
+			t.Errorf("aborted download left %d temp files", len(files))
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		entry, path := put(t, s, "portal:y:1.0.0", "other content")
+		if problems, err := s.Verify(); err != nil || len(problems) != 0 {
+			t.Fatalf("Verify() = %v, %v, want no problems", problems, err)
+		}
+
+		if err := os.WriteFile(path, []byte("tampered"), 0644); err != nil {
+			t.Fatalf("Failed to tamper with blob: %v", err)
+		}
+		problems, err := s.Verify()
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if len(problems) != 2 || problems[0].SHA256 != entry.SHA256 || problems[1].Key != "portal:y:1.0.0" {
+			t.Errorf("Verify() = %+v, want the blob and its entry", problems)
+		}
+		if _, _, ok := s.Get("portal:y:1.0.0"); ok {
+			t.Error("Get() still finds an entry whose blob was corrupt")
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		put(t, s, "portal:big:1.0.0", strings.Repeat("x", 100))
+		age(t, s, "github:a/b:abc", 48*time.Hour)
+
+		// The shared blob survives while the mirror entry still uses it
+		result, err := s.Prune(PruneOptions{OlderThan: 24 * time.Hour})
+		if err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+		if result.Entries != 1 || result.Blobs != 0 {
+			t.Errorf("Prune(OlderThan) = %+v, want 1 entry and no blobs", result)
+		}
+
+		// Least recently used goes first when over the size limit
+		age(t, s, "url:https://mirror/mod.zip", time.Hour)
+		result, err = s.Prune(PruneOptions{MaxSize: 100})
+		if err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+		if result.Entries != 1 || result.Blobs != 1 || result.Freed != int64(len("mod content")) {
+			t.Errorf("Prune(MaxSize) = %+v, want the mirror entry and its blob", result)
+		}
+		if _, _, ok := s.Get("portal:big:1.0.0"); !ok {
+			t.Error("Prune(MaxSize) removed the most recently used entry")
+		}
+
+		// A blob an instance links to outlives its entry's age
+		linked, path := put(t, s, "portal:linked:1.0.0", "linked content")
+		age(t, s, "portal:linked:1.0.0", 48*time.Hour)
+		result, err = s.Prune(PruneOptions{OlderThan: 24 * time.Hour, Keep: map[string]bool{linked.SHA256: true}})
+		if err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+		if result.Entries != 0 || result.Blobs != 0 || result.Kept != 1 {
+			t.Errorf("Prune(Keep) = %+v, want the linked blob kept", result)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Prune(Keep) removed the linked blob: %v", err)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		if err := s.Clear(); err != nil {
+			t.Fatalf("Clear() error = %v", err)
+		}
+		entries, err := s.List()
+		if err != nil || len(entries) != 0 {
+			t.Errorf("List() after Clear() = %v, %v", entries, err)
+		}
+	})
+}
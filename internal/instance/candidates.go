@@ -0,0 +1,379 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/WhyIsSandwich/factctl/internal/resolve"
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+// InstalledSourceName is the candidate source used for mods already present in mods/
+const InstalledSourceName = "installed"
+
+// modRegistry adapts installed mods, the source registry and the mod portal
+// to the solver's Registry interface
+type modRegistry struct {
+	ctx  context.Context
+	mm   *ModManager
+	inst *Instance
+
+	// Lockfile from the previous run, used to spot new commits of a source
+	previous *Lockfile
+
+	// Mods whose installed copy should give way to newer versions
+	update map[string]bool
+}
+
+// Candidates returns every available version of a mod in order of preference:
+// the installed copy first, then each configured source in priority order, then
+// portal releases from newest to oldest. A mod pinned to a source only gets
+// candidates from that source, and keeps its installed copy only if the pinned
+// source offers the same version. For mods being updated, every candidate
+// that would be an update is preferred over the installed copy.
+func (r *modRegistry) Candidates(name string) ([]*solver.Candidate, error) {
+	pin := r.inst.Config.Mods.Pin[name]
+
+	// Copies of the mod in configured sources
+	var available []*solver.Candidate
+	for _, sourceName := range r.mm.sourcesProviding(r.inst, name) {
+		if pin != "" && sourceName != pin {
+			continue
+		}
+		info, err := r.mm.registryModInfo(name, sourceName)
+		if err != nil {
+			fmt.Printf("  → Warning: Ignoring '%s' from source '%s': %v\n", name, sourceName, err)
+			continue
+		}
+		c, err := candidateFromInfo(info, sourceName)
+		if err != nil {
+			fmt.Printf("  → Warning: Ignoring '%s' from source '%s': %v\n", name, sourceName, err)
+			continue
+		}
+		available = append(available, c)
+	}
+
+	if r.usesPortal(name) {
+		available = append(available, r.portalCandidates(name)...)
+	}
+
+	// The solver asks for one mod at a time, so look up what these versions
+	// need before it gets there
+	var deps []string
+	for _, c := range available {
+		for _, dep := range c.Dependencies {
+			if dep.IsRequired() {
+				deps = append(deps, dep.Name)
+			}
+		}
+	}
+	r.prefetchPortal(deps)
+
+	installed := r.installedCandidate(name)
+	if installed == nil || (pin != "" && !hasVersion(available, installed.Version)) {
+		return available, nil
+	}
+	if !r.update[name] {
+		return append([]*solver.Candidate{installed}, available...), nil
+	}
+
+	var updates, rest []*solver.Candidate
+	for _, c := range available {
+		if r.isUpdate(installed, c) {
+			updates = append(updates, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	return append(append(updates, installed), rest...), nil
+}
+
+// installedCandidate returns the installed copy of a mod as a candidate, or
+// nil if the mod isn't installed
+func (r *modRegistry) installedCandidate(name string) *solver.Candidate {
+	info := r.mm.installedModInfo(r.inst, name)
+	if info == nil {
+		return nil
+	}
+	c, err := candidateFromInfo(info, InstalledSourceName)
+	if err != nil {
+		fmt.Printf("  → Warning: Ignoring installed copy of '%s': %v\n", name, err)
+		return nil
+	}
+	return c
+}
+
+// isUpdate reports whether candidate would update the installed copy of a mod:
+// it is a newer version, or the same version from a newer commit of the source
+// the installed copy came from
+func (r *modRegistry) isUpdate(installed, candidate *solver.Candidate) bool {
+	switch candidate.Version.Compare(installed.Version) {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+
+	if r.previous == nil || candidate.Source == PortalSourceName {
+		return false
+	}
+	locked := r.previous.Mod(installed.Name)
+	if locked == nil || locked.Source != candidate.Source || locked.Revision == "" {
+		return false
+	}
+
+	r.mm.mu.RLock()
+	defer r.mm.mu.RUnlock()
+	revision := r.mm.sourceRevisions[candidate.Source]
+	return revision != "" && revision != locked.Revision
+}
+
+// usesPortal reports whether the portal is asked for a mod's releases: when
+// the mod is pinned to it, or when no configured source provides the mod
+func (r *modRegistry) usesPortal(name string) bool {
+	switch pin := r.inst.Config.Mods.Pin[name]; pin {
+	case PortalSourceName:
+		return true
+	case "":
+		return len(r.mm.sourcesProviding(r.inst, name)) == 0
+	default:
+		return false
+	}
+}
+
+// prefetchPortal lists the portal releases of mods in parallel, skipping base
+// game mods, mods that don't use the portal and ones already looked up.
+// Failures are left for portalCandidates to report.
+func (r *modRegistry) prefetchPortal(names []string) {
+	var lookups []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] || isBuiltinMod(name) || !r.usesPortal(name) {
+			continue
+		}
+		seen[name] = true
+
+		r.mm.mu.RLock()
+		_, cached := r.mm.portalReleases[name]
+		r.mm.mu.RUnlock()
+		if !cached {
+			lookups = append(lookups, name)
+		}
+	}
+
+	forEach(r.mm.workerCount(r.inst), lookups, func(_ int, name string) error {
+		_, err := r.mm.getPortalReleases(r.ctx, name)
+		return err
+	})
+}
+
+// portalCandidates returns the portal releases of a mod, newest first
+func (r *modRegistry) portalCandidates(name string) []*solver.Candidate {
+	releases, err := r.mm.getPortalReleases(r.ctx, name)
+	if err != nil {
+		fmt.Printf("  → Warning: Could not list portal releases for '%s': %v\n", name, err)
+	}
+
+	var portal []*solver.Candidate
+	for _, release := range releases {
+		c, err := candidateFromInfo(&ModInfo{
+			Name:            name,
+			Version:         release.Version,
+			Dependencies:    release.InfoJSON.Dependencies,
+			FactorioVersion: release.InfoJSON.FactorioVersion,
+		}, PortalSourceName)
+		if err != nil {
+			continue
+		}
+		portal = append(portal, c)
+	}
+	sort.SliceStable(portal, func(i, j int) bool {
+		return portal[i].Version.Compare(portal[j].Version) > 0
+	})
+	return portal
+}
+
+// hasVersion reports whether any candidate has the given version
+func hasVersion(candidates []*solver.Candidate, version solver.Version) bool {
+	for _, c := range candidates {
+		if c.Version.Compare(version) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reason explains why the solver's choice for a mod came from its source
+func (r *modRegistry) reason(c *solver.Candidate) string {
+	mods := r.inst.Config.Mods
+	if c.Source == InstalledSourceName {
+		return "already installed"
+	}
+	if pin := mods.Pin[c.Name]; pin != "" {
+		return "pinned in mods.pin"
+	}
+
+	if c.Source == PortalSourceName {
+		return "not in any configured source"
+	}
+
+	sources := r.mm.sourcesProviding(r.inst, c.Name)
+
+	position := 0
+	for i, sourceName := range sources {
+		if sourceName == c.Source {
+			position = i
+		}
+	}
+	switch {
+	case len(sources) == 1:
+		return "only source providing it"
+	case position > 0:
+		return fmt.Sprintf("no compatible version in preferred %s", quoteNames(sources[:position]))
+	case slices.Contains(mods.Priority, c.Source):
+		return fmt.Sprintf("preferred over %s by mods.priority", quoteNames(sources[1:]))
+	default:
+		return fmt.Sprintf("preferred over %s by source name order", quoteNames(sources[1:]))
+	}
+}
+
+// quoteNames formats source names as a quoted, comma-separated list
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + name + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// candidateFromInfo converts mod metadata into a solver candidate
+func candidateFromInfo(info *ModInfo, source string) (*solver.Candidate, error) {
+	version, err := solver.ParseVersion(info.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	deps, err := solver.ParseDependencies(info.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &solver.Candidate{
+		Name:            info.Name,
+		Version:         version,
+		FactorioVersion: info.FactorioVersion,
+		Dependencies:    deps,
+		Source:          source,
+	}, nil
+}
+
+// installedModInfo returns the info of an installed mod, or nil if it isn't installed
+func (mm *ModManager) installedModInfo(inst *Instance, modName string) *ModInfo {
+	modDir := filepath.Join(inst.Dir, "mods")
+
+	// Local filesystem mods are symlinked directories named after the mod
+	if info, err := mm.readModInfoFromDirectory(filepath.Join(modDir, modName)); err == nil && info.Name == modName {
+		return info
+	}
+
+	for _, path := range installedModFiles(inst, modName) {
+		if info, err := mm.getModInfo(path); err == nil && info.Name == modName {
+			return info
+		}
+	}
+
+	return nil
+}
+
+// installedModFiles returns the zip files of every installed version of a mod
+func installedModFiles(inst *Instance, modName string) []string {
+	pattern := filepath.Join(inst.Dir, "mods", fmt.Sprintf("%s_*.zip", modName))
+	matches, _ := filepath.Glob(pattern)
+
+	// The glob also matches mods whose names merely start with modName_
+	var files []string
+	for _, match := range matches {
+		version := filepath.Base(match)
+		version = version[len(modName)+1 : len(version)-len(".zip")]
+		if _, err := solver.ParseVersion(version); err == nil {
+			files = append(files, match)
+		}
+	}
+	return files
+}
+
+// removeOtherVersions deletes installed versions of a mod other than keepPath
+func removeOtherVersions(inst *Instance, modName, keepPath string) error {
+	paths := installedModFiles(inst, modName)
+	// A linked local folder is another version too
+	linkPath := filepath.Join(inst.Dir, "mods", modName)
+	if fi, err := os.Lstat(linkPath); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		paths = append(paths, linkPath)
+	}
+
+	for _, path := range paths {
+		if path == keepPath {
+			continue
+		}
+		fmt.Printf("  → Removing previous version %s\n", filepath.Base(path))
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing previous version: %w", err)
+		}
+	}
+	return nil
+}
+
+// sourcesProviding returns the configured sources that contain a mod, most
+// preferred first
+func (mm *ModManager) sourcesProviding(inst *Instance, modName string) []string {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	var sources []string
+	for _, sourceName := range inst.Config.Mods.SourceOrder() {
+		if _, ok := mm.sourceRegistry[modName][sourceName]; ok {
+			sources = append(sources, sourceName)
+		}
+	}
+	return sources
+}
+
+// registryModInfo returns the info of a mod in the source registry
+func (mm *ModManager) registryModInfo(modName, sourceName string) (*ModInfo, error) {
+	mm.mu.RLock()
+	entry, ok := mm.sourceRegistry[modName][sourceName]
+	mm.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("mod '%s' not found in source '%s'", modName, sourceName)
+	}
+	return entry.info, nil
+}
+
+// getPortalReleases lists every release of a mod on the portal, including the
+// dependencies of each release. Unknown mods return no releases.
+func (mm *ModManager) getPortalReleases(ctx context.Context, modName string) ([]resolve.PortalRelease, error) {
+	mm.mu.RLock()
+	releases, ok := mm.portalReleases[modName]
+	mm.mu.RUnlock()
+	if ok {
+		return releases, nil
+	}
+
+	releases, err := mm.portal.Releases(ctx, modName)
+	if err != nil && !errors.Is(err, resolve.ErrModNotFound) {
+		return nil, err
+	}
+
+	mm.mu.Lock()
+	mm.portalReleases[modName] = releases
+	mm.mu.Unlock()
+
+	return releases, nil
+}
@@ -0,0 +1,306 @@
+package instance
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+func TestModRegistry(t *testing.T) {
+	// Create temporary directory for testing
+	tmpDir, err := os.MkdirTemp("", "factctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1",
+			Mods: ModsConfig{
+				Sources: map[string]string{"mods": "gh:test/mods", "zz-extra": "gh:test/extra"},
+			},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	for _, dir := range []string{"mods", "config"} {
+		if err := os.MkdirAll(filepath.Join(inst.Dir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s directory: %v", dir, err)
+		}
+	}
+
+	// Installed copy of test-mod, plus a mod whose name shares its prefix
+	for _, info := range []*ModInfo{
+		{Name: "test-mod", Version: "1.0.0", FactorioVersion: "1.1"},
+		{Name: "test-mod_extras", Version: "1.0.0", FactorioVersion: "1.1"},
+	} {
+		path := filepath.Join(inst.Dir, "mods", info.Name+"_"+info.Version+".zip")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("Failed to create mod file: %v", err)
+		}
+		if err := createTestModZip(f, info); err != nil {
+			f.Close()
+			t.Fatalf("Failed to create test mod zip: %v", err)
+		}
+		f.Close()
+	}
+
+	// A source repository holding test-mod and dep-mod in subfolders
+	archive := filepath.Join(tmpDir, "repo.zip")
+	createTestRepoZip(t, archive, []*ModInfo{
+		{
+			Name:            "test-mod",
+			Version:         "1.1.0",
+			FactorioVersion: "1.1",
+			Dependencies:    []string{"base >= 1.1", "dep-mod >= 2.0", "? optional-mod"},
+		},
+		{Name: "dep-mod", Version: "1.0.0", FactorioVersion: "1.1"},
+	})
+	entries, err := indexSourceArchive(archive, "")
+	if err != nil {
+		t.Fatalf("indexSourceArchive() error = %v", err)
+	}
+
+	manager := NewModManager(tmpDir)
+	for name, entry := range entries {
+		manager.sourceRegistry[name] = map[string]*registryEntry{"mods": entry}
+	}
+
+	// The portal is unreachable with a cancelled context, so only local candidates remain
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	registry := &modRegistry{ctx: ctx, mm: manager, inst: inst}
+
+	t.Run("candidates", func(t *testing.T) {
+		candidates, err := registry.Candidates("test-mod")
+		if err != nil {
+			t.Fatalf("Candidates() error = %v", err)
+		}
+		if len(candidates) != 2 {
+			t.Fatalf("Candidates() returned %d candidates, want 2", len(candidates))
+		}
+		if candidates[0].Source != InstalledSourceName || candidates[0].Version.String() != "1.0.0" {
+			t.Errorf("Candidates()[0] = %s from %s, want installed 1.0.0", candidates[0], candidates[0].Source)
+		}
+		if candidates[1].Source != "mods" || len(candidates[1].Dependencies) != 3 {
+			t.Errorf("Candidates()[1] = %s from %s with %d dependencies", candidates[1], candidates[1].Source, len(candidates[1].Dependencies))
+		}
+	})
+
+	t.Run("installed files", func(t *testing.T) {
+		files := installedModFiles(inst, "test-mod")
+		if len(files) != 1 || filepath.Base(files[0]) != "test-mod_1.0.0.zip" {
+			t.Errorf("installedModFiles() = %v, want only test-mod_1.0.0.zip", files)
+		}
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		if err := manager.installModFromRegistrySource(inst, "dep-mod", "mods"); err != nil {
+			t.Fatalf("installModFromRegistrySource() error = %v", err)
+		}
+
+		modPath := filepath.Join(inst.Dir, "mods", "dep-mod_1.0.0.zip")
+		zr, err := zip.OpenReader(modPath)
+		if err != nil {
+			t.Fatalf("Failed to open installed mod: %v", err)
+		}
+		defer zr.Close()
+
+		var names []string
+		for _, file := range zr.File {
+			names = append(names, file.Name)
+		}
+		if strings.Join(names, ",") != "dep-mod_1.0.0/info.json,dep-mod_1.0.0/data.lua" {
+			t.Errorf("installed mod contains %v, want only dep-mod's folder", names)
+		}
+
+		hash, err := hashFile(modPath)
+		if err != nil {
+			t.Fatalf("hashFile() error = %v", err)
+		}
+		if locked := manager.lockEntries["dep-mod"]; locked == nil || locked.SHA256 != hash || locked.Source != "mods" {
+			t.Errorf("lock entry = %+v, want source mods with sha256 %s", locked, hash)
+		}
+		cached, _, err := manager.extractToCache(entries["dep-mod"])
+		if err != nil || cached.SHA256 != hash {
+			t.Errorf("extractToCache() = %+v, %v, want sha256 %s", cached, err, hash)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		updating := &modRegistry{ctx: ctx, mm: manager, inst: inst, update: map[string]bool{"test-mod": true}}
+		candidates, err := updating.Candidates("test-mod")
+		if err != nil {
+			t.Fatalf("Candidates() error = %v", err)
+		}
+		if len(candidates) != 2 || candidates[0].Source != "mods" || candidates[1].Source != InstalledSourceName {
+			t.Errorf("Candidates() = %v, want the newer source version before the installed copy", candidates)
+		}
+		if c := updating.newestUpdate("test-mod"); c == nil || c.Version.String() != "1.1.0" {
+			t.Errorf("newestUpdate() = %v, want test-mod 1.1.0", c)
+		}
+
+		// The same version counts as an update only from a newer commit of its source
+		installed := updating.installedCandidate("test-mod")
+		same := *installed
+		same.Source = "mods"
+		manager.sourceRevisions["mods"] = "def456"
+		defer delete(manager.sourceRevisions, "mods")
+		for _, tt := range []struct {
+			revision string
+			want     bool
+		}{
+			{"abc123", true},
+			{"def456", false},
+		} {
+			updating.previous = &Lockfile{Mods: []LockedMod{{Name: "test-mod", Version: "1.0.0", Source: "mods", Revision: tt.revision}}}
+			if got := updating.isUpdate(installed, &same); got != tt.want {
+				t.Errorf("isUpdate() with locked revision %s = %v, want %v", tt.revision, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("priority and pins", func(t *testing.T) {
+		// A second source offers the same test-mod release
+		manager.sourceRegistry["test-mod"]["zz-extra"] = entries["test-mod"]
+		defer delete(manager.sourceRegistry["test-mod"], "zz-extra")
+		defer func() { inst.Config.Mods.Priority, inst.Config.Mods.Pin = nil, nil }()
+
+		sources := func() string {
+			candidates, err := registry.Candidates("test-mod")
+			if err != nil {
+				t.Fatalf("Candidates() error = %v", err)
+			}
+			var names []string
+			for _, c := range candidates {
+				names = append(names, c.Source)
+			}
+			return strings.Join(names, ",")
+		}
+
+		if got := sources(); got != "installed,mods,zz-extra" {
+			t.Errorf("Candidates() sources = %s, want name order", got)
+		}
+		c := &solver.Candidate{Name: "test-mod", Source: "mods"}
+		if got := registry.reason(c); got != "preferred over 'zz-extra' by source name order" {
+			t.Errorf("reason() = %q", got)
+		}
+
+		inst.Config.Mods.Priority = []string{"zz-extra"}
+		if got := sources(); got != "installed,zz-extra,mods" {
+			t.Errorf("Candidates() sources = %s, want zz-extra first", got)
+		}
+		if got := registry.reason(c); got != "no compatible version in preferred 'zz-extra'" {
+			t.Errorf("reason() = %q", got)
+		}
+
+		// The installed 1.0.0 isn't what the pinned source offers
+		inst.Config.Mods.Pin = map[string]string{"test-mod": "mods"}
+		if got := sources(); got != "mods" {
+			t.Errorf("Candidates() sources = %s, want only the pinned source", got)
+		}
+		if got := registry.reason(c); got != "pinned in mods.pin" {
+			t.Errorf("reason() = %q", got)
+		}
+	})
+
+	t.Run("portal lookups", func(t *testing.T) {
+		defer func() { inst.Config.Mods.Pin = nil }()
+		inst.Config.Mods.Pin = map[string]string{"dep-mod": PortalSourceName}
+
+		for name, want := range map[string]bool{"test-mod": false, "dep-mod": true, "other-mod": true} {
+			if got := registry.usesPortal(name); got != want {
+				t.Errorf("usesPortal(%s) = %v, want %v", name, got, want)
+			}
+		}
+
+		// Mods from a source, base game mods and cached lookups never reach the portal
+		manager.portalReleases["other-mod"] = nil
+		defer delete(manager.portalReleases, "other-mod")
+		live, cancelLive := context.WithCancel(context.Background())
+		defer cancelLive()
+		prefetching := &modRegistry{ctx: live, mm: manager, inst: inst}
+		prefetching.prefetchPortal([]string{"test-mod", "base", "other-mod"})
+		if len(manager.portalReleases) != 1 {
+			t.Errorf("portalReleases = %v, want only the cached other-mod", manager.portalReleases)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		// Only the source copy is acceptable, and it needs a dep-mod nobody provides
+		_, err := solver.Solve(&solver.Problem{
+			Roots:           []string{"test-mod"},
+			FactorioVersion: "1.1",
+			Builtin:         builtinModNames,
+			Registry: &constrainedRegistry{
+				Registry: registry,
+				exclude:  InstalledSourceName,
+			},
+		})
+
+		var conflict *solver.ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("Solve() error = %v, want ConflictError", err)
+		}
+		if !strings.Contains(err.Error(), "test-mod needs dep-mod >= 2.0") {
+			t.Errorf("Solve() error = %q, want it to explain the dep-mod constraint", err)
+		}
+	})
+}
+
+// createTestRepoZip writes a repository archive with one folder per mod
+func createTestRepoZip(t *testing.T, path string, mods []*ModInfo) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create repository zip: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, info := range mods {
+		w, err := zw.Create("repo-abc/" + info.Name + "/info.json")
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			t.Fatalf("Failed to write info.json: %v", err)
+		}
+		if _, err := zw.Create("repo-abc/" + info.Name + "/data.lua"); err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close repository zip: %v", err)
+	}
+}
+
+// constrainedRegistry hides candidates from one source
+type constrainedRegistry struct {
+	solver.Registry
+	exclude string
+}
+
+func (r *constrainedRegistry) Candidates(name string) ([]*solver.Candidate, error) {
+	candidates, err := r.Registry.Candidates(name)
+	if err != nil {
+		return nil, err
+	}
+	var out []*solver.Candidate
+	for _, c := range candidates {
+		if c.Source != r.exclude {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
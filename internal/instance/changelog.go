@@ -0,0 +1,49 @@
+package instance
+
+import (
+	"strings"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+// changelogBetween returns the sections of a Factorio changelog.txt for
+// versions newer than from and no newer than to, in the order they appear.
+// Separator and date lines are dropped; sections with unparseable versions
+// are skipped.
+func changelogBetween(text, from, to string) string {
+	low, err := solver.ParseVersion(from)
+	if err != nil {
+		return ""
+	}
+	high, err := solver.ParseVersion(to)
+	if err != nil {
+		return ""
+	}
+
+	var out []string
+	include := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if version, ok := strings.CutPrefix(trimmed, "Version:"); ok {
+			v, err := solver.ParseVersion(strings.TrimSpace(version))
+			include = err == nil && v.Compare(low) > 0 && v.Compare(high) <= 0
+			if include {
+				out = append(out, trimmed)
+			}
+			continue
+		}
+
+		// Sections are separated by a line of dashes
+		if trimmed != "" && strings.Trim(trimmed, "-") == "" {
+			include = false
+			continue
+		}
+
+		if include && trimmed != "" && !strings.HasPrefix(trimmed, "Date:") {
+			out = append(out, strings.TrimRight(line, " \t"))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
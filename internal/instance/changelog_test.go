@@ -0,0 +1,62 @@
+package instance
+
+import "testing"
+
+func TestChangelogBetween(t *testing.T) {
+	changelog := `---------------------------------------------------------------------------------------------------
+Version: 1.2.0
+Date: 2024-03-01
+  Features:
+    - Added a new building
+---------------------------------------------------------------------------------------------------
+Version: 1.1.1
+Date: 2024-02-01
+  Bugfixes:
+    - Fixed a crash when loading saves
+---------------------------------------------------------------------------------------------------
+Version: 1.1.0
+Date: 2024-01-01
+  Features:
+    - Initial release
+`
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{
+			name: "one release",
+			from: "1.1.0",
+			to:   "1.1.1",
+			want: "Version: 1.1.1\n  Bugfixes:\n    - Fixed a crash when loading saves",
+		},
+		{
+			name: "several releases",
+			from: "1.1.0",
+			to:   "1.2.0",
+			want: "Version: 1.2.0\n  Features:\n    - Added a new building\n" +
+				"Version: 1.1.1\n  Bugfixes:\n    - Fixed a crash when loading saves",
+		},
+		{
+			name: "same version",
+			from: "1.2.0",
+			to:   "1.2.0",
+			want: "",
+		},
+		{
+			name: "invalid version",
+			from: "latest",
+			to:   "1.2.0",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changelogBetween(changelog, tt.from, tt.to); got != tt.want {
+				t.Errorf("changelogBetween() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 
 	"github.com/WhyIsSandwich/factctl/internal/jsonc"
+	"github.com/WhyIsSandwich/factctl/internal/modsettings"
 )
 
 // Config represents an instance configuration
@@ -23,6 +26,10 @@ type Config struct {
 	// Port to run the server on (if running as server)
 	Port int `json:"port,omitempty"`
 
+	// RCON port and password, for sending console commands to a running server
+	RCONPort     int    `json:"rcon_port,omitempty"`
+	RCONPassword string `json:"rcon_password,omitempty"`
+
 	// Whether to run in headless mode
 	Headless bool `json:"headless,omitempty"`
 
@@ -49,11 +56,134 @@ type ModsConfig struct {
 	// List of mods to enable
 	Enabled []string `json:"enabled"`
 
+	// Mods to keep installed but disabled in mod-list.json
+	Disabled []string `json:"disabled,omitempty"`
+
+	// Whether to install the optional dependencies of every mod
+	InstallOptional bool `json:"install_optional,omitempty"`
+
+	// Mods whose optional dependencies are installed
+	OptionalFor []string `json:"optional_for,omitempty"`
+
 	// Map of mod names to their versions/sources
 	Sources map[string]string `json:"sources"`
 
-	// Additional mod settings
+	// Source names in order of preference when a mod is in several sources.
+	// Sources not listed follow in name order; the portal always comes last.
+	Priority []string `json:"priority,omitempty"`
+
+	// Per-mod source overrides: mod name -> source name, or "portal"
+	Pin map[string]string `json:"pin,omitempty"`
+
+	// Mod settings written to mod-settings.dat, either grouped by scope
+	// ("startup", "runtime-global", "runtime-per-user") or given directly,
+	// in which case they are startup settings
 	Settings map[string]interface{} `json:"settings,omitempty"`
+
+	// Maximum number of sources and mods to download at once (defaults to 4)
+	Workers int `json:"workers,omitempty"`
+
+	// How mod files are placed from the download cache: auto (default),
+	// reflink, hardlink, symlink or copy
+	Link string `json:"link,omitempty"`
+}
+
+// SourceOrder returns the configured source names from most to least preferred
+func (m *ModsConfig) SourceOrder() []string {
+	order := make([]string, 0, len(m.Sources))
+	listed := make(map[string]bool)
+	for _, name := range m.Priority {
+		if _, ok := m.Sources[name]; ok && !listed[name] {
+			order = append(order, name)
+			listed[name] = true
+		}
+	}
+
+	var rest []string
+	for name := range m.Sources {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(order, rest...)
+}
+
+// EnableMod adds a mod to the enabled list and reports whether it was added.
+// The mod is taken off the disabled list.
+func (m *ModsConfig) EnableMod(name string) bool {
+	m.Disabled = removeName(m.Disabled, name)
+	if slices.Contains(m.Enabled, name) {
+		return false
+	}
+	m.Enabled = append(m.Enabled, name)
+	return true
+}
+
+// DisableMod removes a mod from the enabled list and reports whether it was listed
+func (m *ModsConfig) DisableMod(name string) bool {
+	i := slices.Index(m.Enabled, name)
+	if i < 0 {
+		return false
+	}
+	m.Enabled = slices.Delete(m.Enabled, i, i+1)
+	return true
+}
+
+// KeepDisabled moves a mod from the enabled list to the disabled list
+func (m *ModsConfig) KeepDisabled(name string) {
+	m.DisableMod(name)
+	if !slices.Contains(m.Disabled, name) {
+		m.Disabled = append(m.Disabled, name)
+	}
+}
+
+// WantsOptional reports whether the optional dependencies of a mod are installed
+func (m *ModsConfig) WantsOptional(name string) bool {
+	return m.InstallOptional || slices.Contains(m.OptionalFor, name)
+}
+
+// removeName returns names without name
+func removeName(names []string, name string) []string {
+	if i := slices.Index(names, name); i >= 0 {
+		return slices.Delete(names, i, i+1)
+	}
+	return names
+}
+
+// ScopedSettings returns the configured mod settings grouped by scope
+func (m *ModsConfig) ScopedSettings() (map[string]map[string]any, error) {
+	scoped := make(map[string]map[string]any)
+	add := func(scope, name string, value any) {
+		if scoped[scope] == nil {
+			scoped[scope] = make(map[string]any)
+		}
+		scoped[scope][name] = value
+	}
+
+	for key, value := range m.Settings {
+		if !isSettingScope(key) {
+			add(modsettings.Startup, key, value)
+			continue
+		}
+		settings, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("settings for scope %q must be an object", key)
+		}
+		for name, v := range settings {
+			add(key, name, v)
+		}
+	}
+	return scoped, nil
+}
+
+// LinkMode returns how mod files are placed from the download cache
+func (m *ModsConfig) LinkMode() string {
+	if m.Link == "" {
+		return LinkAuto
+	}
+	return m.Link
 }
 
 // ServerConfig contains server-specific settings
@@ -83,6 +213,11 @@ type ServerConfig struct {
 	Settings map[string]interface{} `json:"settings,omitempty"`
 }
 
+// ConfigPath returns the path of an instance's instance.json
+func ConfigPath(inst *Instance) string {
+	return filepath.Join(inst.Dir, "config", "instance.json")
+}
+
 // LoadConfig loads an instance configuration from a file
 func LoadConfig(path string) (*Config, error) {
 	f, err := os.Open(path)
@@ -145,7 +280,7 @@ func (c *Config) validate() error {
 	}
 
 	nonBuiltinMods := []string{}
-	for _, mod := range c.Mods.Enabled {
+	for _, mod := range append(slices.Clone(c.Mods.Enabled), c.Mods.Disabled...) {
 		if !builtinMods[mod] {
 			nonBuiltinMods = append(nonBuiltinMods, mod)
 		}
@@ -155,6 +290,46 @@ func (c *Config) validate() error {
 		return fmt.Errorf("mod sources are required for non-built-in mods: %v", nonBuiltinMods)
 	}
 
+	for _, mod := range c.Mods.Disabled {
+		if mod == "base" {
+			return fmt.Errorf("the base mod cannot be disabled")
+		}
+		if slices.Contains(c.Mods.Enabled, mod) {
+			return fmt.Errorf("mod %q is both enabled and disabled", mod)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range c.Mods.Priority {
+		if _, ok := c.Mods.Sources[name]; !ok {
+			return fmt.Errorf("priority lists unknown source %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("priority lists source %q more than once", name)
+		}
+		seen[name] = true
+	}
+
+	switch c.Mods.Link {
+	case "", LinkAuto, LinkReflink, LinkHardlink, LinkSymlink, LinkCopy:
+	default:
+		return fmt.Errorf("unknown link mode %q (use auto, reflink, hardlink, symlink or copy)", c.Mods.Link)
+	}
+
+	if _, err := c.Mods.ScopedSettings(); err != nil {
+		return err
+	}
+
+	for mod, source := range c.Mods.Pin {
+		if _, ok := c.Mods.Sources[source]; !ok && source != PortalSourceName {
+			return fmt.Errorf("mod %q is pinned to unknown source %q", mod, source)
+		}
+	}
+
+	if c.RCONPort > 0 && c.RCONPassword == "" {
+		return fmt.Errorf("rcon_password is required with rcon_port")
+	}
+
 	if c.Server != nil {
 		if err := c.Server.validate(); err != nil {
 			return fmt.Errorf("invalid server config: %w", err)
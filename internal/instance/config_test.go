@@ -3,6 +3,7 @@ package instance
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -78,6 +79,80 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "priority and pins",
+			cfg: Config{
+				Name:    "test-instance",
+				Version: "1.1.87",
+				Mods: ModsConfig{
+					Enabled:  []string{"mod1"},
+					Sources:  map[string]string{"angels": "gh:a/b", "seablock": "gh:c/d"},
+					Priority: []string{"seablock", "angels"},
+					Pin:      map[string]string{"mod1": "angels", "mod2": "portal"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "priority with unknown source",
+			cfg: Config{
+				Name:    "test-instance",
+				Version: "1.1.87",
+				Mods: ModsConfig{
+					Sources:  map[string]string{"angels": "gh:a/b"},
+					Priority: []string{"seablock"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pin to unknown source",
+			cfg: Config{
+				Name:    "test-instance",
+				Version: "1.1.87",
+				Mods: ModsConfig{
+					Sources: map[string]string{"angels": "gh:a/b"},
+					Pin:     map[string]string{"mod1": "seablock"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown link mode",
+			cfg: Config{
+				Name:    "test-instance",
+				Version: "1.1.87",
+				Mods:    ModsConfig{Link: "junction"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mod both enabled and disabled",
+			cfg: Config{
+				Name:    "test-instance",
+				Version: "1.1.87",
+				Mods:    ModsConfig{Enabled: []string{"quality"}, Disabled: []string{"quality"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "disabled builtin mod",
+			cfg: Config{
+				Name:    "test-instance",
+				Version: "2.0",
+				Mods:    ModsConfig{Enabled: []string{"base"}, Disabled: []string{"quality"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "settings scope that is not an object",
+			cfg: Config{
+				Name:    "test-instance",
+				Version: "1.1.87",
+				Mods:    ModsConfig{Settings: map[string]interface{}{"startup": true}},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -158,4 +233,21 @@ func TestConfigLoadSave(t *testing.T) {
 	if loaded.Server.MaxPlayers != testCfg.Server.MaxPlayers {
 		t.Errorf("LoadConfig() got MaxPlayers = %v, want %v", loaded.Server.MaxPlayers, testCfg.Server.MaxPlayers)
 	}
-}
\ No newline at end of file
+}
+
+func TestSourceOrder(t *testing.T) {
+	mods := ModsConfig{
+		Sources: map[string]string{
+			"angels":   "gh:a/b",
+			"bobs":     "gh:c/d",
+			"seablock": "gh:e/f",
+			"extra":    "gh:g/h",
+		},
+		Priority: []string{"seablock", "angels"},
+	}
+
+	got := strings.Join(mods.SourceOrder(), ",")
+	if want := "seablock,angels,bobs,extra"; got != want {
+		t.Errorf("SourceOrder() = %s, want %s", got, want)
+	}
+}
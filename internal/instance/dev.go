@@ -0,0 +1,134 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalMods returns the directories of the local mods linked into an instance,
+// keyed by mod name. Links are resolved so the directories can be watched.
+func LocalMods(inst *Instance) (map[string]string, error) {
+	modDir := filepath.Join(inst.Dir, "mods")
+	entries, err := os.ReadDir(modDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading mods directory: %w", err)
+	}
+
+	mods := make(map[string]string)
+	for _, entry := range entries {
+		// Zips symlinked from the download cache are not local mods
+		if entry.Type()&os.ModeSymlink == 0 || strings.HasSuffix(entry.Name(), ".zip") {
+			continue
+		}
+
+		dir, err := filepath.EvalSymlinks(filepath.Join(modDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("resolving local mod '%s': %w", entry.Name(), err)
+		}
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			continue
+		}
+		mods[entry.Name()] = dir
+	}
+	return mods, nil
+}
+
+// ValidateLocalMod checks that the info.json of a local mod can be loaded by
+// the instance's Factorio version and names the mod it is linked as
+func ValidateLocalMod(inst *Instance, name, dir string) (*ModInfo, error) {
+	info, err := readModInfoFile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Name != name {
+		return nil, fmt.Errorf("info.json names the mod %q, but it is linked as %q", info.Name, name)
+	}
+	if err := checkModInfo(info); err != nil {
+		return nil, err
+	}
+	if info.FactorioVersion != "" && !isVersionCompatible(inst.Config.Version, info.FactorioVersion) {
+		return nil, fmt.Errorf("mod requires Factorio %s but instance uses %s", info.FactorioVersion, inst.Config.Version)
+	}
+
+	return info, nil
+}
+
+// WatchLocalMods watches local mod directories, keyed by mod name, until ctx
+// is done. Once no file has changed for the debounce period, onChange is called
+// with the names of the mods that changed.
+func WatchLocalMods(ctx context.Context, mods map[string]string, debounce time.Duration, onChange func(names []string)) error {
+	dirs := make([]string, 0, len(mods))
+	for _, dir := range mods {
+		dirs = append(dirs, dir)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes := make(chan string, 64)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- watchDirs(ctx, dirs, changes)
+	}()
+
+	pending := make(map[string]bool)
+	var settled <-chan time.Time
+	for {
+		select {
+		case path := <-changes:
+			if name := changedMod(mods, path); name != "" {
+				pending[name] = true
+				settled = time.After(debounce)
+			}
+		case <-settled:
+			names := make([]string, 0, len(pending))
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			pending = make(map[string]bool)
+			settled = nil
+			onChange(names)
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// changedMod returns the mod a changed path belongs to, or "" for paths that
+// don't count, such as version control data and editor swap files
+func changedMod(mods map[string]string, path string) string {
+	for name, dir := range mods {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel != "." {
+			for _, part := range strings.Split(rel, string(filepath.Separator)) {
+				if strings.HasPrefix(part, ".") || strings.HasPrefix(part, "#") || strings.HasSuffix(part, "~") {
+					return ""
+				}
+			}
+		}
+		return name
+	}
+	return ""
+}
+
+// IsLoadError reports whether a log entry reports an error, such as a mod
+// failing to load. Factorio writes errors to stdout as "<uptime> Error ...".
+func IsLoadError(entry LogEntry) bool {
+	if entry.Level == LogError {
+		return true
+	}
+	msg := strings.TrimLeft(entry.Message, " 0123456789.")
+	return strings.HasPrefix(msg, "Error") || strings.Contains(msg, "Failed to load mods")
+}
@@ -0,0 +1,148 @@
+package instance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testLocalMod creates a local mod directory linked into an instance
+func testLocalMod(t *testing.T, inst *Instance, name, info string) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create mod directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "info.json"), []byte(info), 0644); err != nil {
+		t.Fatalf("Failed to write info.json: %v", err)
+	}
+	if err := os.Symlink(dir, filepath.Join(inst.Dir, "mods", name)); err != nil {
+		t.Fatalf("Failed to link mod: %v", err)
+	}
+	return dir
+}
+
+func TestLocalMods(t *testing.T) {
+	inst := &Instance{Config: &Config{Name: "dev", Version: "2.0.28"}, Dir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(inst.Dir, "mods"), 0755); err != nil {
+		t.Fatalf("Failed to create mods directory: %v", err)
+	}
+
+	dir := testLocalMod(t, inst, "my-mod", `{"name": "my-mod", "version": "0.1.0", "factorio_version": "2.0"}`)
+	testLocalMod(t, inst, "renamed", `{"name": "other", "version": "0.1.0"}`)
+	testLocalMod(t, inst, "short-version", `{"name": "short-version", "version": "0.1"}`)
+	testLocalMod(t, inst, "old", `{"name": "old", "version": "0.1.0", "factorio_version": "1.1"}`)
+	testLocalMod(t, inst, "bad-dependency", `{"name": "bad-dependency", "version": "0.1.0", "dependencies": ["base >= two"]}`)
+
+	// Zips linked from the cache are not local mods
+	zip := filepath.Join(t.TempDir(), "flib_0.15.0.zip")
+	os.WriteFile(zip, nil, 0644)
+	if err := os.Symlink(zip, filepath.Join(inst.Dir, "mods", "flib_0.15.0.zip")); err != nil {
+		t.Fatalf("Failed to link zip: %v", err)
+	}
+
+	mods, err := LocalMods(inst)
+	if err != nil {
+		t.Fatalf("LocalMods() error = %v", err)
+	}
+	resolved, _ := filepath.EvalSymlinks(dir)
+	if len(mods) != 5 || mods["my-mod"] != resolved {
+		t.Errorf("LocalMods() = %v, want 5 mods with my-mod in %s", mods, resolved)
+	}
+
+	for name, dir := range mods {
+		_, err := ValidateLocalMod(inst, name, dir)
+		if wantErr := name != "my-mod"; (err != nil) != wantErr {
+			t.Errorf("ValidateLocalMod(%s) error = %v, want error %v", name, err, wantErr)
+		}
+	}
+}
+
+func TestWatchLocalMods(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	mods := map[string]string{"mod-a": dirA, "mod-b": dirB}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changed := make(chan []string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchLocalMods(ctx, mods, 100*time.Millisecond, func(names []string) {
+			changed <- names
+		})
+	}()
+
+	// Give the watcher time to start; polling needs a first scan to compare against
+	time.Sleep(2 * pollInterval)
+
+	os.WriteFile(filepath.Join(dirA, ".control.lua.swp"), []byte("swap"), 0644)
+	os.MkdirAll(filepath.Join(dirA, "prototypes"), 0755)
+	os.WriteFile(filepath.Join(dirA, "prototypes", "item.lua"), []byte("data:extend({})"), 0644)
+	os.WriteFile(filepath.Join(dirB, "control.lua"), []byte("script.on_init(function() end)"), 0644)
+
+	var got []string
+	deadline := time.After(4 * time.Second)
+	for len(got) < 2 {
+		select {
+		case names := <-changed:
+			got = append(got, names...)
+		case <-deadline:
+			t.Fatalf("WatchLocalMods() reported %v, want mod-a and mod-b", got)
+		}
+	}
+	if !reflect.DeepEqual(got, []string{"mod-a", "mod-b"}) {
+		t.Errorf("WatchLocalMods() reported %v, want [mod-a mod-b] at once", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchLocalMods() error = %v", err)
+	}
+}
+
+func TestPollDirs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.lua")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan string, 8)
+	go pollDirs(ctx, []string{dir}, 20*time.Millisecond, changes)
+	time.Sleep(50 * time.Millisecond)
+
+	os.WriteFile(path, []byte("data:extend({})"), 0644)
+	select {
+	case got := <-changes:
+		if got != path {
+			t.Errorf("pollDirs() reported %s, want %s", got, path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pollDirs() reported no change")
+	}
+}
+
+func TestIsLoadError(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"   1.063 Error ModManager.cpp:1133: Error in assignID: item with name 'foo' does not exist.", true},
+		{"   0.950 Failed to load mods: __my-mod__/data.lua:3: unexpected symbol near 'x'", true},
+		{"2025-10-19 12:34:56 [ERROR] Something broke", true},
+		{"   0.512 Loading mod my-mod 0.1.0 (data.lua)", false},
+		{"2025-10-19 12:34:56 [INFO] Game started", false},
+	}
+
+	lm := NewLogManager(t.TempDir())
+	for _, tt := range tests {
+		if got := IsLoadError(lm.parseLine(tt.line)); got != tt.want {
+			t.Errorf("IsLoadError(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
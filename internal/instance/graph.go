@@ -0,0 +1,433 @@
+package instance
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+// Kinds of dependency graph edges
+const (
+	EdgeRequired       = "required"
+	EdgeNoLoadOrder    = "no-load-order"
+	EdgeOptional       = "optional"
+	EdgeHiddenOptional = "hidden-optional"
+	EdgeIncompatible   = "incompatible"
+)
+
+// GraphNode is one mod in an instance's dependency graph
+type GraphNode struct {
+	// Mod name
+	Name string `json:"name"`
+
+	// Installed version (empty if the mod is not installed)
+	Version string `json:"version,omitempty"`
+
+	// Source the mod was installed from according to the lockfile
+	Source string `json:"source,omitempty"`
+
+	// Whether the mod is installed or ships with Factorio
+	Installed bool `json:"installed"`
+
+	// Whether the mod ships with Factorio
+	Builtin bool `json:"builtin,omitempty"`
+
+	// Whether the mod is listed in mods.enabled
+	Requested bool `json:"requested,omitempty"`
+
+	// Whether the mod is listed in mods.disabled
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// GraphEdge is a dependency of one mod on another
+type GraphEdge struct {
+	// Mod that declares the dependency
+	From string `json:"from"`
+
+	// Mod depended on
+	To string `json:"to"`
+
+	// One of the Edge* kinds
+	Kind string `json:"kind"`
+
+	// Version constraint such as ">= 1.2.0" (empty if any version will do)
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// DependencyGraph is the resolved mod set of an instance with every
+// dependency the installed mods declare
+type DependencyGraph struct {
+	// Mods sorted by name, including dependencies that are not installed
+	Nodes []*GraphNode `json:"nodes"`
+
+	// Dependencies sorted by the declaring mod, then the target
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// DependencyGraph builds the dependency graph of an instance from its
+// installed mods. Dependencies are taken from the lockfile where it recorded
+// them and from each mod's info.json otherwise.
+func (mm *ModManager) DependencyGraph(inst *Instance) (*DependencyGraph, error) {
+	installed, err := mm.ListMods(inst)
+	if err != nil {
+		return nil, fmt.Errorf("listing installed mods: %w", err)
+	}
+	lock, err := LoadLockfile(inst)
+	if err != nil && err != ErrNoLockfile {
+		return nil, err
+	}
+
+	g := &DependencyGraph{}
+	nodes := make(map[string]*GraphNode)
+	node := func(name string) *GraphNode {
+		if nodes[name] == nil {
+			builtin := isBuiltinMod(name)
+			nodes[name] = &GraphNode{Name: name, Builtin: builtin, Installed: builtin}
+		}
+		return nodes[name]
+	}
+
+	for _, info := range installed {
+		n := node(info.Name)
+		n.Version = info.Version
+		n.Installed = true
+
+		deps := info.Dependencies
+		if lock != nil {
+			if locked := lock.Mod(info.Name); locked != nil {
+				n.Source = locked.Source
+				if locked.Dependencies != nil {
+					deps = locked.Dependencies
+				}
+			}
+		}
+
+		for _, s := range deps {
+			dep, err := solver.ParseDependency(s)
+			if err != nil {
+				return nil, fmt.Errorf("mod '%s': %w", info.Name, err)
+			}
+			node(dep.Name)
+			g.Edges = append(g.Edges, &GraphEdge{
+				From:       info.Name,
+				To:         dep.Name,
+				Kind:       edgeKind(dep.Kind),
+				Constraint: dep.Constraint.String(),
+			})
+		}
+	}
+
+	for _, name := range inst.Config.Mods.Enabled {
+		node(name).Requested = true
+	}
+	for _, name := range inst.Config.Mods.Disabled {
+		node(name).Disabled = true
+	}
+
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].Name < g.Nodes[j].Name
+	})
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g, nil
+}
+
+// edgeKind names a dependency kind for the graph
+func edgeKind(kind solver.DependencyKind) string {
+	switch kind {
+	case solver.Optional:
+		return EdgeOptional
+	case solver.HiddenOptional:
+		return EdgeHiddenOptional
+	case solver.Incompatible:
+		return EdgeIncompatible
+	case solver.RequiredNoLoadOrder:
+		return EdgeNoLoadOrder
+	default:
+		return EdgeRequired
+	}
+}
+
+// Node returns the node of a mod, or nil if the mod is not in the graph
+func (g *DependencyGraph) Node(name string) *GraphNode {
+	for _, n := range g.Nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// edgesFrom returns the dependencies a mod declares
+func (g *DependencyGraph) edgesFrom(name string) []*GraphEdge {
+	var edges []*GraphEdge
+	for _, e := range g.Edges {
+		if e.From == name {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+// pulls reports whether an edge brings its target into the mod set: the
+// target is installed and the edge is not an incompatibility
+func (g *DependencyGraph) pulls(e *GraphEdge) bool {
+	n := g.Node(e.To)
+	return e.Kind != EdgeIncompatible && n != nil && n.Installed
+}
+
+// Why returns the shortest chain of dependencies from each mod listed in the
+// config to the given mod, ordered by the listed mod's name. A mod listed in
+// the config has no chain to itself.
+func (g *DependencyGraph) Why(name string) [][]*GraphEdge {
+	var chains [][]*GraphEdge
+	for _, root := range g.Nodes {
+		if (!root.Requested && !root.Disabled) || root.Name == name {
+			continue
+		}
+
+		// Breadth-first search keeps the first, shortest chain to each mod
+		via := map[string]*GraphEdge{root.Name: nil}
+		queue := []string{root.Name}
+		for len(queue) > 0 && via[name] == nil {
+			current := queue[0]
+			queue = queue[1:]
+			for _, e := range g.edgesFrom(current) {
+				if _, seen := via[e.To]; seen || !g.pulls(e) {
+					continue
+				}
+				via[e.To] = e
+				queue = append(queue, e.To)
+			}
+		}
+
+		if via[name] == nil {
+			continue
+		}
+		var chain []*GraphEdge
+		for e := via[name]; e != nil; e = via[e.From] {
+			chain = append(chain, e)
+		}
+		slices.Reverse(chain)
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
+// unneeded returns the installed mods that no mod listed in the config
+// reaches through required dependencies, or through optional dependencies of
+// mods whose optional dependencies are wanted, sorted by name
+func (g *DependencyGraph) unneeded(wantsOptional func(name string) bool) []string {
+	reached := make(map[string]bool)
+	var queue []string
+	for _, n := range g.Nodes {
+		if n.Requested || n.Disabled {
+			reached[n.Name] = true
+			queue = append(queue, n.Name)
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, e := range g.edgesFrom(current) {
+			switch e.Kind {
+			case EdgeRequired, EdgeNoLoadOrder:
+			case EdgeOptional, EdgeHiddenOptional:
+				if !wantsOptional(e.From) {
+					continue
+				}
+			default:
+				continue
+			}
+			if !reached[e.To] && g.pulls(e) {
+				reached[e.To] = true
+				queue = append(queue, e.To)
+			}
+		}
+	}
+
+	var names []string
+	for _, n := range g.Nodes {
+		if n.Installed && !n.Builtin && !reached[n.Name] {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+// describeEdge renders the target of an edge, shown as target, with its kind
+// prefix and constraint
+func describeEdge(e *GraphEdge, target string) string {
+	prefix := map[string]string{
+		EdgeOptional:       "? ",
+		EdgeHiddenOptional: "(?) ",
+		EdgeIncompatible:   "! ",
+		EdgeNoLoadOrder:    "~ ",
+	}[e.Kind]
+	s := prefix + target
+	if e.Constraint != "" {
+		s += " " + e.Constraint
+	}
+	return s
+}
+
+// FormatChain renders a chain from Why as "a → b >= 1.0 → ? c"
+func FormatChain(chain []*GraphEdge) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	parts := []string{chain[0].From}
+	for _, e := range chain {
+		parts = append(parts, describeEdge(e, e.To))
+	}
+	return strings.Join(parts, " → ")
+}
+
+// WriteTree prints the dependencies of every mod listed in the config as an
+// indented tree. Built-in mods are left out, and a mod that was already
+// expanded is shown once without its dependencies.
+func (g *DependencyGraph) WriteTree(w io.Writer) error {
+	expanded := make(map[string]bool)
+	for _, n := range g.Nodes {
+		if (!n.Requested && !n.Disabled) || n.Builtin {
+			continue
+		}
+		label := g.label(n.Name)
+		if n.Disabled {
+			label += " (disabled)"
+		}
+		if _, err := fmt.Fprintln(w, label); err != nil {
+			return err
+		}
+		if err := g.writeSubtree(w, n.Name, "", expanded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSubtree prints the dependencies of one mod below it
+func (g *DependencyGraph) writeSubtree(w io.Writer, name, indent string, expanded map[string]bool) error {
+	expanded[name] = true
+
+	edges := g.treeEdges(name)
+	for i, e := range edges {
+		branch, next := "├── ", "│   "
+		if i == len(edges)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		target := g.Node(e.To)
+		var line string
+		switch {
+		case !target.Installed:
+			line = describeEdge(e, e.To) + " (not installed)"
+		case e.Kind == EdgeIncompatible:
+			line = describeEdge(e, e.To) + " (conflict: " + target.Version + " is installed)"
+		default:
+			line = describeEdge(e, g.label(e.To))
+			if expanded[e.To] && len(g.treeEdges(e.To)) > 0 {
+				line += " (see above)"
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s\n", indent, branch, line); err != nil {
+			return err
+		}
+
+		if g.pulls(e) && !expanded[e.To] {
+			if err := g.writeSubtree(w, e.To, indent+next, expanded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// treeEdges returns the dependencies of a mod shown in a tree
+func (g *DependencyGraph) treeEdges(name string) []*GraphEdge {
+	var edges []*GraphEdge
+	for _, e := range g.edgesFrom(name) {
+		target := g.Node(e.To)
+		if target.Builtin {
+			continue
+		}
+		// Incompatibilities only matter when both mods are present
+		if e.Kind == EdgeIncompatible && !target.Installed {
+			continue
+		}
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// label renders a mod with its installed version
+func (g *DependencyGraph) label(name string) string {
+	if n := g.Node(name); n != nil && n.Version != "" {
+		return name + " " + n.Version
+	}
+	return name
+}
+
+// WriteDot prints the graph in Graphviz dot format. Mods listed in the config
+// are boxes, and each kind of dependency has its own line style.
+func (g *DependencyGraph) WriteDot(w io.Writer, title string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", title)
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=ellipse];\n")
+
+	for _, n := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%q", strings.Replace(g.label(n.Name), " ", "\n", 1))}
+		var styles []string
+		if n.Requested || n.Disabled {
+			attrs = append(attrs, "shape=box")
+			styles = append(styles, "bold")
+		}
+		if n.Disabled {
+			attrs = append(attrs, "color=gray")
+		}
+		if n.Builtin {
+			attrs = append(attrs, "fillcolor=lightgray")
+			styles = append(styles, "filled")
+		}
+		if !n.Installed {
+			attrs = append(attrs, "fontcolor=gray")
+			styles = append(styles, "dashed")
+		}
+		if len(styles) > 0 {
+			attrs = append(attrs, fmt.Sprintf("style=%q", strings.Join(styles, ",")))
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", n.Name, strings.Join(attrs, ", "))
+	}
+
+	edgeStyles := map[string]string{
+		EdgeRequired:       "style=solid",
+		EdgeNoLoadOrder:    "style=solid, arrowhead=empty",
+		EdgeOptional:       "style=dashed",
+		EdgeHiddenOptional: "style=dotted",
+		EdgeIncompatible:   "style=dashed, color=red, arrowhead=tee",
+	}
+	for _, e := range g.Edges {
+		attrs := edgeStyles[e.Kind]
+		if e.Constraint != "" {
+			attrs += fmt.Sprintf(", label=%q", e.Constraint)
+		}
+		fmt.Fprintf(&b, "  %q -> %q [%s];\n", e.From, e.To, attrs)
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,131 @@
+package instance
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1",
+			Mods: ModsConfig{
+				Enabled: []string{"base", "pack"},
+				Sources: map[string]string{"repo": "gh:test/repo"},
+			},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	if err := os.MkdirAll(filepath.Join(inst.Dir, "mods"), 0755); err != nil {
+		t.Fatalf("Failed to create mods directory: %v", err)
+	}
+
+	manager := NewModManager(tmpDir)
+	for _, info := range []*ModInfo{
+		{Name: "pack", Version: "1.0.0", Dependencies: []string{"base >= 1.1", "core >= 0.2.0", "? extra", "! rival"}},
+		{Name: "core", Version: "0.3.0", Dependencies: []string{"~ lib"}},
+		{Name: "extra", Version: "2.0.0", Dependencies: []string{"lib", "(?) missing"}},
+		{Name: "lib", Version: "1.0.0"},
+	} {
+		f, err := os.Create(filepath.Join(inst.Dir, "mods", info.Name+"_"+info.Version+".zip"))
+		if err != nil {
+			t.Fatalf("Failed to create mod file: %v", err)
+		}
+		if err := createTestModZip(f, info); err != nil {
+			t.Fatalf("Failed to create test mod zip: %v", err)
+		}
+		f.Close()
+	}
+
+	// The lockfile's record of the resolved dependencies wins over info.json
+	lock := &Lockfile{
+		FactorioVersion: "1.1",
+		Mods: []LockedMod{
+			{Name: "lib", Version: "1.0.0", Source: PortalSourceName, Dependencies: []string{"base"}},
+		},
+	}
+	if err := lock.Save(inst); err != nil {
+		t.Fatalf("Lockfile.Save() error = %v", err)
+	}
+
+	g, err := manager.DependencyGraph(inst)
+	if err != nil {
+		t.Fatalf("DependencyGraph() error = %v", err)
+	}
+
+	t.Run("nodes", func(t *testing.T) {
+		if n := g.Node("lib"); n == nil || n.Source != PortalSourceName || !n.Installed {
+			t.Errorf("Node(lib) = %+v, want installed from the portal", n)
+		}
+		if n := g.Node("missing"); n == nil || n.Installed {
+			t.Errorf("Node(missing) = %+v, want a node that is not installed", n)
+		}
+		if n := g.Node("base"); n == nil || !n.Builtin || !n.Requested {
+			t.Errorf("Node(base) = %+v, want a requested builtin", n)
+		}
+	})
+
+	t.Run("why", func(t *testing.T) {
+		tests := []struct {
+			mod  string
+			want []string
+		}{
+			{"lib", []string{"pack → core >= 0.2.0 → ~ lib"}},
+			{"extra", []string{"pack → ? extra"}},
+			{"pack", nil},
+			{"rival", nil},
+			{"missing", nil},
+		}
+		for _, tt := range tests {
+			var got []string
+			for _, chain := range g.Why(tt.mod) {
+				got = append(got, FormatChain(chain))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Why(%s) = %q, want %q", tt.mod, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := g.WriteTree(&buf); err != nil {
+			t.Fatalf("WriteTree() error = %v", err)
+		}
+		want := `pack 1.0.0
+├── core 0.3.0 >= 0.2.0
+│   └── ~ lib 1.0.0
+└── ? extra 2.0.0
+    ├── lib 1.0.0
+    └── (?) missing (not installed)
+`
+		if buf.String() != want {
+			t.Errorf("WriteTree() =\n%s\nwant\n%s", buf.String(), want)
+		}
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := g.WriteDot(&buf, "test-instance"); err != nil {
+			t.Fatalf("WriteDot() error = %v", err)
+		}
+		for _, want := range []string{
+			`digraph "test-instance" {`,
+			`"pack" [label="pack\n1.0.0", shape=box, style="bold"];`,
+			`"pack" -> "core" [style=solid, label=">= 0.2.0"];`,
+			`"core" -> "lib" [style=solid, arrowhead=empty];`,
+			`"pack" -> "extra" [style=dashed];`,
+			`"extra" -> "missing" [style=dotted];`,
+			`"pack" -> "rival" [style=dashed, color=red, arrowhead=tee];`,
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("WriteDot() is missing %s in\n%s", want, buf.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,308 @@
+package instance
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/WhyIsSandwich/factctl/internal/modsettings"
+	"github.com/WhyIsSandwich/factctl/internal/resolve"
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+// ImportedMod is a mod found in an existing Factorio profile
+type ImportedMod struct {
+	// Mod name and version from info.json
+	Name    string
+	Version string
+
+	// Path of the mod zip in the profile
+	Path string
+
+	// SHA256 of the mod zip, pinned in its file source so the import's copy in
+	// the cache is found again
+	SHA256 string
+
+	// Whether the portal release with this name and version has the same SHA1.
+	// Other mods are kept as file sources pointing at Path.
+	Portal bool
+
+	// Whether mod-list.json enables the mod
+	Enabled bool
+
+	// info.json of the mod
+	info *ModInfo
+}
+
+// Profile is what was read from an existing Factorio profile
+type Profile struct {
+	// Directory holding mod-list.json and the mod zips
+	ModsDir string
+
+	// Mod zips, sorted by name. Only the newest version of each mod is kept,
+	// as Factorio would load it.
+	Mods []*ImportedMod
+
+	// Built-in mods listed in mod-list.json and whether they are enabled
+	Builtin map[string]bool
+
+	// Decoded mod-settings.dat, or nil if the profile has none
+	Settings *modsettings.File
+
+	// Things that could not be imported
+	Warnings []string
+}
+
+// ReadProfile reads the mods, mod-list.json and mod-settings.dat of an
+// existing Factorio profile. dir may be the profile (such as ~/.factorio) or
+// its mods directory. Each mod zip is matched to a portal release by name,
+// version and SHA1.
+func (mm *ModManager) ReadProfile(ctx context.Context, dir string) (*Profile, error) {
+	modsDir := filepath.Join(dir, "mods")
+	if _, err := os.Stat(filepath.Join(dir, "mod-list.json")); err == nil {
+		modsDir = dir
+	}
+	if _, err := os.Stat(modsDir); err != nil {
+		return nil, fmt.Errorf("no mods directory in %s: %w", dir, err)
+	}
+
+	p := &Profile{ModsDir: modsDir, Builtin: make(map[string]bool)}
+
+	// Mods missing from mod-list.json are enabled by Factorio
+	listed, err := readModListFile(filepath.Join(modsDir, "mod-list.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(modsDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading mods directory: %w", err)
+	}
+
+	newest := make(map[string]*ImportedMod)
+	for _, entry := range entries {
+		path := filepath.Join(modsDir, entry.Name())
+		if entry.IsDir() {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s is an unpacked mod directory and was not imported", path))
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), ".zip") {
+			continue
+		}
+
+		info, err := mm.getModInfo(path)
+		if err != nil {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s is not a readable mod: %v", path, err))
+			continue
+		}
+		version, err := solver.ParseVersion(info.Version)
+		if err != nil {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s has an invalid version: %v", path, err))
+			continue
+		}
+		if current := newest[info.Name]; current != nil && solver.MustParseVersion(current.Version).Compare(version) >= 0 {
+			continue
+		}
+
+		enabled, ok := listed[info.Name]
+		newest[info.Name] = &ImportedMod{
+			Name:    info.Name,
+			Version: info.Version,
+			Path:    path,
+			Enabled: enabled || !ok,
+			info:    info,
+		}
+	}
+
+	for name, enabled := range listed {
+		switch {
+		case isBuiltinMod(name):
+			p.Builtin[name] = enabled
+		case newest[name] == nil && enabled:
+			p.Warnings = append(p.Warnings, fmt.Sprintf("mod '%s' is enabled in mod-list.json but not installed", name))
+		}
+	}
+
+	for _, mod := range newest {
+		p.Mods = append(p.Mods, mod)
+	}
+	sort.Slice(p.Mods, func(i, j int) bool {
+		return p.Mods[i].Name < p.Mods[j].Name
+	})
+	sort.Strings(p.Warnings)
+
+	// Match each file to its portal release; the others are pinned by hash
+	errs := forEach(DefaultWorkers, p.Mods, func(_ int, mod *ImportedMod) error {
+		mod.Portal = mm.matchesPortalRelease(ctx, mod)
+		if mod.Portal {
+			return nil
+		}
+		sum, err := hashFile(mod.Path)
+		mod.SHA256 = sum
+		return err
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %w", p.Mods[i].Path, err)
+		}
+	}
+
+	if f, err := os.Open(filepath.Join(modsDir, "mod-settings.dat")); err == nil {
+		defer f.Close()
+		if p.Settings, err = modsettings.Decode(f); err != nil {
+			return nil, fmt.Errorf("reading mod settings: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+// matchesPortalRelease reports whether a mod zip is the portal release of the
+// same name and version
+func (mm *ModManager) matchesPortalRelease(ctx context.Context, mod *ImportedMod) bool {
+	releases, err := mm.getPortalReleases(ctx, mod.Name)
+	if err != nil {
+		fmt.Printf("  → Warning: Could not look up '%s' on the mod portal: %v\n", mod.Name, err)
+		return false
+	}
+
+	for _, release := range releases {
+		if release.Version != mod.Version {
+			continue
+		}
+		sum, err := sha1File(mod.Path)
+		return err == nil && strings.EqualFold(sum, release.SHA1)
+	}
+	return false
+}
+
+// sha1File returns the hex-encoded SHA1 of a file, as the mod portal lists it
+func sha1File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Config builds an instance configuration that reproduces the profile. Portal
+// mods get a portal source pinned to the profile's release and other mods a
+// file source pinned to the zip's SHA256, both named after the mod; the
+// settings from mod-settings.dat are kept by scope.
+func (p *Profile) Config(name, version string) *Config {
+	cfg := &Config{
+		Name:    name,
+		Version: version,
+		Mods: ModsConfig{
+			Enabled: []string{"base"},
+			Sources: make(map[string]string),
+		},
+	}
+
+	for _, builtin := range builtinModNames {
+		enabled, ok := p.Builtin[builtin]
+		switch {
+		case builtin == "base" || !ok:
+		case enabled:
+			cfg.Mods.Enabled = append(cfg.Mods.Enabled, builtin)
+		default:
+			cfg.Mods.Disabled = append(cfg.Mods.Disabled, builtin)
+		}
+	}
+
+	for _, mod := range p.Mods {
+		if mod.Enabled {
+			cfg.Mods.Enabled = append(cfg.Mods.Enabled, mod.Name)
+		} else {
+			cfg.Mods.Disabled = append(cfg.Mods.Disabled, mod.Name)
+		}
+		if mod.Portal {
+			// The exact release keeps the next up from upgrading the mod
+			cfg.Mods.Sources[mod.Name] = fmt.Sprintf("portal:%s@%s", mod.Name, mod.Version)
+		} else {
+			cfg.Mods.Sources[mod.Name] = "file:" + mod.Path + "#sha256=" + mod.SHA256
+		}
+	}
+
+	if p.Settings != nil {
+		settings := make(map[string]interface{})
+		for _, scope := range modsettings.Scopes {
+			if values := p.Settings.Settings[scope]; len(values) > 0 {
+				scoped := make(map[string]interface{}, len(values))
+				for k, v := range values {
+					scoped[k] = v
+				}
+				settings[scope] = scoped
+			}
+		}
+		if len(settings) > 0 {
+			cfg.Mods.Settings = settings
+		}
+	}
+
+	return cfg
+}
+
+// ImportProfile places the mods and mod-settings.dat of a profile into an
+// instance created from the profile's Config. Every mod file goes through the
+// download cache, filed where a later download of the same source would look,
+// so the next up doesn't fetch them again.
+func (mm *ModManager) ImportProfile(inst *Instance, p *Profile) error {
+	for _, mod := range p.Mods {
+		src, err := resolve.ParseSource(inst.Config.Mods.Sources[mod.Name])
+		if err != nil {
+			return fmt.Errorf("source of '%s': %w", mod.Name, err)
+		}
+
+		blob, err := mm.cacheFile(cacheKey(src), mod.Path)
+		if err != nil {
+			return fmt.Errorf("caching '%s': %w", mod.Name, err)
+		}
+		if _, err := mm.installModFile(inst, mod.info, "", blob); err != nil {
+			return fmt.Errorf("installing '%s': %w", mod.Name, err)
+		}
+		if err := mm.updateModList(inst, mod.Name, mod.Enabled); err != nil {
+			return fmt.Errorf("updating mod list: %w", err)
+		}
+	}
+
+	if p.Settings != nil {
+		if err := SaveModSettings(inst, p.Settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheFile copies a file into the download cache under key and returns the
+// path of the cached copy
+func (mm *ModManager) cacheFile(key, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w, err := mm.downloads.Create(key)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		w.Abort()
+		return "", err
+	}
+	_, blob, err := w.Commit()
+	return blob, err
+}
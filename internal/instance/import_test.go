@@ -0,0 +1,143 @@
+package instance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/WhyIsSandwich/factctl/internal/modsettings"
+	"github.com/WhyIsSandwich/factctl/internal/resolve"
+)
+
+func TestImportProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	modsDir := filepath.Join(tmpDir, "profile", "mods")
+	if err := os.MkdirAll(filepath.Join(modsDir, "dev-mod"), 0755); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	for _, info := range []*ModInfo{
+		{Name: "a", Version: "1.0.0"},
+		{Name: "a", Version: "1.1.0"},
+		{Name: "b", Version: "2.0.0"},
+		{Name: "c", Version: "1.0.0"},
+	} {
+		f, err := os.Create(filepath.Join(modsDir, info.Name+"_"+info.Version+".zip"))
+		if err != nil {
+			t.Fatalf("Failed to create mod file: %v", err)
+		}
+		if err := createTestModZip(f, info); err != nil {
+			t.Fatalf("Failed to create test mod zip: %v", err)
+		}
+		f.Close()
+	}
+
+	modList := `{"mods": [
+		{"name": "base", "enabled": true},
+		{"name": "quality", "enabled": false},
+		{"name": "a", "enabled": true},
+		{"name": "b", "enabled": false},
+		{"name": "gone", "enabled": true}
+	]}`
+	if err := os.WriteFile(filepath.Join(modsDir, "mod-list.json"), []byte(modList), 0644); err != nil {
+		t.Fatalf("Failed to write mod list: %v", err)
+	}
+
+	settings := modsettings.New(modsettings.Version{Major: 2, Minor: 0, Patch: 28})
+	settings.Settings[modsettings.Startup]["a-setting"] = int64(3)
+	f, err := os.Create(filepath.Join(modsDir, "mod-settings.dat"))
+	if err != nil {
+		t.Fatalf("Failed to create mod settings: %v", err)
+	}
+	if err := modsettings.Encode(f, settings); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	f.Close()
+
+	// Only a matches its portal release
+	manager := NewModManager(tmpDir)
+	sum, err := sha1File(filepath.Join(modsDir, "a_1.1.0.zip"))
+	if err != nil {
+		t.Fatalf("sha1File() error = %v", err)
+	}
+	manager.portalReleases["a"] = []resolve.PortalRelease{{Version: "1.1.0", SHA1: sum}}
+	manager.portalReleases["b"] = []resolve.PortalRelease{{Version: "2.0.0", SHA1: "0000"}}
+	manager.portalReleases["c"] = nil
+
+	profile, err := manager.ReadProfile(context.Background(), filepath.Dir(modsDir))
+	if err != nil {
+		t.Fatalf("ReadProfile() error = %v", err)
+	}
+
+	if len(profile.Mods) != 3 || profile.Mods[0].Version != "1.1.0" {
+		t.Fatalf("ReadProfile() mods = %+v, want a 1.1.0, b and c", profile.Mods)
+	}
+	if !profile.Mods[0].Portal || profile.Mods[1].Portal || profile.Mods[2].Portal {
+		t.Errorf("ReadProfile() matched portal releases %v %v %v, want only a", profile.Mods[0].Portal, profile.Mods[1].Portal, profile.Mods[2].Portal)
+	}
+	if len(profile.Warnings) != 2 {
+		t.Errorf("ReadProfile() warnings = %q, want the unpacked mod and the missing mod", profile.Warnings)
+	}
+	if profile.Settings == nil || profile.Settings.Version.String() != "2.0.28" {
+		t.Errorf("ReadProfile() settings = %+v, want version 2.0.28", profile.Settings)
+	}
+
+	cfg := profile.Config("imported", "2.0.28")
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Config() is not valid: %v", err)
+	}
+	if !slices.Equal(cfg.Mods.Enabled, []string{"base", "a", "c"}) || !slices.Equal(cfg.Mods.Disabled, []string{"quality", "b"}) {
+		t.Errorf("Config() enabled %v disabled %v, want [base a c] and [quality b]", cfg.Mods.Enabled, cfg.Mods.Disabled)
+	}
+	bSum, err := hashFile(filepath.Join(modsDir, "b_2.0.0.zip"))
+	if err != nil {
+		t.Fatalf("hashFile() error = %v", err)
+	}
+	if cfg.Mods.Sources["a"] != "portal:a@1.1.0" || cfg.Mods.Sources["b"] != "file:"+filepath.Join(modsDir, "b_2.0.0.zip")+"#sha256="+bSum {
+		t.Errorf("Config() sources = %v", cfg.Mods.Sources)
+	}
+	if scoped, err := cfg.Mods.ScopedSettings(); err != nil || scoped[modsettings.Startup]["a-setting"] != int64(3) {
+		t.Errorf("Config() settings = %v, %v", scoped, err)
+	}
+
+	inst := &Instance{Config: cfg, Dir: filepath.Join(tmpDir, "instances", "imported")}
+	if err := cfg.SaveConfig(ConfigPath(inst)); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	if err := manager.ImportProfile(inst, profile); err != nil {
+		t.Fatalf("ImportProfile() error = %v", err)
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		if !manager.isModInstalled(inst, name) {
+			t.Errorf("ImportProfile() did not install %s", name)
+		}
+	}
+	if list, err := ReadModList(inst); err != nil || !list["a"] || list["b"] {
+		t.Errorf("mod-list.json = %v, %v, want a enabled and b disabled", list, err)
+	}
+	if got, err := LoadModSettings(inst); err != nil || got.Version != settings.Version {
+		t.Errorf("LoadModSettings() = %+v, %v", got, err)
+	}
+
+	// The next up finds every mod in the cache through its own source, and the
+	// portal release through the portal fallback as well. Nothing else is cached.
+	keys := []string{cacheKey(&resolve.Source{Type: resolve.SourcePortal, ID: "a", Revision: "1.1.0"})}
+	for _, name := range []string{"a", "b", "c"} {
+		src, err := resolve.ParseSource(cfg.Mods.Sources[name])
+		if err != nil {
+			t.Fatalf("ParseSource() error = %v", err)
+		}
+		keys = append(keys, cacheKey(src))
+	}
+	for _, key := range keys {
+		if _, _, ok := manager.downloads.Get(key); key == "" || !ok {
+			t.Errorf("ImportProfile() did not cache %q", key)
+		}
+	}
+	if entries, err := manager.downloads.List(); err != nil || len(entries) != 3 {
+		t.Errorf("cache entries = %d, %v, want one per mod", len(entries), err)
+	}
+}
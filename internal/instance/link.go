@@ -0,0 +1,140 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Ways a mod file can be placed into an instance from the download cache
+const (
+	// LinkAuto tries a reflink, then a hardlink, then falls back to copying
+	LinkAuto = "auto"
+	// LinkReflink shares the file's blocks copy-on-write (Btrfs, XFS, APFS)
+	LinkReflink = "reflink"
+	// LinkHardlink makes the instance file another name for the cached file
+	LinkHardlink = "hardlink"
+	// LinkSymlink points the instance file at the cache. The link breaks if
+	// the cache entry is pruned.
+	LinkSymlink = "symlink"
+	// LinkCopy always writes a full copy
+	LinkCopy = "copy"
+)
+
+// LinkedBlobs finds the cache blobs that instances' mod files are symlinks to
+// and returns the names of the instances using each, keyed by SHA256. These
+// links break if the blob is removed, unlike hardlinks, reflinks and copies.
+func (mm *ModManager) LinkedBlobs() (map[string][]string, error) {
+	instancesDir := filepath.Join(mm.baseDir, "instances")
+	dirs, err := os.ReadDir(instancesDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading instances: %w", err)
+	}
+
+	linked := make(map[string][]string)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		modsDir := filepath.Join(instancesDir, dir.Name(), "mods")
+		files, err := os.ReadDir(modsDir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.Type()&fs.ModeSymlink == 0 {
+				continue
+			}
+			target, err := os.Readlink(filepath.Join(modsDir, file.Name()))
+			if err != nil {
+				continue
+			}
+			sha := filepath.Base(target)
+			if len(sha) != 64 {
+				continue
+			}
+			blob, err := filepath.Abs(mm.downloads.BlobPath(sha))
+			if err != nil || filepath.Clean(target) != blob {
+				continue
+			}
+			if users := linked[sha]; len(users) == 0 || users[len(users)-1] != dir.Name() {
+				linked[sha] = append(users, dir.Name())
+			}
+		}
+	}
+	return linked, nil
+}
+
+// errReflinkUnsupported is returned where the platform has no reflink support
+var errReflinkUnsupported = errors.New("reflinks are not supported on this platform")
+
+// linkFile places src at dst using mode and returns the method that was used.
+// A mode that the filesystem does not support falls back to copying, so the
+// only errors are ones that a copy would hit as well.
+func linkFile(src, dst, mode string) (string, error) {
+	var attempts []string
+	switch mode {
+	case LinkAuto, "":
+		attempts = []string{LinkReflink, LinkHardlink}
+	case LinkReflink, LinkHardlink, LinkSymlink:
+		attempts = []string{mode}
+	case LinkCopy:
+	default:
+		return "", fmt.Errorf("unknown link mode %q", mode)
+	}
+
+	for _, method := range attempts {
+		var err error
+		switch method {
+		case LinkReflink:
+			err = reflink(src, dst)
+		case LinkHardlink:
+			err = os.Link(src, dst)
+		case LinkSymlink:
+			var abs string
+			if abs, err = filepath.Abs(src); err == nil {
+				err = os.Symlink(abs, dst)
+			}
+		}
+		if err == nil {
+			return method, nil
+		}
+		// Clean up whatever a failed attempt may have left behind
+		os.Remove(dst)
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return "", err
+	}
+	return LinkCopy, nil
+}
+
+// copyFile copies src to a new file at dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinkFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "blob")
+	if err := os.WriteFile(src, []byte("mod contents"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	tests := []struct {
+		mode    string
+		methods []string // Acceptable results; reflinks depend on the filesystem
+	}{
+		{LinkAuto, []string{LinkReflink, LinkHardlink, LinkCopy}},
+		{LinkReflink, []string{LinkReflink, LinkCopy}},
+		{LinkHardlink, []string{LinkHardlink}},
+		{LinkSymlink, []string{LinkSymlink}},
+		{LinkCopy, []string{LinkCopy}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dst := filepath.Join(tmpDir, tt.mode+".zip")
+			method, err := linkFile(src, dst, tt.mode)
+			if err != nil {
+				t.Fatalf("linkFile() error = %v", err)
+			}
+
+			found := false
+			for _, m := range tt.methods {
+				found = found || m == method
+			}
+			if !found {
+				t.Errorf("linkFile() used %s, want one of %v", method, tt.methods)
+			}
+
+			data, err := os.ReadFile(dst)
+			if err != nil || string(data) != "mod contents" {
+				t.Errorf("linked file contains %q, %v", data, err)
+			}
+
+			srcInfo, _ := os.Stat(src)
+			dstInfo, _ := os.Lstat(dst)
+			if same := os.SameFile(srcInfo, dstInfo); same != (method == LinkHardlink) {
+				t.Errorf("os.SameFile() = %v for method %s", same, method)
+			}
+			if isLink := dstInfo.Mode()&os.ModeSymlink != 0; isLink != (method == LinkSymlink) {
+				t.Errorf("symlink = %v for method %s", isLink, method)
+			}
+		})
+	}
+
+	t.Run("unknown mode", func(t *testing.T) {
+		if _, err := linkFile(src, filepath.Join(tmpDir, "unknown.zip"), "bogus"); err == nil {
+			t.Error("linkFile() accepted an unknown mode")
+		}
+	})
+}
+
+func TestLinkedModFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{Name: "test-instance", Version: "1.1"},
+		Dir:    filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	for _, dir := range []string{"mods", "config"} {
+		if err := os.MkdirAll(filepath.Join(inst.Dir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s directory: %v", dir, err)
+		}
+	}
+
+	// A mod zip in the cache, placed into the instance once per link mode
+	blob := filepath.Join(tmpDir, "blob")
+	f, err := os.Create(blob)
+	if err != nil {
+		t.Fatalf("Failed to create mod file: %v", err)
+	}
+	if err := createTestModZip(f, &ModInfo{Name: "linked-mod", Version: "1.0.0", FactorioVersion: "1.1"}); err != nil {
+		t.Fatalf("Failed to create test mod zip: %v", err)
+	}
+	f.Close()
+
+	manager := NewModManager(tmpDir)
+	for _, mode := range []string{LinkHardlink, LinkSymlink, LinkCopy} {
+		t.Run(mode, func(t *testing.T) {
+			inst.Config.Mods.Link = mode
+			info := &ModInfo{Name: "linked-mod", Version: "1.0.0"}
+			if _, err := manager.installModFile(inst, info, "", blob); err != nil {
+				t.Fatalf("installModFile() error = %v", err)
+			}
+
+			mods, err := manager.ListMods(inst)
+			if err != nil {
+				t.Fatalf("ListMods() error = %v", err)
+			}
+			if len(mods) != 1 || mods[0].Name != "linked-mod" {
+				t.Errorf("ListMods() = %v, want linked-mod only", mods)
+			}
+
+			if err := manager.UninstallMod(inst, "linked-mod"); err != nil {
+				t.Fatalf("UninstallMod() error = %v", err)
+			}
+			if files := installedModFiles(inst, "linked-mod"); len(files) != 0 {
+				t.Errorf("installed files after UninstallMod() = %v", files)
+			}
+			if _, err := os.Stat(blob); err != nil {
+				t.Errorf("UninstallMod() removed the cached file: %v", err)
+			}
+		})
+	}
+}
+
+func TestLinkedBlobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewModManager(tmpDir)
+
+	w, err := manager.Cache().Create("portal:linked-mod:1.0.0")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	w.Write([]byte("mod contents"))
+	entry, blob, err := w.Commit()
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	// Only the symlinked copy depends on the blob staying in the cache
+	for name, mode := range map[string]string{"linked": LinkSymlink, "copied": LinkCopy, "hardlinked": LinkHardlink} {
+		modsDir := filepath.Join(tmpDir, "instances", name, "mods")
+		if err := os.MkdirAll(modsDir, 0755); err != nil {
+			t.Fatalf("Failed to create mods directory: %v", err)
+		}
+		if _, err := linkFile(blob, filepath.Join(modsDir, "linked-mod_1.0.0.zip"), mode); err != nil {
+			t.Fatalf("linkFile() error = %v", err)
+		}
+	}
+
+	linked, err := manager.LinkedBlobs()
+	if err != nil {
+		t.Fatalf("LinkedBlobs() error = %v", err)
+	}
+	if len(linked) != 1 || len(linked[entry.SHA256]) != 1 || linked[entry.SHA256][0] != "linked" {
+		t.Errorf("LinkedBlobs() = %v, want %s used by linked", linked, entry.SHA256[:12])
+	}
+}
@@ -0,0 +1,206 @@
+package instance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// LockfileName is the name of the lockfile written next to instance.json
+const LockfileName = "factctl.lock"
+
+// lockfileVersion is the current lockfile format version
+const lockfileVersion = 1
+
+// PortalSourceName is the source name recorded for mods installed from the mod portal
+const PortalSourceName = "portal"
+
+var (
+	// ErrNoLockfile is returned when a frozen install is requested but no lockfile exists
+	ErrNoLockfile = errors.New("no lockfile found")
+)
+
+// Lockfile records the exact set of mods resolved for an instance
+type Lockfile struct {
+	// Format version of the lockfile
+	Version int `json:"version"`
+
+	// Factorio version the mods were resolved against
+	FactorioVersion string `json:"factorio_version"`
+
+	// When the lockfile was generated
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// Sources that were loaded, keyed by source name
+	Sources map[string]LockedSource `json:"sources"`
+
+	// Installed mods, sorted by name
+	Mods []LockedMod `json:"mods"`
+}
+
+// LockedSource records a configured source and the revision it resolved to
+type LockedSource struct {
+	// Source specification as written in the config
+	Spec string `json:"spec"`
+
+	// Resolved commit SHA (empty if the source has no revisions)
+	Revision string `json:"revision,omitempty"`
+}
+
+// LockedMod records a single installed mod
+type LockedMod struct {
+	// Mod name from info.json
+	Name string `json:"name"`
+
+	// Mod version from info.json
+	Version string `json:"version"`
+
+	// Name of the source the mod came from ("portal" for the mod portal)
+	Source string `json:"source"`
+
+	// Resolved commit SHA for repository sources or release version for the portal
+	Revision string `json:"revision,omitempty"`
+
+	// SHA256 of the installed mod file
+	SHA256 string `json:"sha256"`
+
+	// Dependencies from info.json as resolved, kept for factctl mods graph
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// LockfilePath returns the path of the lockfile for an instance
+func LockfilePath(inst *Instance) string {
+	return filepath.Join(inst.Dir, "config", LockfileName)
+}
+
+// LoadLockfile reads the lockfile for an instance
+func LoadLockfile(inst *Instance) (*Lockfile, error) {
+	data, err := os.ReadFile(LockfilePath(inst))
+	if os.IsNotExist(err) {
+		return nil, ErrNoLockfile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading lockfile: %w", err)
+	}
+
+	var lock Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parsing lockfile: %w", err)
+	}
+
+	if lock.Version != lockfileVersion {
+		return nil, fmt.Errorf("unsupported lockfile version %d", lock.Version)
+	}
+
+	return &lock, nil
+}
+
+// Save writes the lockfile for an instance
+func (l *Lockfile) Save(inst *Instance) error {
+	l.Version = lockfileVersion
+
+	// Keep the output stable so lockfiles diff cleanly
+	sort.Slice(l.Mods, func(i, j int) bool {
+		return l.Mods[i].Name < l.Mods[j].Name
+	})
+
+	path := LockfilePath(inst)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding lockfile: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing lockfile: %w", err)
+	}
+
+	return nil
+}
+
+// Mod returns the locked entry for a mod, or nil if the mod is not locked
+func (l *Lockfile) Mod(name string) *LockedMod {
+	for i := range l.Mods {
+		if l.Mods[i].Name == name {
+			return &l.Mods[i]
+		}
+	}
+	return nil
+}
+
+// RemoveMod drops a mod from the lockfile and reports whether it was locked
+func (l *Lockfile) RemoveMod(name string) bool {
+	for i := range l.Mods {
+		if l.Mods[i].Name == name {
+			l.Mods = append(l.Mods[:i], l.Mods[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDrift compares the lockfile against an instance configuration and
+// returns a description of every difference found
+func (l *Lockfile) CheckDrift(cfg *Config) []string {
+	var drift []string
+
+	if l.FactorioVersion != cfg.Version {
+		drift = append(drift, fmt.Sprintf("factorio version changed from %s to %s", l.FactorioVersion, cfg.Version))
+	}
+
+	// Every configured source must be locked with the same spec
+	for name, spec := range cfg.Mods.Sources {
+		locked, ok := l.Sources[name]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("source '%s' is not in the lockfile", name))
+			continue
+		}
+		if locked.Spec != spec {
+			drift = append(drift, fmt.Sprintf("source '%s' changed from %s to %s", name, locked.Spec, spec))
+		}
+	}
+	for name := range l.Sources {
+		if _, ok := cfg.Mods.Sources[name]; !ok {
+			drift = append(drift, fmt.Sprintf("source '%s' was removed from the config", name))
+		}
+	}
+
+	// Every enabled mod must be locked
+	for _, modName := range cfg.Mods.Enabled {
+		if isBuiltinMod(modName) {
+			continue
+		}
+		if l.Mod(modName) == nil {
+			drift = append(drift, fmt.Sprintf("mod '%s' is enabled but not in the lockfile", modName))
+		}
+	}
+
+	sort.Strings(drift)
+	return drift
+}
+
+// hashFile returns the hex-encoded SHA256 of a file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
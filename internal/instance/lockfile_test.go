@@ -0,0 +1,161 @@
+package instance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockfile(t *testing.T) {
+	// Create temporary directory for testing
+	tmpDir, err := os.MkdirTemp("", "factctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1",
+			Mods: ModsConfig{
+				Enabled: []string{"base", "test-mod"},
+				Sources: map[string]string{
+					"mods": "github:test/mods@main",
+				},
+			},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+
+	for _, dir := range []string{"mods", "config"} {
+		if err := os.MkdirAll(filepath.Join(inst.Dir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory %s: %v", dir, err)
+		}
+	}
+
+	// Install a mod file directly
+	modPath := filepath.Join(inst.Dir, "mods", "test-mod_1.0.0.zip")
+	modFile, err := os.Create(modPath)
+	if err != nil {
+		t.Fatalf("Failed to create mod file: %v", err)
+	}
+	if err := createTestModZip(modFile, &ModInfo{Name: "test-mod", Version: "1.0.0"}); err != nil {
+		modFile.Close()
+		t.Fatalf("Failed to create test mod zip: %v", err)
+	}
+	modFile.Close()
+
+	modHash, err := hashFile(modPath)
+	if err != nil {
+		t.Fatalf("Failed to hash mod file: %v", err)
+	}
+
+	t.Run("missing lockfile", func(t *testing.T) {
+		if _, err := LoadLockfile(inst); err != ErrNoLockfile {
+			t.Errorf("LoadLockfile() error = %v, want %v", err, ErrNoLockfile)
+		}
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		lock := &Lockfile{
+			FactorioVersion: "1.1",
+			Sources: map[string]LockedSource{
+				"mods": {Spec: "github:test/mods@main", Revision: "0123456789abcdef"},
+			},
+			Mods: []LockedMod{
+				{Name: "zzz-mod", Version: "2.0.0", Source: PortalSourceName, Revision: "2.0.0", SHA256: "ff"},
+				{Name: "test-mod", Version: "1.0.0", Source: "mods", Revision: "0123456789abcdef", SHA256: modHash},
+			},
+		}
+		if err := lock.Save(inst); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		loaded, err := LoadLockfile(inst)
+		if err != nil {
+			t.Fatalf("LoadLockfile() error = %v", err)
+		}
+
+		if len(loaded.Mods) != 2 || loaded.Mods[0].Name != "test-mod" {
+			t.Errorf("LoadLockfile() mods not sorted by name: %+v", loaded.Mods)
+		}
+		if got := loaded.Sources["mods"].Revision; got != "0123456789abcdef" {
+			t.Errorf("LoadLockfile() source revision = %q", got)
+		}
+		if loaded.Mod("zzz-mod") == nil || loaded.Mod("missing") != nil {
+			t.Error("Mod() lookup returned unexpected result")
+		}
+	})
+
+	t.Run("drift detection", func(t *testing.T) {
+		lock := &Lockfile{
+			FactorioVersion: "1.1",
+			Sources: map[string]LockedSource{
+				"mods": {Spec: "github:test/mods@main"},
+			},
+			Mods: []LockedMod{{Name: "test-mod", Version: "1.0.0"}},
+		}
+
+		if drift := lock.CheckDrift(inst.Config); len(drift) != 0 {
+			t.Errorf("CheckDrift() = %v, want no drift", drift)
+		}
+
+		changed := *inst.Config
+		changed.Version = "2.0"
+		changed.Mods = ModsConfig{
+			Enabled: []string{"base", "test-mod", "other-mod"},
+			Sources: map[string]string{
+				"mods":  "github:test/mods@dev",
+				"extra": "github:test/extra@main",
+			},
+		}
+		if drift := lock.CheckDrift(&changed); len(drift) != 4 {
+			t.Errorf("CheckDrift() = %v, want 4 differences", drift)
+		}
+	})
+
+	t.Run("frozen install", func(t *testing.T) {
+		// Sources are not downloaded here, so lock only the installed file
+		inst.Config.Mods.Sources = map[string]string{}
+		lock := &Lockfile{
+			FactorioVersion: "1.1",
+			Sources:         map[string]LockedSource{},
+			Mods: []LockedMod{
+				{Name: "test-mod", Version: "1.0.0", Source: "mods", SHA256: modHash},
+			},
+		}
+		if err := lock.Save(inst); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		manager := NewModManager(tmpDir)
+		installed, err := manager.InstallModsFrozen(context.Background(), inst)
+		if err != nil {
+			t.Fatalf("InstallModsFrozen() error = %v", err)
+		}
+		if len(installed) != 1 || installed[0] != "test-mod" {
+			t.Errorf("InstallModsFrozen() = %v, want [test-mod]", installed)
+		}
+
+		// A modified file must be rejected
+		lock.Mods[0].SHA256 = strings.Repeat("0", 64)
+		if err := lock.Save(inst); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if _, err := manager.InstallModsFrozen(context.Background(), inst); err == nil {
+			t.Error("InstallModsFrozen() expected hash mismatch error but got nil")
+		}
+
+		// An installed mod missing from the lockfile is drift
+		lock.Mods = nil
+		if err := lock.Save(inst); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if _, err := manager.InstallModsFrozen(context.Background(), inst); err == nil {
+			t.Error("InstallModsFrozen() expected drift error but got nil")
+		}
+	})
+}
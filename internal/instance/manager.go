@@ -166,6 +166,17 @@ func (m *Manager) Create(cfg *Config) (*Instance, error) {
 		}
 	}
 
+	// Disabled mods are listed so Factorio doesn't enable them when installed
+	for _, mod := range cfg.Mods.Disabled {
+		modList.Mods = append(modList.Mods, struct {
+			Name    string "json:\"name\""
+			Enabled bool   "json:\"enabled\""
+		}{
+			Name:    mod,
+			Enabled: false,
+		})
+	}
+
 	modListPath := filepath.Join(instDir, "config", "mod-list.json")
 	if err := SaveJSON(modListPath, &modList); err != nil {
 		return nil, fmt.Errorf("saving mod list: %w", err)
@@ -248,6 +259,23 @@ func (m *Manager) UpdatePlayerData(inst *Instance, username, token string) error
 	return SaveJSON(playerDataPath, playerData)
 }
 
+// Load opens an existing instance and its configuration
+func (m *Manager) Load(name string) (*Instance, error) {
+	instDir := filepath.Join(m.baseDir, "instances", name)
+	if _, err := os.Stat(instDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("instance %s does not exist", name)
+	}
+
+	inst := &Instance{Dir: instDir, State: StateStopped}
+	cfg, err := LoadConfig(ConfigPath(inst))
+	if err != nil {
+		return nil, fmt.Errorf("loading instance configuration: %w", err)
+	}
+	inst.Config = cfg
+
+	return inst, nil
+}
+
 // Remove removes an instance and optionally creates a backup
 func (m *Manager) Remove(name string, backup bool) error {
 	instDir := filepath.Join(m.baseDir, "instances", name)
@@ -0,0 +1,325 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/WhyIsSandwich/factctl/internal/resolve"
+)
+
+// ModStatus describes one mod of an instance: whether it is installed,
+// where it came from and whether Factorio will load it
+type ModStatus struct {
+	// Mod name
+	Name string
+
+	// Installed version (empty if the mod is not installed)
+	Version string
+
+	// Source the mod was installed from according to the lockfile
+	// (empty if it was not locked)
+	Source string
+
+	// Whether mod-list.json enables the mod
+	Enabled bool
+
+	// Whether the mod is listed in mods.enabled; enabled mods that are not
+	// listed were pulled in as dependencies
+	Requested bool
+
+	// Whether the mod is listed in mods.disabled
+	Disabled bool
+
+	// Whether the mod ships with Factorio
+	Builtin bool
+}
+
+// ReadModList returns the enabled state of every mod in an instance's mod-list.json
+func ReadModList(inst *Instance) (map[string]bool, error) {
+	return readModListFile(filepath.Join(inst.Dir, "config", "mod-list.json"))
+}
+
+// readModListFile returns the enabled state of every mod in a mod-list.json
+func readModListFile(path string) (map[string]bool, error) {
+	var list struct {
+		Mods []struct {
+			Name    string `json:"name"`
+			Enabled bool   `json:"enabled"`
+		} `json:"mods"`
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading mod list: %w", err)
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing mod list: %w", err)
+	}
+
+	enabled := make(map[string]bool, len(list.Mods))
+	for _, mod := range list.Mods {
+		enabled[mod.Name] = mod.Enabled
+	}
+	return enabled, nil
+}
+
+// ModStatuses lists every mod that is installed, requested in the config or
+// present in mod-list.json, sorted by name
+func (mm *ModManager) ModStatuses(inst *Instance) ([]*ModStatus, error) {
+	installed, err := mm.ListMods(inst)
+	if err != nil {
+		return nil, err
+	}
+	modList, err := ReadModList(inst)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := LoadLockfile(inst)
+	if err != nil && err != ErrNoLockfile {
+		return nil, err
+	}
+
+	statuses := make(map[string]*ModStatus)
+	status := func(name string) *ModStatus {
+		if statuses[name] == nil {
+			statuses[name] = &ModStatus{Name: name, Enabled: modList[name], Builtin: isBuiltinMod(name)}
+		}
+		return statuses[name]
+	}
+
+	for _, info := range installed {
+		status(info.Name).Version = info.Version
+	}
+	for _, name := range inst.Config.Mods.Enabled {
+		status(name).Requested = true
+	}
+	for _, name := range inst.Config.Mods.Disabled {
+		status(name).Disabled = true
+	}
+	for name := range modList {
+		status(name)
+	}
+	if lock != nil {
+		for name, s := range statuses {
+			if locked := lock.Mod(name); locked != nil {
+				s.Source = locked.Source
+			}
+		}
+	}
+
+	result := make([]*ModStatus, 0, len(statuses))
+	for _, s := range statuses {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// AddMod enables a mod in the instance config, optionally with a source of its
+// own, saves the config and installs the enabled mods with their dependencies
+func (mm *ModManager) AddMod(ctx context.Context, inst *Instance, modName, sourceSpec string) ([]string, error) {
+	if err := validateModName(modName); err != nil {
+		return nil, err
+	}
+
+	cfg := inst.Config
+	if sourceSpec == "" && len(cfg.Mods.Sources) == 0 && !isBuiltinMod(modName) {
+		// Without any configured source the mod can only come from the portal
+		sourceSpec = "portal:" + modName
+	}
+	if sourceSpec != "" {
+		if _, err := resolve.ParseSource(sourceSpec); err != nil {
+			return nil, fmt.Errorf("invalid source: %w", err)
+		}
+		if cfg.Mods.Sources == nil {
+			cfg.Mods.Sources = make(map[string]string)
+		}
+		cfg.Mods.Sources[modName] = sourceSpec
+	}
+	cfg.Mods.EnableMod(modName)
+
+	if err := cfg.SaveConfig(ConfigPath(inst)); err != nil {
+		return nil, err
+	}
+
+	return mm.InstallModsRecursively(ctx, inst, cfg.Mods.Enabled)
+}
+
+// RemoveMod removes a mod from the instance config, the mods directory,
+// mod-list.json and the lockfile, along with a source named after it unless
+// other mods still come from that source. Mods it pulled in as dependencies
+// stay installed.
+func (mm *ModManager) RemoveMod(inst *Instance, modName string) error {
+	if modName == "base" {
+		return fmt.Errorf("cannot remove the base mod")
+	}
+
+	lock, err := LoadLockfile(inst)
+	if err != nil && err != ErrNoLockfile {
+		return err
+	}
+	if lock != nil {
+		lock.RemoveMod(modName)
+	}
+
+	cfg := inst.Config
+	requested := cfg.Mods.DisableMod(modName) || slices.Contains(cfg.Mods.Disabled, modName)
+	cfg.Mods.Disabled = removeName(cfg.Mods.Disabled, modName)
+	cfg.Mods.OptionalFor = removeName(cfg.Mods.OptionalFor, modName)
+	delete(cfg.Mods.Pin, modName)
+
+	// A source named after the mod goes with it once nothing else uses it
+	_, ownSource := cfg.Mods.Sources[modName]
+	dropSource := ownSource && !mm.sourceInUse(cfg, lock, modName)
+	if dropSource {
+		delete(cfg.Mods.Sources, modName)
+		if i := slices.Index(cfg.Mods.Priority, modName); i >= 0 {
+			cfg.Mods.Priority = slices.Delete(cfg.Mods.Priority, i, i+1)
+		}
+	}
+
+	installed := mm.isModInstalled(inst, modName)
+	if !requested && !ownSource && !installed {
+		return fmt.Errorf("mod '%s' is not part of instance '%s'", modName, cfg.Name)
+	}
+
+	if err := cfg.SaveConfig(ConfigPath(inst)); err != nil {
+		return err
+	}
+
+	if installed {
+		if err := mm.UninstallMod(inst, modName); err != nil {
+			return err
+		}
+	} else if err := mm.updateModList(inst, modName, false); err != nil {
+		return fmt.Errorf("updating mod list: %w", err)
+	}
+
+	// Keep the lockfile in step so --frozen doesn't bring the mod back
+	if lock == nil {
+		return nil
+	}
+	if dropSource {
+		delete(lock.Sources, modName)
+	}
+	return lock.Save(inst)
+}
+
+// sourceInUse reports whether any mod in the config or the lockfile still
+// comes from a source: a pin to it, a locked mod from it, or an enabled or
+// disabled mod it provides
+func (mm *ModManager) sourceInUse(cfg *Config, lock *Lockfile, sourceName string) bool {
+	for _, pinned := range cfg.Mods.Pin {
+		if pinned == sourceName {
+			return true
+		}
+	}
+	if lock != nil {
+		for _, locked := range lock.Mods {
+			if locked.Source == sourceName {
+				return true
+			}
+		}
+	}
+
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	for _, name := range slices.Concat(cfg.Mods.Enabled, cfg.Mods.Disabled) {
+		if _, ok := mm.sourceRegistry[name][sourceName]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SetModEnabled enables or disables an installed mod in both the instance
+// config and mod-list.json. A disabled mod moves to mods.disabled so later
+// runs of up keep it installed and disabled.
+func (mm *ModManager) SetModEnabled(inst *Instance, modName string, enabled bool) error {
+	if modName == "base" && !enabled {
+		return fmt.Errorf("cannot disable the base mod")
+	}
+	if !isBuiltinMod(modName) && !mm.isModInstalled(inst, modName) {
+		return fmt.Errorf("mod '%s' is not installed", modName)
+	}
+
+	cfg := inst.Config
+	if enabled {
+		cfg.Mods.EnableMod(modName)
+	} else {
+		cfg.Mods.KeepDisabled(modName)
+	}
+
+	if err := cfg.SaveConfig(ConfigPath(inst)); err != nil {
+		return err
+	}
+	if err := mm.updateModList(inst, modName, enabled); err != nil {
+		return fmt.Errorf("updating mod list: %w", err)
+	}
+	return nil
+}
+
+// validateModName rejects names that can't be a Factorio mod name
+func validateModName(name string) error {
+	if name == "" {
+		return fmt.Errorf("mod name cannot be empty")
+	}
+	if strings.ContainsAny(name, ":/\\") {
+		return fmt.Errorf("invalid mod name %q", name)
+	}
+	return nil
+}
+
+// Orphans returns the installed mods that are no longer needed: no mod in
+// mods.enabled or mods.disabled reaches them through required dependencies,
+// or through the optional dependencies the config asks for. Mods named in
+// removing are treated as already removed, so the result of a removal can be
+// shown before it happens.
+func (mm *ModManager) Orphans(inst *Instance, removing ...string) ([]string, error) {
+	g, err := mm.DependencyGraph(inst)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range removing {
+		if n := g.Node(name); n != nil && !n.Builtin {
+			n.Requested, n.Disabled, n.Installed = false, false, false
+		}
+	}
+	return g.unneeded(inst.Config.Mods.WantsOptional), nil
+}
+
+// PruneMods uninstalls mods and drops them from the lockfile
+func (mm *ModManager) PruneMods(inst *Instance, modNames []string) error {
+	if len(modNames) == 0 {
+		return nil
+	}
+
+	for _, modName := range modNames {
+		if err := mm.UninstallMod(inst, modName); err != nil {
+			return fmt.Errorf("removing mod '%s': %w", modName, err)
+		}
+	}
+
+	lock, err := LoadLockfile(inst)
+	if err == ErrNoLockfile {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, modName := range modNames {
+		lock.RemoveMod(modName)
+	}
+	return lock.Save(inst)
+}
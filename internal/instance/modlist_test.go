@@ -0,0 +1,453 @@
+package instance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/WhyIsSandwich/factctl/internal/resolve"
+)
+
+func TestModListCommands(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1",
+			Mods: ModsConfig{
+				Enabled:  []string{"base", "own-mod", "repo-mod"},
+				Sources:  map[string]string{"own-mod": "gh:test/own-mod", "repo": "gh:test/repo"},
+				Priority: []string{"own-mod", "repo"},
+			},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	for _, dir := range []string{"mods", "config"} {
+		if err := os.MkdirAll(filepath.Join(inst.Dir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s directory: %v", dir, err)
+		}
+	}
+	if err := inst.Config.SaveConfig(ConfigPath(inst)); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	manager := NewModManager(tmpDir)
+	for _, info := range []*ModInfo{
+		{Name: "own-mod", Version: "1.0.0", FactorioVersion: "1.1"},
+		{Name: "repo-mod", Version: "2.0.0", FactorioVersion: "1.1"},
+		{Name: "dep-mod", Version: "0.5.0", FactorioVersion: "1.1"},
+	} {
+		f, err := os.Create(filepath.Join(inst.Dir, "mods", info.Name+"_"+info.Version+".zip"))
+		if err != nil {
+			t.Fatalf("Failed to create mod file: %v", err)
+		}
+		if err := createTestModZip(f, info); err != nil {
+			t.Fatalf("Failed to create test mod zip: %v", err)
+		}
+		f.Close()
+		if err := manager.updateModList(inst, info.Name, true); err != nil {
+			t.Fatalf("updateModList() error = %v", err)
+		}
+	}
+
+	lock := &Lockfile{
+		FactorioVersion: "1.1",
+		Sources:         map[string]LockedSource{"own-mod": {Spec: "gh:test/own-mod"}, "repo": {Spec: "gh:test/repo"}},
+		Mods: []LockedMod{
+			{Name: "dep-mod", Version: "0.5.0", Source: PortalSourceName},
+			{Name: "own-mod", Version: "1.0.0", Source: "own-mod"},
+			{Name: "repo-mod", Version: "2.0.0", Source: "repo"},
+		},
+	}
+	if err := lock.Save(inst); err != nil {
+		t.Fatalf("Lockfile.Save() error = %v", err)
+	}
+
+	t.Run("list", func(t *testing.T) {
+		statuses, err := manager.ModStatuses(inst)
+		if err != nil {
+			t.Fatalf("ModStatuses() error = %v", err)
+		}
+
+		want := []ModStatus{
+			{Name: "base", Requested: true, Builtin: true},
+			{Name: "dep-mod", Version: "0.5.0", Source: PortalSourceName, Enabled: true},
+			{Name: "own-mod", Version: "1.0.0", Source: "own-mod", Enabled: true, Requested: true},
+			{Name: "repo-mod", Version: "2.0.0", Source: "repo", Enabled: true, Requested: true},
+		}
+		if len(statuses) != len(want) {
+			t.Fatalf("ModStatuses() returned %d mods, want %d", len(statuses), len(want))
+		}
+		for i := range want {
+			if *statuses[i] != want[i] {
+				t.Errorf("ModStatuses()[%d] = %+v, want %+v", i, *statuses[i], want[i])
+			}
+		}
+	})
+
+	t.Run("disable and enable", func(t *testing.T) {
+		if err := manager.SetModEnabled(inst, "repo-mod", false); err != nil {
+			t.Fatalf("SetModEnabled(false) error = %v", err)
+		}
+		checkModState(t, inst, "repo-mod", false)
+		if cfg, err := LoadConfig(ConfigPath(inst)); err != nil || !slices.Contains(cfg.Mods.Disabled, "repo-mod") {
+			t.Errorf("instance.json does not keep repo-mod disabled: %v", err)
+		}
+		modList, err := ReadModList(inst)
+		if _, listed := modList["repo-mod"]; err != nil || !listed {
+			t.Errorf("mod-list.json has no entry for the disabled mod: %v", err)
+		}
+
+		if err := manager.SetModEnabled(inst, "repo-mod", true); err != nil {
+			t.Fatalf("SetModEnabled(true) error = %v", err)
+		}
+		checkModState(t, inst, "repo-mod", true)
+		if cfg, err := LoadConfig(ConfigPath(inst)); err != nil || slices.Contains(cfg.Mods.Disabled, "repo-mod") {
+			t.Errorf("instance.json still disables repo-mod: %v", err)
+		}
+
+		if err := manager.SetModEnabled(inst, "missing-mod", true); err == nil {
+			t.Error("SetModEnabled() enabled a mod that is not installed")
+		}
+		if err := manager.SetModEnabled(inst, "base", false); err == nil {
+			t.Error("SetModEnabled() disabled base")
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := manager.RemoveMod(inst, "own-mod"); err != nil {
+			t.Fatalf("RemoveMod() error = %v", err)
+		}
+		checkModState(t, inst, "own-mod", false)
+
+		cfg, err := LoadConfig(ConfigPath(inst))
+		if err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		if _, ok := cfg.Mods.Sources["own-mod"]; ok || slices.Contains(cfg.Mods.Priority, "own-mod") {
+			t.Errorf("config still references the mod's own source: %+v", cfg.Mods)
+		}
+		if manager.isModInstalled(inst, "own-mod") {
+			t.Error("mod file is still installed")
+		}
+
+		lock, err := LoadLockfile(inst)
+		if err != nil {
+			t.Fatalf("LoadLockfile() error = %v", err)
+		}
+		if lock.Mod("own-mod") != nil || lock.Mod("repo-mod") == nil {
+			t.Errorf("lockfile mods = %+v, want own-mod removed", lock.Mods)
+		}
+		if _, ok := lock.Sources["own-mod"]; ok {
+			t.Error("lockfile still records the mod's own source")
+		}
+
+		if err := manager.RemoveMod(inst, "own-mod"); err == nil {
+			t.Error("RemoveMod() removed a mod twice")
+		}
+	})
+
+	t.Run("remove from a shared source", func(t *testing.T) {
+		// The multi source is named after one of the two mods it provides
+		inst.Config.Mods.Enabled = append(inst.Config.Mods.Enabled, "multi", "multi-extra")
+		inst.Config.Mods.Sources["multi"] = "gh:test/multi"
+		if err := inst.Config.SaveConfig(ConfigPath(inst)); err != nil {
+			t.Fatalf("SaveConfig() error = %v", err)
+		}
+		lock, err := LoadLockfile(inst)
+		if err != nil {
+			t.Fatalf("LoadLockfile() error = %v", err)
+		}
+		lock.Sources["multi"] = LockedSource{Spec: "gh:test/multi"}
+		lock.Mods = append(lock.Mods,
+			LockedMod{Name: "multi", Version: "1.0.0", Source: "multi"},
+			LockedMod{Name: "multi-extra", Version: "1.0.0", Source: "multi"},
+		)
+		if err := lock.Save(inst); err != nil {
+			t.Fatalf("Lockfile.Save() error = %v", err)
+		}
+
+		if err := manager.RemoveMod(inst, "multi"); err != nil {
+			t.Fatalf("RemoveMod() error = %v", err)
+		}
+
+		cfg, err := LoadConfig(ConfigPath(inst))
+		if err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		if slices.Contains(cfg.Mods.Enabled, "multi") || cfg.Mods.Sources["multi"] != "gh:test/multi" {
+			t.Errorf("config mods = %+v, want multi removed and its source kept", cfg.Mods)
+		}
+		if lock, err = LoadLockfile(inst); err != nil {
+			t.Fatalf("LoadLockfile() error = %v", err)
+		}
+		if _, ok := lock.Sources["multi"]; !ok || lock.Mod("multi") != nil || lock.Mod("multi-extra") == nil {
+			t.Errorf("lockfile = %+v, want multi removed and its source kept for multi-extra", lock)
+		}
+	})
+}
+
+// fakePortal serves portal: sources from a fixed list of releases, choosing
+// between them like the real portal does
+type fakePortal struct {
+	releases   []resolve.PortalRelease
+	downloaded []string
+}
+
+func (p *fakePortal) release(src *resolve.Source) *resolve.PortalRelease {
+	for i := len(p.releases) - 1; i >= 0; i-- {
+		r := &p.releases[i]
+		if src.Revision != "" && r.Version != src.Revision {
+			continue
+		}
+		if src.FactorioVersion != "" && !isVersionCompatible(src.FactorioVersion, r.InfoJSON.FactorioVersion) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+func (p *fakePortal) ResolveRevision(ctx context.Context, src *resolve.Source) (string, error) {
+	if r := p.release(src); r != nil {
+		return r.Version, nil
+	}
+	return "", resolve.ErrModNotFound
+}
+
+func (p *fakePortal) Fetch(ctx context.Context, src *resolve.Source, w io.Writer) (string, error) {
+	r := p.release(src)
+	if r == nil {
+		return "", resolve.ErrModNotFound
+	}
+	p.downloaded = append(p.downloaded, r.Version)
+	h := sha256.New()
+	err := createTestModZip(io.MultiWriter(w, h), &ModInfo{Name: src.ID, Version: r.Version, FactorioVersion: r.InfoJSON.FactorioVersion})
+	return hex.EncodeToString(h.Sum(nil)), err
+}
+
+func TestAddPortalMod(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1.110",
+			Mods:    ModsConfig{Enabled: []string{"base"}},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	for _, dir := range []string{"mods", "config"} {
+		if err := os.MkdirAll(filepath.Join(inst.Dir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s directory: %v", dir, err)
+		}
+	}
+	if err := inst.Config.SaveConfig(ConfigPath(inst)); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	// The newest release is for Factorio 2.0
+	portal := &fakePortal{releases: make([]resolve.PortalRelease, 2)}
+	portal.releases[0].Version, portal.releases[0].InfoJSON.FactorioVersion = "1.0.0", "1.1"
+	portal.releases[1].Version, portal.releases[1].InfoJSON.FactorioVersion = "2.0.0", "2.0"
+
+	manager := NewModManager(tmpDir)
+	manager.resolver.RegisterFetcher(resolve.SourcePortal, portal)
+	manager.portalReleases["helmod"] = portal.releases
+
+	if _, err := manager.AddMod(context.Background(), inst, "helmod", ""); err != nil {
+		t.Fatalf("AddMod() error = %v", err)
+	}
+
+	files := installedModFiles(inst, "helmod")
+	if len(files) != 1 || filepath.Base(files[0]) != "helmod_1.0.0.zip" {
+		t.Errorf("AddMod() installed %v, want helmod_1.0.0.zip for Factorio 1.1", files)
+	}
+	// The mod's own source must pick the release for 1.1 rather than leave it
+	// to the portal fallback
+	if slices.Contains(portal.downloaded, "2.0.0") {
+		t.Errorf("AddMod() downloaded %v, want only releases for Factorio 1.1", portal.downloaded)
+	}
+	lock, err := LoadLockfile(inst)
+	if err != nil {
+		t.Fatalf("LoadLockfile() error = %v", err)
+	}
+	if locked := lock.Mod("helmod"); locked == nil || locked.Source != "helmod" {
+		t.Errorf("lock entry = %+v, want helmod from its own source", locked)
+	}
+}
+
+func TestAddLocalMod(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1.110",
+			Mods:    ModsConfig{Enabled: []string{"base"}},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	for _, dir := range []string{"mods", "config"} {
+		if err := os.MkdirAll(filepath.Join(inst.Dir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s directory: %v", dir, err)
+		}
+	}
+	if err := inst.Config.SaveConfig(ConfigPath(inst)); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	modDir := filepath.Join(tmpDir, "src", "my-mod")
+	if err := os.MkdirAll(modDir, 0755); err != nil {
+		t.Fatalf("Failed to create mod directory: %v", err)
+	}
+	info := `{"name": "my-mod", "version": "0.1.0", "factorio_version": "1.1", "dependencies": ["base", "lib >= 1.1.0"]}`
+	if err := os.WriteFile(filepath.Join(modDir, "info.json"), []byte(info), 0644); err != nil {
+		t.Fatalf("Failed to write info.json: %v", err)
+	}
+
+	portal := &fakePortal{releases: make([]resolve.PortalRelease, 3)}
+	for i, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		portal.releases[i].Version, portal.releases[i].InfoJSON.FactorioVersion = version, "1.1"
+	}
+	manager := NewModManager(tmpDir)
+	manager.resolver.RegisterFetcher(resolve.SourcePortal, portal)
+	manager.portalReleases["lib"] = portal.releases
+	manager.portalReleases["my-mod"] = nil
+
+	if _, err := manager.AddMod(context.Background(), inst, "my-mod", "file:"+modDir); err != nil {
+		t.Fatalf("AddMod() error = %v", err)
+	}
+
+	linkPath := filepath.Join(inst.Dir, "mods", "my-mod")
+	if target, err := os.Readlink(linkPath); err != nil || target != modDir {
+		t.Errorf("mods/my-mod links to %q, %v, want %s", target, err, modDir)
+	}
+	if files := installedModFiles(inst, "lib"); len(files) != 1 || filepath.Base(files[0]) != "lib_1.2.0.zip" {
+		t.Errorf("AddMod() installed %v, want lib_1.2.0.zip", files)
+	}
+
+	lock, err := LoadLockfile(inst)
+	if err != nil {
+		t.Fatalf("LoadLockfile() error = %v", err)
+	}
+	locked := lock.Mod("my-mod")
+	if locked == nil || locked.Source != "my-mod" || locked.SHA256 != "" || !slices.Equal(locked.Dependencies, []string{"base", "lib >= 1.1.0"}) {
+		t.Errorf("lock entry = %+v, want my-mod from its own source with its dependencies", locked)
+	}
+
+	// A frozen install links the folder again
+	if err := os.Remove(linkPath); err != nil {
+		t.Fatalf("Failed to remove link: %v", err)
+	}
+	if _, err := manager.InstallModsFrozen(context.Background(), inst); err != nil {
+		t.Fatalf("InstallModsFrozen() error = %v", err)
+	}
+	if target, err := os.Readlink(linkPath); err != nil || target != modDir {
+		t.Errorf("InstallModsFrozen() linked %q, %v, want %s", target, err, modDir)
+	}
+}
+
+// checkModState checks that a mod is enabled or disabled in both instance.json and mod-list.json
+func checkModState(t *testing.T, inst *Instance, modName string, enabled bool) {
+	t.Helper()
+
+	cfg, err := LoadConfig(ConfigPath(inst))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if got := slices.Contains(cfg.Mods.Enabled, modName); got != enabled {
+		t.Errorf("instance.json enables %s = %v, want %v", modName, got, enabled)
+	}
+
+	modList, err := ReadModList(inst)
+	if err != nil {
+		t.Fatalf("ReadModList() error = %v", err)
+	}
+	if modList[modName] != enabled {
+		t.Errorf("mod-list.json enables %s = %v, want %v", modName, modList[modName], enabled)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1",
+			Mods: ModsConfig{
+				Enabled: []string{"base", "pack"},
+				Sources: map[string]string{"repo": "gh:test/repo"},
+			},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	if err := os.MkdirAll(filepath.Join(inst.Dir, "mods"), 0755); err != nil {
+		t.Fatalf("Failed to create mods directory: %v", err)
+	}
+
+	manager := NewModManager(tmpDir)
+	lock := &Lockfile{FactorioVersion: "1.1"}
+	for _, info := range []*ModInfo{
+		{Name: "pack", Version: "1.0.0", Dependencies: []string{"base", "core", "? extra"}},
+		{Name: "core", Version: "1.0.0", Dependencies: []string{"~ lib"}},
+		{Name: "lib", Version: "1.0.0"},
+		{Name: "extra", Version: "1.0.0"},
+		{Name: "old", Version: "1.0.0", Dependencies: []string{"lib"}},
+	} {
+		f, err := os.Create(filepath.Join(inst.Dir, "mods", info.Name+"_"+info.Version+".zip"))
+		if err != nil {
+			t.Fatalf("Failed to create mod file: %v", err)
+		}
+		if err := createTestModZip(f, info); err != nil {
+			t.Fatalf("Failed to create test mod zip: %v", err)
+		}
+		f.Close()
+		lock.Mods = append(lock.Mods, LockedMod{Name: info.Name, Version: info.Version, Source: PortalSourceName})
+	}
+	if err := lock.Save(inst); err != nil {
+		t.Fatalf("Lockfile.Save() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		optionalFor []string
+		removing    []string
+		want        []string
+	}{
+		{"unused mods", nil, nil, []string{"extra", "old"}},
+		{"wanted optional dependencies are kept", []string{"pack"}, nil, []string{"old"}},
+		{"after removing the root", nil, []string{"pack"}, []string{"core", "extra", "lib", "old"}},
+	}
+	for _, tt := range tests {
+		inst.Config.Mods.OptionalFor = tt.optionalFor
+		got, err := manager.Orphans(inst, tt.removing...)
+		if err != nil {
+			t.Fatalf("Orphans() error = %v", err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Orphans() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if err := manager.PruneMods(inst, []string{"extra", "old"}); err != nil {
+		t.Fatalf("PruneMods() error = %v", err)
+	}
+	if manager.isModInstalled(inst, "old") || !manager.isModInstalled(inst, "lib") {
+		t.Error("PruneMods() removed the wrong mods")
+	}
+	lock, err := LoadLockfile(inst)
+	if err != nil {
+		t.Fatalf("LoadLockfile() error = %v", err)
+	}
+	if lock.Mod("old") != nil || lock.Mod("pack") == nil {
+		t.Errorf("lockfile mods = %+v, want old removed", lock.Mods)
+	}
+	inst.Config.Mods.OptionalFor = nil
+	if got, err := manager.Orphans(inst); err != nil || len(got) != 0 {
+		t.Errorf("Orphans() after pruning = %v, %v, want none", got, err)
+	}
+}
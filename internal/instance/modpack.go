@@ -0,0 +1,221 @@
+package instance
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ModpackOptions describes the modpack exported from an instance
+type ModpackOptions struct {
+	// Name and version of the generated mod (default: <instance>-modpack 1.0.0)
+	Name    string
+	Version string
+
+	// Title shown in the game's mod list (default: the instance name)
+	Title string
+
+	// Add every installed mod zip, mod-list.json and mod-settings.dat next to
+	// the generated mod, ready to extract into a mods directory
+	Bundle bool
+}
+
+// defaults fills in the options left empty
+func (o *ModpackOptions) defaults(inst *Instance) {
+	if o.Name == "" {
+		o.Name = inst.Config.Name + "-modpack"
+	}
+	if o.Version == "" {
+		o.Version = "1.0.0"
+	}
+	if o.Title == "" {
+		o.Title = inst.Config.Name
+	}
+}
+
+// FileName returns the name Factorio expects for the exported zip
+func (o *ModpackOptions) FileName() string {
+	if o.Bundle {
+		return o.Name + "_" + o.Version + "-bundle.zip"
+	}
+	return o.Name + "_" + o.Version + ".zip"
+}
+
+// Modpack returns the info.json of a mod that depends on exactly the versions
+// of every mod enabled in the instance's mod-list.json. Built-in mods are
+// depended on without a version, as they come with the game.
+func (mm *ModManager) Modpack(inst *Instance, opts ModpackOptions) (*ModInfo, error) {
+	opts.defaults(inst)
+	if err := validateModName(opts.Name); err != nil {
+		return nil, err
+	}
+
+	enabled, err := ReadModList(inst)
+	if err != nil {
+		return nil, err
+	}
+	installed, err := mm.ListMods(inst)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &ModInfo{
+		Name:            opts.Name,
+		Version:         opts.Version,
+		Title:           opts.Title,
+		Author:          "factctl",
+		Description:     fmt.Sprintf("Mods of the factctl instance '%s'", inst.Config.Name),
+		FactorioVersion: factorioRelease(inst.Config.Version),
+	}
+
+	versions := make(map[string]string, len(installed))
+	for _, info := range installed {
+		versions[info.Name] = info.Version
+	}
+
+	var names []string
+	for name, on := range enabled {
+		if on && !isBuiltinMod(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range builtinModNames {
+		if enabled[name] {
+			pack.Dependencies = append(pack.Dependencies, name)
+		}
+	}
+	for _, name := range names {
+		version, ok := versions[name]
+		if !ok {
+			return nil, fmt.Errorf("mod '%s' is enabled but not installed", name)
+		}
+		pack.Dependencies = append(pack.Dependencies, fmt.Sprintf("%s = %s", name, version))
+	}
+	return pack, nil
+}
+
+// factorioRelease returns the major.minor part of a Factorio version, as
+// info.json expects it
+func factorioRelease(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// ExportModpack writes the modpack of an instance as a mod zip to w. With
+// opts.Bundle, the zip instead holds the modpack zip together with every
+// installed mod zip, mod-list.json and mod-settings.dat.
+func (mm *ModManager) ExportModpack(inst *Instance, w io.Writer, opts ModpackOptions) error {
+	opts.defaults(inst)
+	pack, err := mm.Modpack(inst, opts)
+	if err != nil {
+		return err
+	}
+
+	if !opts.Bundle {
+		return writeModpackZip(w, pack)
+	}
+
+	zipWriter := zip.NewWriter(w)
+
+	dst, err := zipWriter.Create(pack.Name + "_" + pack.Version + ".zip")
+	if err != nil {
+		return fmt.Errorf("creating file in zip: %w", err)
+	}
+	if err := writeModpackZip(dst, pack); err != nil {
+		return err
+	}
+
+	modDir := filepath.Join(inst.Dir, "mods")
+	entries, err := os.ReadDir(modDir)
+	if err != nil {
+		return fmt.Errorf("reading mods directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !strings.HasSuffix(name, ".zip") {
+			if entry.IsDir() || entry.Type()&os.ModeSymlink != 0 {
+				fmt.Printf("  → Warning: Skipping '%s': only mod zips can be bundled\n", name)
+			}
+			continue
+		}
+		if err := addFileToZip(zipWriter, filepath.Join(modDir, name), name); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range []string{filepath.Join(inst.Dir, "config", "mod-list.json"), ModSettingsPath(inst)} {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+		if err := addFileToZip(zipWriter, file, filepath.Base(file)); err != nil {
+			return err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("closing zip writer: %w", err)
+	}
+	return nil
+}
+
+// writeModpackZip writes a mod zip holding only an info.json
+func writeModpackZip(w io.Writer, pack *ModInfo) error {
+	data, err := json.MarshalIndent(pack, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding info.json: %w", err)
+	}
+
+	zipWriter := zip.NewWriter(w)
+	// Factorio expects files to be in a folder named after the mod
+	dst, err := zipWriter.Create(pack.Name + "/info.json")
+	if err != nil {
+		return fmt.Errorf("creating file in zip: %w", err)
+	}
+	if _, err := dst.Write(data); err != nil {
+		return fmt.Errorf("writing info.json: %w", err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("closing zip writer: %w", err)
+	}
+	return nil
+}
+
+// addFileToZip stores a file in a zip under name. Mod zips are already
+// compressed, so everything is stored as is.
+func addFileToZip(zipWriter *zip.Writer, path, name string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: stat.ModTime(),
+	})
+	if err != nil {
+		return fmt.Errorf("creating file in zip: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("adding %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package instance
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExportModpack(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{Name: "friends", Version: "2.0.28"},
+		Dir:    filepath.Join(tmpDir, "instances", "friends"),
+	}
+	for _, dir := range []string{"mods", "config"} {
+		if err := os.MkdirAll(filepath.Join(inst.Dir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s directory: %v", dir, err)
+		}
+	}
+
+	for _, info := range []*ModInfo{
+		{Name: "a", Version: "1.2.0"},
+		{Name: "b", Version: "0.3.1"},
+	} {
+		f, err := os.Create(filepath.Join(inst.Dir, "mods", info.Name+"_"+info.Version+".zip"))
+		if err != nil {
+			t.Fatalf("Failed to create mod file: %v", err)
+		}
+		if err := createTestModZip(f, info); err != nil {
+			t.Fatalf("Failed to create test mod zip: %v", err)
+		}
+		f.Close()
+	}
+
+	modList := `{"mods": [
+		{"name": "base", "enabled": true},
+		{"name": "quality", "enabled": true},
+		{"name": "a", "enabled": true},
+		{"name": "b", "enabled": false}
+	]}`
+	if err := os.WriteFile(filepath.Join(inst.Dir, "config", "mod-list.json"), []byte(modList), 0644); err != nil {
+		t.Fatalf("Failed to write mod list: %v", err)
+	}
+
+	manager := NewModManager(tmpDir)
+	pack, err := manager.Modpack(inst, ModpackOptions{})
+	if err != nil {
+		t.Fatalf("Modpack() error = %v", err)
+	}
+	if pack.Name != "friends-modpack" || pack.FactorioVersion != "2.0" {
+		t.Errorf("Modpack() = %s for Factorio %s, want friends-modpack for 2.0", pack.Name, pack.FactorioVersion)
+	}
+	if want := []string{"base", "quality", "a = 1.2.0"}; !slices.Equal(pack.Dependencies, want) {
+		t.Errorf("Modpack() dependencies = %q, want %q", pack.Dependencies, want)
+	}
+
+	t.Run("mod", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := manager.ExportModpack(inst, &buf, ModpackOptions{Name: "pack", Version: "2.0.0"}); err != nil {
+			t.Fatalf("ExportModpack() error = %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("ExportModpack() wrote an invalid zip: %v", err)
+		}
+		if len(zr.File) != 1 || zr.File[0].Name != "pack/info.json" {
+			t.Errorf("ExportModpack() files = %v, want pack/info.json", zr.File)
+		}
+	})
+
+	t.Run("bundle", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := manager.ExportModpack(inst, &buf, ModpackOptions{Bundle: true}); err != nil {
+			t.Fatalf("ExportModpack() error = %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("ExportModpack() wrote an invalid zip: %v", err)
+		}
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		want := []string{"friends-modpack_1.0.0.zip", "a_1.2.0.zip", "b_0.3.1.zip", "mod-list.json"}
+		if !slices.Equal(names, want) {
+			t.Errorf("ExportModpack() files = %q, want %q", names, want)
+		}
+	})
+
+	// A pack can't pin a mod that isn't there
+	if err := os.Remove(filepath.Join(inst.Dir, "mods", "a_1.2.0.zip")); err != nil {
+		t.Fatalf("Failed to remove mod: %v", err)
+	}
+	if _, err := manager.Modpack(inst, ModpackOptions{}); err == nil {
+		t.Error("Modpack() with an enabled mod missing succeeded, want an error")
+	}
+}
@@ -2,24 +2,26 @@ package instance
 
 import (
 	"archive/zip"
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
+	"io/fs"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"crypto/sha256"
-	"encoding/hex"
-
 	"github.com/WhyIsSandwich/factctl/internal/auth"
+	"github.com/WhyIsSandwich/factctl/internal/cache"
 	"github.com/WhyIsSandwich/factctl/internal/resolve"
+	"github.com/WhyIsSandwich/factctl/internal/solver"
 	"github.com/blang/semver"
 )
 
@@ -36,54 +38,76 @@ type ModInfo struct {
 	FactorioVersion string   `json:"factorio_version,omitempty"`
 }
 
-// CacheEntry represents a cached download
-type CacheEntry struct {
-	URL      string    `json:"url"`
-	Hash     string    `json:"hash"`
-	FilePath string    `json:"file_path"`
-	Size     int64     `json:"size"`
-	CachedAt time.Time `json:"cached_at"`
+// registryEntry locates a mod inside a source archive in the download cache.
+// The mod is only extracted when it is installed.
+type registryEntry struct {
+	archive string // Path of the downloaded source archive
+	dir     string // Folder containing the mod inside the archive
+	local   string // Local mod folder, linked into instances instead of extracted
+	info    *ModInfo
 }
 
 // ModManager handles mod installation and management
 type ModManager struct {
 	baseDir  string
 	resolver *resolve.Resolver
-	modInfos map[string]*ModInfo
+	portal   *resolve.PortalFetcher
+	// Content-addressed store of downloaded archives
+	downloads *cache.Store
+	// Number of parallel downloads; 0 defers to the instance config
+	workers int
+	// Serializes read-modify-write updates of mod-list.json
+	modListMu sync.Mutex
+	// Guards workers and all maps below, which are shared by download workers
 	mu       sync.RWMutex
-	cacheDir string
-	// Source registry map: modName -> sourceName -> modData
-	sourceRegistry map[string]map[string][]byte
+	modInfos map[string]*ModInfo
+	// Source registry map: modName -> sourceName -> location in the downloaded source archive
+	sourceRegistry map[string]map[string]*registryEntry
+	// Resolved revision (commit SHA) of each loaded source: sourceName -> revision
+	sourceRevisions map[string]string
+	// Lock entries for mods installed during the current run: modName -> entry
+	lockEntries map[string]*LockedMod
+	// Portal releases listed during the current run: modName -> releases
+	portalReleases map[string][]resolve.PortalRelease
 }
 
 // NewModManager creates a new mod manager
 func NewModManager(baseDir string) *ModManager {
-	cacheDir := filepath.Join(baseDir, "cache", "downloads")
-	return &ModManager{
-		baseDir:        baseDir,
-		cacheDir:       cacheDir,
-		resolver:       resolve.NewResolver(),
-		modInfos:       make(map[string]*ModInfo),
-		sourceRegistry: make(map[string]map[string][]byte),
-	}
+	mm := &ModManager{
+		baseDir:         baseDir,
+		downloads:       cache.New(CacheDir(baseDir)),
+		resolver:        resolve.NewDefaultResolver(),
+		modInfos:        make(map[string]*ModInfo),
+		sourceRegistry:  make(map[string]map[string]*registryEntry),
+		sourceRevisions: make(map[string]string),
+		lockEntries:     make(map[string]*LockedMod),
+		portalReleases:  make(map[string][]resolve.PortalRelease),
+	}
+
+	// Portal downloads need the user's credentials
+	mm.portal = resolve.NewPortalFetcher().WithCredentials(mm.portalCredentials)
+	mm.resolver.RegisterFetcher(resolve.SourcePortal, mm.portal)
+
+	// GitHub API requests use the user's token when there is one, and keep
+	// responses so unchanged refs don't count against the rate limit
+	github := resolve.NewGitHubClient().
+		WithToken(mm.githubToken).
+		WithCache(filepath.Join(baseDir, "cache", "github"))
+	mm.resolver.RegisterFetcher(resolve.SourceGitHub, resolve.NewGitHubFetcher().WithClient(github))
+	mm.resolver.RegisterFetcher(resolve.SourceGitHubPR, resolve.NewGitHubPRFetcher().WithClient(github))
+	mm.resolver.RegisterFetcher(resolve.SourceGitHubRelease, resolve.NewGitHubReleaseFetcher().WithClient(github))
+
+	return mm
 }
 
-// InstallMod installs a mod for an instance
-func (mm *ModManager) InstallMod(ctx context.Context, inst *Instance, modSpec string) error {
-	// Prepare mod directory
-	modDir := filepath.Join(inst.Dir, "mods")
-	if err := os.MkdirAll(modDir, 0755); err != nil {
-		return fmt.Errorf("creating mod directory: %w", err)
-	}
-
-	// Check if this is a direct source specification (portal:, github:, etc.)
-	if strings.Contains(modSpec, ":") && (strings.HasPrefix(modSpec, "portal:") || strings.HasPrefix(modSpec, "github:") || strings.HasPrefix(modSpec, "ghpr:") || strings.HasPrefix(modSpec, "git:")) {
-		// This is a direct source specification, download directly
-		return mm.installModFromDirectSource(ctx, inst, modSpec)
-	}
+// CacheDir returns the directory of the download cache under a base directory
+func CacheDir(baseDir string) string {
+	return filepath.Join(baseDir, "cache", "store")
+}
 
-	// This is a mod name that needs to be resolved from sources
-	return mm.installModFromRegistry(ctx, inst, modSpec)
+// Cache returns the download cache
+func (mm *ModManager) Cache() *cache.Store {
+	return mm.downloads
 }
 
 // UninstallMod removes a mod from an instance
@@ -101,6 +125,12 @@ func (mm *ModManager) UninstallMod(inst *Instance, modName string) error {
 		return fmt.Errorf("finding mod files: %w", err)
 	}
 
+	// Local mods are symlinked directories; only the link is removed
+	linkPath := filepath.Join(modDir, modName)
+	if fi, err := os.Lstat(linkPath); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		matches = append(matches, linkPath)
+	}
+
 	if len(matches) == 0 {
 		return fmt.Errorf("mod %s not found", modName)
 	}
@@ -122,43 +152,54 @@ func (mm *ModManager) UninstallMod(inst *Instance, modName string) error {
 
 // BuildSourceRegistry loads all configured sources and builds a comprehensive map of available mods
 func (mm *ModManager) BuildSourceRegistry(ctx context.Context, inst *Instance) error {
+	return mm.buildSourceRegistry(ctx, inst, nil)
+}
+
+// buildSourceRegistry loads all configured sources, pinning each source to the
+// revision given in pins when one is present
+func (mm *ModManager) buildSourceRegistry(ctx context.Context, inst *Instance, pins map[string]string) error {
 	fmt.Println("Building source registry from configured sources...")
 
 	// Clear existing registry
 	mm.mu.Lock()
-	mm.sourceRegistry = make(map[string]map[string][]byte)
+	mm.sourceRegistry = make(map[string]map[string]*registryEntry)
+	mm.sourceRevisions = make(map[string]string)
 	mm.mu.Unlock()
 
-	// Load each source and build the registry
-	for sourceName, sourceURL := range inst.Config.Mods.Sources {
-		fmt.Printf("  → Loading source '%s' (%s)...\n", sourceName, sourceURL)
+	// Load sources in parallel, then add them to the registry in name order so
+	// the result doesn't depend on which download finishes first
+	var sourceNames []string
+	for sourceName := range inst.Config.Mods.Sources {
+		sourceNames = append(sourceNames, sourceName)
+	}
+	sort.Strings(sourceNames)
 
-		// Download the source repository
-		var repoBuf bytes.Buffer
-		if err := mm.downloadMod(ctx, sourceURL, &repoBuf); err != nil {
-			fmt.Printf("  → Warning: Failed to download source '%s': %v\n", sourceName, err)
-			continue
+	loaded := make([]*loadedSource, len(sourceNames))
+	forEach(mm.workerCount(inst), sourceNames, func(i int, sourceName string) error {
+		source, err := mm.loadSource(ctx, sourceName, inst.Config.Mods.Sources[sourceName], pins[sourceName], inst.Config.Version)
+		if err != nil {
+			fmt.Printf("  → Warning: %v\n", err)
+			return err
 		}
+		loaded[i] = source
+		return nil
+	})
 
-		// Extract all mods from this source
-		mods, err := mm.extractAllModsFromRepository(repoBuf.Bytes())
-		if err != nil {
-			fmt.Printf("  → Warning: Failed to extract mods from '%s': %v\n", sourceName, err)
+	mm.mu.Lock()
+	for i, source := range loaded {
+		if source == nil {
 			continue
 		}
-
-		// Add mods to registry
-		mm.mu.Lock()
-		for modName, modData := range mods {
+		sourceName := sourceNames[i]
+		mm.sourceRevisions[sourceName] = source.revision
+		for modName, entry := range source.mods {
 			if mm.sourceRegistry[modName] == nil {
-				mm.sourceRegistry[modName] = make(map[string][]byte)
+				mm.sourceRegistry[modName] = make(map[string]*registryEntry)
 			}
-			mm.sourceRegistry[modName][sourceName] = modData
+			mm.sourceRegistry[modName][sourceName] = entry
 		}
-		mm.mu.Unlock()
-
-		fmt.Printf("  → Found %d mods in source '%s'\n", len(mods), sourceName)
 	}
+	mm.mu.Unlock()
 
 	// Count total unique mods
 	mm.mu.RLock()
@@ -169,88 +210,174 @@ func (mm *ModManager) BuildSourceRegistry(ctx context.Context, inst *Instance) e
 	return nil
 }
 
+// loadedSource is a downloaded and indexed source
+type loadedSource struct {
+	revision string
+	mods     map[string]*registryEntry
+}
+
+// loadSource downloads one configured source into the cache and indexes its mods.
+// A non-empty revision pins the source to that commit. Portal sources pick the
+// newest release for factorioVersion.
+func (mm *ModManager) loadSource(ctx context.Context, sourceName, sourceURL, revision, factorioVersion string) (*loadedSource, error) {
+	if revision != "" {
+		fmt.Printf("  → Loading source '%s' (%s) at locked revision %s...\n", sourceName, sourceURL, shortRevision(revision))
+	} else {
+		fmt.Printf("  → Loading source '%s' (%s)...\n", sourceName, sourceURL)
+	}
+
+	src, err := resolve.ParseSource(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source '%s': %w", sourceName, err)
+	}
+	src.Revision = revision
+	src.FactorioVersion = factorioVersion
+
+	// Local folders are linked into the instance rather than downloaded
+	if src.Type == resolve.SourceFile {
+		if fi, err := os.Stat(src.Path); err == nil && fi.IsDir() {
+			return mm.loadLocalSource(sourceName, src)
+		}
+	}
+
+	// Download the source repository into the cache
+	fmt.Printf("  → Downloading '%s'...\n", sourceURL)
+	archive, resolved, err := mm.downloadSource(ctx, src, sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download source '%s': %w", sourceName, err)
+	}
+
+	// Index the mods in this source; they are extracted at install time
+	mods, err := indexSourceArchive(archive, src.SubPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mods from '%s': %w", sourceName, err)
+	}
+
+	fmt.Printf("  → Found %d mods in source '%s'\n", len(mods), sourceName)
+	return &loadedSource{revision: resolved, mods: mods}, nil
+}
+
+// loadLocalSource indexes a local mod folder. The folder is linked as it is, so
+// it has no revision and can't be pinned by sha256.
+func (mm *ModManager) loadLocalSource(sourceName string, src *resolve.Source) (*loadedSource, error) {
+	if src.SHA256 != "" {
+		return nil, fmt.Errorf("source '%s' is a local folder, which is linked rather than copied and cannot be pinned by sha256", sourceName)
+	}
+	dir, err := filepath.Abs(src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source '%s': %w", sourceName, err)
+	}
+
+	info, err := mm.readModInfoFromDirectory(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mod from '%s': %w", sourceName, err)
+	}
+
+	fmt.Printf("  → Found local mod '%s' in source '%s'\n", info.Name, sourceName)
+	return &loadedSource{mods: map[string]*registryEntry{info.Name: {local: dir, info: info}}}, nil
+}
+
 // InstallModsRecursively installs mods and all their dependencies recursively
+// and records the resolved set in the instance lockfile
 func (mm *ModManager) InstallModsRecursively(ctx context.Context, inst *Instance, modNames []string) ([]string, error) {
+	return mm.installMods(ctx, inst, modNames, nil)
+}
+
+// installMods resolves and installs mods and their dependencies. Installed
+// copies are kept unless the mod is in update, in which case newer versions
+// from the sources and the portal are preferred.
+func (mm *ModManager) installMods(ctx context.Context, inst *Instance, modNames []string, update map[string]bool) ([]string, error) {
 	// Build source registry upfront to avoid repeated API calls
 	if err := mm.BuildSourceRegistry(ctx, inst); err != nil {
 		return nil, fmt.Errorf("building source registry: %w", err)
 	}
 
-	// Track installed mods to avoid duplicates
-	installedMods := make(map[string]bool)
-	var installQueue []string
-	var errors []error
-
-	// Add initial mods to queue
-	for _, modName := range modNames {
-		if !installedMods[modName] {
-			installQueue = append(installQueue, modName)
-		}
+	// The previous lockfile lets us keep provenance for mods that are already installed
+	previous, err := LoadLockfile(inst)
+	if err != nil && err != ErrNoLockfile {
+		fmt.Printf("Warning: Ignoring unreadable lockfile: %v\n", err)
 	}
 
-	// Process queue until empty
-	for len(installQueue) > 0 {
-		modName := installQueue[0]
-		installQueue = installQueue[1:]
+	mm.mu.Lock()
+	mm.lockEntries = make(map[string]*LockedMod)
+	mm.mu.Unlock()
 
-		if installedMods[modName] {
-			continue // Already installed
-		}
+	// Pick one version of every mod so that all constraints hold
+	fmt.Printf("Resolving dependencies...\n")
+	registry := &modRegistry{ctx: ctx, mm: mm, inst: inst, previous: previous, update: update}
+	registry.prefetchPortal(modNames)
+	mods := &inst.Config.Mods
+	solution, err := solver.Solve(&solver.Problem{
+		Roots:           modNames,
+		FactorioVersion: inst.Config.Version,
+		Builtin:         builtinModNames,
+		Wanted:          mods.Disabled,
+		Optional:        mods.WantsOptional,
+		Registry:        registry,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resolving dependencies: %w", err)
+	}
 
-		// Check if mod is already installed
-		if mm.isModInstalled(inst, modName) {
-			fmt.Printf("Mod '%s' already installed, skipping...\n", modName)
-			installedMods[modName] = true
-			continue
-		}
+	// Optional dependencies and disabled mods that don't fit are left out
+	for _, modName := range slices.Sorted(maps.Keys(solution.Skipped)) {
+		reason := strings.ReplaceAll(solution.Skipped[modName].Error(), "\n", "\n    ")
+		fmt.Printf("Warning: Skipping '%s': %s\n", modName, reason)
+	}
 
-		// Skip built-in mods (they're always available)
-		if isBuiltinMod(modName) {
-			fmt.Printf("Mod '%s' is built-in, skipping...\n", modName)
-			installedMods[modName] = true
-			continue
-		}
+	// Report where each mod comes from before the parallel installs start
+	fmt.Printf("Selected sources:\n")
+	for _, modName := range solution.Names() {
+		c := solution.Mods[modName]
+		fmt.Printf("  → %s %s from '%s' (%s)\n", modName, c.Version, c.Source, registry.reason(c))
+	}
 
-		fmt.Printf("Installing mod '%s'...\n", modName)
+	// Install dependencies before the mods that need them; mods at the same
+	// depth are independent and are installed in parallel
+	var result []string
+	var errors []error
+	workers := mm.workerCount(inst)
 
-		// Try to install the mod using the optimized registry
-		if err := mm.installModFromRegistry(ctx, inst, modName); err != nil {
-			fmt.Printf("  → Registry installation failed for '%s': %v\n", modName, err)
-			// If installation failed, try to resolve from portal as fallback
-			if portalErr := mm.installModFromPortal(ctx, inst, modName); portalErr != nil {
-				fmt.Printf("  → Portal fallback also failed for '%s': %v\n", modName, portalErr)
-				errors = append(errors, fmt.Errorf("failed to install mod '%s': %w (portal fallback: %v)", modName, err, portalErr))
+	for _, level := range installLevels(solution, modNames) {
+		errs := forEach(workers, level, func(_ int, modName string) error {
+			return mm.installCandidate(ctx, inst, solution.Mods[modName], previous)
+		})
+		for i, modName := range level {
+			if errs[i] != nil {
+				errors = append(errors, fmt.Errorf("failed to install mod '%s': %w", modName, errs[i]))
 				continue
-			} else {
-				fmt.Printf("  → Portal fallback succeeded for '%s'\n", modName)
 			}
+			result = append(result, modName)
 		}
+	}
 
-		// Mark as installed
-		installedMods[modName] = true
-
-		// Get dependencies for this mod
-		dependencies, err := mm.getModDependencies(ctx, inst, modName)
-		if err != nil {
-			fmt.Printf("Warning: Could not get dependencies for '%s': %v\n", modName, err)
-			continue
+	// Disabled mods stay installed but switched off
+	if err := mm.disableMods(inst); err != nil {
+		errors = append(errors, err)
+	}
+	for _, modName := range mods.Disabled {
+		for _, dependent := range requiredBy(solution, modName) {
+			if !slices.Contains(mods.Disabled, dependent) {
+				fmt.Printf("Warning: '%s' is disabled but '%s' needs it\n", modName, dependent)
+			}
 		}
+	}
 
-		// Add dependencies to queue (avoid circular dependencies)
-		for _, dep := range dependencies {
-			if !installedMods[dep] && dep != modName {
-				fmt.Printf("  → Queueing dependency: %s\n", dep)
-				installQueue = append(installQueue, dep)
-			} else if dep == modName {
-				fmt.Printf("  → Skipping circular dependency: %s\n", dep)
+	// Keep the resolved dependencies so the graph can be shown later
+	mm.mu.Lock()
+	for modName, entry := range mm.lockEntries {
+		if c := solution.Mods[modName]; c != nil {
+			entry.Dependencies = make([]string, len(c.Dependencies))
+			for i, dep := range c.Dependencies {
+				entry.Dependencies[i] = dep.String()
 			}
 		}
 	}
+	mm.mu.Unlock()
 
-	// Convert map to slice
-	var result []string
-	for modName := range installedMods {
-		result = append(result, modName)
+	// Record what was installed so the same set can be reproduced with --frozen
+	if err := mm.writeLockfile(inst); err != nil {
+		errors = append(errors, fmt.Errorf("writing lockfile: %w", err))
 	}
 
 	// Return errors if any
@@ -269,120 +396,315 @@ func (mm *ModManager) InstallModsRecursively(ctx context.Context, inst *Instance
 	return result, nil
 }
 
-// getModDependencies extracts dependencies from a mod's info.json
-func (mm *ModManager) getModDependencies(ctx context.Context, inst *Instance, modName string) ([]string, error) {
-	// Check if we have mod info cached
-	mm.mu.Lock()
-	modInfo, exists := mm.modInfos[modName]
-	mm.mu.Unlock()
+// disableMods switches off the mods in mods.disabled in mod-list.json
+func (mm *ModManager) disableMods(inst *Instance) error {
+	for _, modName := range inst.Config.Mods.Disabled {
+		if err := mm.updateModList(inst, modName, false); err != nil {
+			return fmt.Errorf("disabling mod '%s': %w", modName, err)
+		}
+	}
+	return nil
+}
 
-	if !exists {
-		// Try to find the mod file and extract info
-		modDir := filepath.Join(inst.Dir, "mods")
-		pattern := filepath.Join(modDir, fmt.Sprintf("%s_*.zip", modName))
-		matches, err := filepath.Glob(pattern)
-		if err != nil || len(matches) == 0 {
-			return nil, fmt.Errorf("mod file not found: %s", modName)
+// requiredBy returns the mods in a solution that require a mod, sorted
+func requiredBy(solution *solver.Solution, modName string) []string {
+	var dependents []string
+	for _, name := range solution.Names() {
+		for _, dep := range solution.Mods[name].Dependencies {
+			if dep.Name == modName && dep.IsRequired() {
+				dependents = append(dependents, name)
+				break
+			}
 		}
+	}
+	return dependents
+}
 
-		// Read and parse the mod file
-		modData, err := os.ReadFile(matches[0])
-		if err != nil {
-			return nil, fmt.Errorf("reading mod file: %w", err)
+// installCandidate installs the version of a mod the solver picked
+func (mm *ModManager) installCandidate(ctx context.Context, inst *Instance, candidate *solver.Candidate, previous *Lockfile) error {
+	modName := candidate.Name
+
+	switch candidate.Source {
+	case InstalledSourceName:
+		fmt.Printf("Mod '%s' %s already installed, skipping...\n", modName, candidate.Version)
+		if err := mm.lockInstalledMod(inst, modName, previous); err != nil {
+			fmt.Printf("Warning: Could not record '%s' in lockfile: %v\n", modName, err)
+		}
+		return nil
+	case PortalSourceName:
+		fmt.Printf("Installing mod '%s' %s...\n", modName, candidate.Version)
+		return mm.installModFromPortal(ctx, inst, modName, candidate.Version.String())
+	default:
+		fmt.Printf("Installing mod '%s' %s...\n", modName, candidate.Version)
+		return mm.installModFromRegistrySource(inst, modName, candidate.Source)
+	}
+}
+
+// InstallModsFrozen installs exactly the mods recorded in the instance lockfile.
+// Sources are loaded at their locked revisions, portal mods at their locked
+// releases, and every mod file must match its locked SHA256. Any difference
+// between the lockfile, the config and the installed mods is an error.
+func (mm *ModManager) InstallModsFrozen(ctx context.Context, inst *Instance) ([]string, error) {
+	lock, err := LoadLockfile(inst)
+	if err != nil {
+		if err == ErrNoLockfile {
+			return nil, fmt.Errorf("%w at %s\nHint: Run 'factctl up' without --frozen to generate one", err, LockfilePath(inst))
+		}
+		return nil, err
+	}
+
+	// Compare the lockfile with the config and with what is on disk before downloading anything
+	drift := lock.CheckDrift(inst.Config)
+	installed, err := mm.ListMods(inst)
+	if err != nil {
+		return nil, fmt.Errorf("listing installed mods: %w", err)
+	}
+	for _, info := range installed {
+		locked := lock.Mod(info.Name)
+		if locked == nil {
+			drift = append(drift, fmt.Sprintf("mod '%s' is installed but not in the lockfile", info.Name))
+		} else if locked.Version != info.Version {
+			drift = append(drift, fmt.Sprintf("mod '%s' is installed at %s but locked at %s", info.Name, info.Version, locked.Version))
+		}
+	}
+	if len(drift) > 0 {
+		return nil, fmt.Errorf("lockfile does not match the instance:\n  - %s", strings.Join(drift, "\n  - "))
+	}
+
+	// Load sources pinned to their locked revisions
+	pins := make(map[string]string)
+	for name, src := range lock.Sources {
+		pins[name] = src.Revision
+	}
+	if err := mm.buildSourceRegistry(ctx, inst, pins); err != nil {
+		return nil, fmt.Errorf("building source registry: %w", err)
+	}
+
+	errs := forEach(mm.workerCount(inst), lock.Mods, func(_ int, locked LockedMod) error {
+		return mm.installLockedMod(ctx, inst, &locked)
+	})
+
+	// Report the first failure in lockfile order
+	var result []string
+	for i, locked := range lock.Mods {
+		if errs[i] != nil {
+			return result, errs[i]
+		}
+		result = append(result, locked.Name)
+	}
+
+	return result, mm.disableMods(inst)
+}
+
+// installLockedMod installs one lockfile entry, or checks the hash of the
+// installed file if it is already present
+func (mm *ModManager) installLockedMod(ctx context.Context, inst *Instance, locked *LockedMod) error {
+	// Local mods are locked without a hash and linked again as they are
+	mm.mu.RLock()
+	entry := mm.sourceRegistry[locked.Name][locked.Source]
+	mm.mu.RUnlock()
+	if entry != nil && entry.local != "" {
+		if entry.info.Version != locked.Version {
+			return fmt.Errorf("local mod '%s' has version %s but is locked at %s", locked.Name, entry.info.Version, locked.Version)
+		}
+		if err := linkLocalMod(inst, entry); err != nil {
+			return err
 		}
+		return mm.updateModList(inst, locked.Name, true)
+	}
+
+	modPath := filepath.Join(inst.Dir, "mods", fmt.Sprintf("%s_%s.zip", locked.Name, locked.Version))
 
-		modInfo, err = mm.extractModInfo(bytes.NewReader(modData))
+	// Already installed files only need their hash checked
+	if _, err := os.Stat(modPath); err == nil {
+		hash, err := hashFile(modPath)
 		if err != nil {
-			return nil, fmt.Errorf("extracting mod info: %w", err)
+			return fmt.Errorf("hashing installed mod '%s': %w", locked.Name, err)
 		}
+		if hash != locked.SHA256 {
+			return fmt.Errorf("installed mod '%s' does not match the lockfile (sha256 %s, locked %s)", locked.Name, hash, locked.SHA256)
+		}
+		fmt.Printf("Mod '%s' %s matches lockfile, skipping...\n", locked.Name, locked.Version)
+		return nil
 	}
 
-	// Extract dependencies from mod info
-	// Factorio dependency format:
-	// - "mod-name" (hard requirement)
-	// - "mod-name >= 1.0" (hard requirement with version)
-	// - "~mod-name" (load order dependency - doesn't affect load order)
-	// - "~mod-name >= 1.0" (load order dependency with version)
-	// - "?mod-name" (optional dependency - skip)
-	// - "(?)mod-name" (hidden optional dependency - skip)
-	// - "!mod-name" (incompatible dependency - skip)
-	var dependencies []string
-	for _, dep := range modInfo.Dependencies {
-		// Skip base mod, optional dependencies, incompatible dependencies, and hidden optional dependencies
-		if strings.HasPrefix(dep, "base") ||
-			strings.HasPrefix(dep, "?") ||
-			strings.HasPrefix(dep, "!") ||
-			strings.HasPrefix(dep, "(?)") {
-			if strings.HasPrefix(dep, "?") || strings.HasPrefix(dep, "(?)") {
-				fmt.Printf("  → Skipping optional dependency: %s\n", dep)
-			} else if strings.HasPrefix(dep, "!") {
-				fmt.Printf("  → Skipping incompatible dependency: %s\n", dep)
-			} else if strings.HasPrefix(dep, "base") {
-				fmt.Printf("  → Skipping base mod: %s\n", dep)
-			}
-			continue
+	fmt.Printf("Installing locked mod '%s' %s from '%s'...\n", locked.Name, locked.Version, locked.Source)
+
+	var modInfo *ModInfo
+	var blob string
+	if locked.Source == PortalSourceName {
+		archive, _, err := mm.downloadFromPortal(ctx, inst, locked.Name, locked.Revision)
+		if err != nil {
+			return fmt.Errorf("downloading locked mod '%s': %w", locked.Name, err)
 		}
+		if modInfo, err = mm.getModInfo(archive); err != nil {
+			return fmt.Errorf("extracting mod info for '%s': %w", locked.Name, err)
+		}
+		blob = archive
+	} else {
+		mm.mu.RLock()
+		entry, ok := mm.sourceRegistry[locked.Name][locked.Source]
+		mm.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("locked mod '%s' not found in source '%s'", locked.Name, locked.Source)
+		}
+		_, path, err := mm.extractToCache(entry)
+		if err != nil {
+			return fmt.Errorf("extracting locked mod '%s': %w", locked.Name, err)
+		}
+		modInfo, blob = entry.info, path
+	}
+
+	if modInfo.Version != locked.Version {
+		return fmt.Errorf("mod '%s' has version %s but is locked at %s", locked.Name, modInfo.Version, locked.Version)
+	}
+
+	if _, err := mm.installModFile(inst, modInfo, locked.SHA256, blob); err != nil {
+		return fmt.Errorf("installing locked mod '%s' from '%s': %w", locked.Name, locked.Source, err)
+	}
+
+	if err := mm.updateModList(inst, locked.Name, true); err != nil {
+		return fmt.Errorf("updating mod list: %w", err)
+	}
+
+	return nil
+}
+
+// recordLockEntry remembers how a mod was installed during the current run
+func (mm *ModManager) recordLockEntry(entry *LockedMod) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.lockEntries[entry.Name] = entry
+}
+
+// lockInstalledMod records a lock entry for a mod that was already installed.
+// Provenance is carried over from the previous lockfile when the file is unchanged,
+// otherwise it is looked up in the source registry.
+func (mm *ModManager) lockInstalledMod(inst *Instance, modName string, previous *Lockfile) error {
+	if sourceName, entry := mm.localEntry(inst, modName); entry != nil {
+		mm.recordLockEntry(&LockedMod{Name: modName, Version: entry.info.Version, Source: sourceName})
+		return nil
+	}
+
+	// installedModFiles leaves out mods whose names merely share the prefix
+	matches := installedModFiles(inst, modName)
+	if len(matches) == 0 {
+		return fmt.Errorf("mod file not found: %s", modName)
+	}
+
+	hash, err := hashFile(matches[0])
+	if err != nil {
+		return fmt.Errorf("hashing mod file: %w", err)
+	}
 
-		// Parse dependency string to extract mod name
-		// Dependencies can be in formats like:
-		// - "mod-name" (hard requirement)
-		// - "mod-name >= 1.0" (hard requirement with version)
-		// - "~mod-name" (load order dependency)
-		// - "~mod-name >= 1.0" (load order dependency with version)
-		// - "mod name with spaces" (mod names can contain spaces)
-		// - "mod name with spaces >= 1.0" (version constraints)
-
-		// Find the first comparison operator to split mod name from version
-		var modName string
-		if strings.Contains(dep, " >= ") {
-			parts := strings.SplitN(dep, " >= ", 2)
-			modName = strings.TrimSpace(parts[0])
-		} else if strings.Contains(dep, " <= ") {
-			parts := strings.SplitN(dep, " <= ", 2)
-			modName = strings.TrimSpace(parts[0])
-		} else if strings.Contains(dep, " = ") {
-			parts := strings.SplitN(dep, " = ", 2)
-			modName = strings.TrimSpace(parts[0])
-		} else if strings.Contains(dep, " > ") {
-			parts := strings.SplitN(dep, " > ", 2)
-			modName = strings.TrimSpace(parts[0])
-		} else if strings.Contains(dep, " < ") {
-			parts := strings.SplitN(dep, " < ", 2)
-			modName = strings.TrimSpace(parts[0])
-		} else {
-			// No version constraint, the whole string is the mod name
-			modName = strings.TrimSpace(dep)
+	if previous != nil {
+		if locked := previous.Mod(modName); locked != nil && locked.SHA256 == hash {
+			entry := *locked
+			mm.recordLockEntry(&entry)
+			return nil
 		}
+	}
+
+	info, err := mm.getModInfo(matches[0])
+	if err != nil {
+		return fmt.Errorf("reading mod info: %w", err)
+	}
 
-		if modName == "" {
+	entry := &LockedMod{
+		Name:    modName,
+		Version: info.Version,
+		SHA256:  hash,
+	}
+
+	// Find the source that provides this exact file
+	mm.mu.RLock()
+	sources := make(map[string]*registryEntry)
+	for sourceName, registered := range mm.sourceRegistry[modName] {
+		sources[sourceName] = registered
+	}
+	mm.mu.RUnlock()
+
+	for sourceName, registered := range sources {
+		if registered.local != "" {
 			continue
 		}
+		if cached, _, err := mm.extractToCache(registered); err == nil && cached.SHA256 == hash {
+			mm.mu.RLock()
+			entry.Source = sourceName
+			entry.Revision = mm.sourceRevisions[sourceName]
+			mm.mu.RUnlock()
+			break
+		}
+	}
+
+	if entry.Source == "" {
+		return fmt.Errorf("installed file does not match any configured source; reinstall it to lock it")
+	}
+
+	mm.recordLockEntry(entry)
+	return nil
+}
+
+// localEntry returns the local mod folder linked into the instance as modName,
+// with the source providing it
+func (mm *ModManager) localEntry(inst *Instance, modName string) (string, *registryEntry) {
+	target, err := os.Readlink(filepath.Join(inst.Dir, "mods", modName))
+	if err != nil {
+		return "", nil
+	}
 
-		// Handle load order dependencies (prefixed with ~)
-		if strings.HasPrefix(modName, "~") {
-			modName = strings.TrimSpace(strings.TrimPrefix(modName, "~"))
-			fmt.Printf("  → Adding load order dependency: %s\n", modName)
-		} else {
-			modName = strings.TrimSpace(modName)
-			fmt.Printf("  → Adding required dependency: %s\n", modName)
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	for sourceName, entry := range mm.sourceRegistry[modName] {
+		if entry.local != "" && entry.local == target {
+			return sourceName, entry
 		}
+	}
+	return "", nil
+}
 
-		// Skip empty mod names
-		if modName == "" {
-			fmt.Printf("  → Skipping empty dependency\n")
-			continue
+// writeLockfile writes the lock entries recorded during the current run
+func (mm *ModManager) writeLockfile(inst *Instance) error {
+	lock := &Lockfile{
+		FactorioVersion: inst.Config.Version,
+		GeneratedAt:     time.Now().UTC(),
+		Sources:         make(map[string]LockedSource),
+	}
+
+	mm.mu.RLock()
+	for name, spec := range inst.Config.Mods.Sources {
+		lock.Sources[name] = LockedSource{
+			Spec:     spec,
+			Revision: mm.sourceRevisions[name],
 		}
+	}
+	for _, entry := range mm.lockEntries {
+		lock.Mods = append(lock.Mods, *entry)
+	}
+	mm.mu.RUnlock()
 
-		dependencies = append(dependencies, modName)
+	if err := lock.Save(inst); err != nil {
+		return err
 	}
 
-	return dependencies, nil
+	fmt.Printf("Wrote lockfile with %d mods to %s\n", len(lock.Mods), LockfilePath(inst))
+	return nil
+}
+
+// shortRevision abbreviates a commit SHA for display
+func shortRevision(revision string) string {
+	if len(revision) > 8 {
+		return revision[:8]
+	}
+	return revision
 }
 
+// builtinModNames lists the mods shipped with the game
+var builtinModNames = []string{"base", "elevated-rails", "quality", "space-age"}
+
 // isBuiltinMod checks if a mod is built into Factorio
 func isBuiltinMod(modName string) bool {
-	builtinMods := []string{"base", "elevated-rails", "quality", "space-age"}
-	for _, builtin := range builtinMods {
+	for _, builtin := range builtinModNames {
 		if modName == builtin {
 			return true
 		}
@@ -390,20 +712,19 @@ func isBuiltinMod(modName string) bool {
 	return false
 }
 
-// installModFromPortal installs a mod from the Factorio mod portal as fallback
-func (mm *ModManager) installModFromPortal(ctx context.Context, inst *Instance, modName string) error {
+// installModFromPortal installs a mod from the Factorio mod portal as fallback.
+// If version is empty the newest compatible release is used.
+func (mm *ModManager) installModFromPortal(ctx context.Context, inst *Instance, modName, version string) error {
 	fmt.Printf("  → Trying mod portal fallback for '%s'...\n", modName)
 
-	// Create temporary buffer for mod content
-	var buf bytes.Buffer
-
 	// Download from portal
-	if err := mm.downloadFromPortal(ctx, inst, modName, &buf); err != nil {
+	archive, release, err := mm.downloadFromPortal(ctx, inst, modName, version)
+	if err != nil {
 		return fmt.Errorf("portal download failed: %w", err)
 	}
 
 	// Extract mod info
-	modInfo, err := mm.extractModInfo(bytes.NewReader(buf.Bytes()))
+	modInfo, err := mm.getModInfo(archive)
 	if err != nil {
 		return fmt.Errorf("extracting mod info: %w", err)
 	}
@@ -421,14 +742,10 @@ func (mm *ModManager) installModFromPortal(ctx context.Context, inst *Instance,
 	mm.modInfos[modInfo.Name] = modInfo
 	mm.mu.Unlock()
 
-	// Write mod file
-	modDir := filepath.Join(inst.Dir, "mods")
-	if err := os.MkdirAll(modDir, 0755); err != nil {
-		return fmt.Errorf("creating mod directory: %w", err)
-	}
-	modPath := filepath.Join(modDir, fmt.Sprintf("%s_%s.zip", modInfo.Name, modInfo.Version))
-	if err := os.WriteFile(modPath, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("writing mod file: %w", err)
+	// Copy the downloaded release into the instance
+	hash, err := mm.installModFile(inst, modInfo, "", archive)
+	if err != nil {
+		return err
 	}
 
 	// Update mod-list.json
@@ -436,6 +753,14 @@ func (mm *ModManager) installModFromPortal(ctx context.Context, inst *Instance,
 		return fmt.Errorf("updating mod list: %w", err)
 	}
 
+	mm.recordLockEntry(&LockedMod{
+		Name:     modInfo.Name,
+		Version:  modInfo.Version,
+		Source:   PortalSourceName,
+		Revision: release,
+		SHA256:   hash,
+	})
+
 	fmt.Printf("  → Successfully installed '%s' from portal (version %s)\n", modInfo.Name, modInfo.Version)
 	return nil
 }
@@ -459,8 +784,14 @@ func (mm *ModManager) ListMods(inst *Instance) ([]*ModInfo, error) {
 
 		path := filepath.Join(modDir, entry.Name())
 
-		// Check if it's a symlink (local filesystem mod)
-		if entry.Type()&os.ModeSymlink != 0 {
+		// Skip files of installs in progress
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		// Check if it's a symlink to a directory (local filesystem mod). Zips
+		// symlinked from the download cache are read like any other zip.
+		if entry.Type()&os.ModeSymlink != 0 && !strings.HasSuffix(entry.Name(), ".zip") {
 			info, err := mm.readModInfoFromDirectory(path)
 			if err != nil {
 				// If we can't read the symlinked directory, skip it
@@ -484,41 +815,22 @@ func (mm *ModManager) ListMods(inst *Instance) ([]*ModInfo, error) {
 	return mods, nil
 }
 
-// extractModInfo reads mod info from a zip file
-func (mm *ModManager) extractModInfo(r io.Reader) (*ModInfo, error) {
-	// Read all data to enable multiple passes
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return nil, fmt.Errorf("reading mod data: %w", err)
-	}
-
-	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
-	if err != nil {
-		return nil, fmt.Errorf("reading zip: %w", err)
-	}
-
-	// Find and read info.json
-	for _, file := range zipReader.File {
-		if filepath.Base(file.Name) == "info.json" {
-			rc, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("opening info.json: %w", err)
-			}
-			defer rc.Close()
-
-			var info ModInfo
-			if err := json.NewDecoder(rc).Decode(&info); err != nil {
-				return nil, fmt.Errorf("parsing info.json: %w", err)
-			}
-
-			return &info, nil
-		}
+// modInfoFromResolved converts resolver metadata into a ModInfo
+func modInfoFromResolved(info *resolve.ModInfo) *ModInfo {
+	return &ModInfo{
+		Name:            info.Name,
+		Version:         info.Version,
+		Title:           info.Title,
+		Author:          info.Author,
+		Contact:         info.Contact,
+		Homepage:        info.Homepage,
+		Description:     info.Description,
+		Dependencies:    info.Dependencies,
+		FactorioVersion: info.FactorioVersion,
 	}
-
-	return nil, fmt.Errorf("info.json not found in mod")
 }
 
-// getModInfo reads info from an installed mod file
+// getModInfo reads info from a mod zip on disk
 func (mm *ModManager) getModInfo(path string) (*ModInfo, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -526,56 +838,29 @@ func (mm *ModManager) getModInfo(path string) (*ModInfo, error) {
 	}
 	defer file.Close()
 
-	return mm.extractModInfo(file)
-}
-
-// installDependencies installs required dependencies for a mod
-func (mm *ModManager) installDependencies(ctx context.Context, inst *Instance, info *ModInfo) error {
-	for _, dep := range info.Dependencies {
-		if err := mm.installDependency(ctx, inst, dep); err != nil {
-			return fmt.Errorf("installing dependency %s: %w", dep, err)
-		}
-	}
-	return nil
-}
-
-// installDependency installs a single dependency
-func (mm *ModManager) installDependency(ctx context.Context, inst *Instance, dep string) error {
-	// Parse dependency string (e.g., "base >= 1.1")
-	parts := strings.Fields(dep)
-	if len(parts) == 0 {
-		return fmt.Errorf("empty dependency")
-	}
-
-	modName := parts[0]
-	if modName == "base" {
-		return nil // Base mod is always available
-	}
-
-	// Check if already installed
-	if mm.isModInstalled(inst, modName) {
-		return nil
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
 	}
 
-	// Convert dependency to mod specification
-	spec := fmt.Sprintf("portal:%s", modName)
-	if len(parts) > 2 {
-		spec += "@" + parts[2]
+	info, err := resolve.ReadModInfo(file, stat.Size(), "")
+	if err != nil {
+		return nil, err
 	}
 
-	// Install dependency
-	return mm.InstallMod(ctx, inst, spec)
+	return modInfoFromResolved(info), nil
 }
 
 // isModInstalled checks if a mod is installed
 func (mm *ModManager) isModInstalled(inst *Instance, modName string) bool {
-	pattern := filepath.Join(inst.Dir, "mods", fmt.Sprintf("%s_*.zip", modName))
-	matches, _ := filepath.Glob(pattern)
-	return len(matches) > 0
+	return len(installedModFiles(inst, modName)) > 0
 }
 
 // updateModList updates the mod-list.json file
 func (mm *ModManager) updateModList(inst *Instance, modName string, enabled bool) error {
+	mm.modListMu.Lock()
+	defer mm.modListMu.Unlock()
+
 	listPath := filepath.Join(inst.Dir, "config", "mod-list.json")
 
 	var list struct {
@@ -603,13 +888,23 @@ func (mm *ModManager) updateModList(inst *Instance, modName string, enabled bool
 		}
 	}
 
-	if !found && enabled {
+	// Installed mods missing from the list would be enabled by Factorio, so
+	// disabling one adds an entry
+	if !found && (enabled || isBuiltinMod(modName) || mm.isModInstalled(inst, modName)) {
 		list.Mods = append(list.Mods, struct {
 			Name    string `json:"name"`
 			Enabled bool   `json:"enabled"`
 		}{
 			Name:    modName,
-			Enabled: true,
+			Enabled: enabled,
+		})
+
+		// Keep base first and the rest sorted, so parallel installs write the same file
+		sort.SliceStable(list.Mods, func(i, j int) bool {
+			if list.Mods[i].Name == "base" || list.Mods[j].Name == "base" {
+				return list.Mods[i].Name == "base" && list.Mods[j].Name != "base"
+			}
+			return list.Mods[i].Name < list.Mods[j].Name
 		})
 	}
 
@@ -626,132 +921,14 @@ func (mm *ModManager) updateModList(inst *Instance, modName string, enabled bool
 	return nil
 }
 
-// installModFromDirectSource installs a mod from a direct source specification
-func (mm *ModManager) installModFromDirectSource(ctx context.Context, inst *Instance, modSpec string) error {
-	// Parse the source to check if it's a local filesystem source
-	src, err := resolve.ParseSource(modSpec)
+// readModInfoFromDirectory reads mod info from a local directory
+func (mm *ModManager) readModInfoFromDirectory(dirPath string) (*ModInfo, error) {
+	infoPath := filepath.Join(dirPath, "info.json")
+
+	// Read info.json file
+	data, err := os.ReadFile(infoPath)
 	if err != nil {
-		return fmt.Errorf("parsing source: %w", err)
-	}
-
-	// Handle local filesystem sources differently
-	if src.Type == resolve.SourceFile {
-		return mm.installModFromLocalFilesystem(ctx, inst, src.Path)
-	}
-
-	// For all other sources, use the existing logic
-	// Create temporary buffer for mod content
-	var buf bytes.Buffer
-
-	// Download mod using resolver
-	if err := mm.downloadMod(ctx, modSpec, &buf); err != nil {
-		return fmt.Errorf("downloading mod: %w", err)
-	}
-
-	// Extract mod info from the buffer
-	modInfo, err := mm.extractModInfo(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return fmt.Errorf("extracting mod info: %w", err)
-	}
-
-	// Check Factorio version compatibility
-	if modInfo.FactorioVersion != "" {
-		if !isVersionCompatible(inst.Config.Version, modInfo.FactorioVersion) {
-			return fmt.Errorf("mod requires Factorio %s but instance uses %s",
-				modInfo.FactorioVersion, inst.Config.Version)
-		}
-	}
-
-	// Cache mod info
-	mm.mu.Lock()
-	mm.modInfos[modInfo.Name] = modInfo
-	mm.mu.Unlock()
-
-	// Install dependencies first
-	if err := mm.installDependencies(ctx, inst, modInfo); err != nil {
-		return fmt.Errorf("installing dependencies: %w", err)
-	}
-
-	// Write mod file
-	modDir := filepath.Join(inst.Dir, "mods")
-	modPath := filepath.Join(modDir, fmt.Sprintf("%s_%s.zip", modInfo.Name, modInfo.Version))
-	if err := os.WriteFile(modPath, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("writing mod file: %w", err)
-	}
-
-	// Update mod-list.json
-	if err := mm.updateModList(inst, modInfo.Name, true); err != nil {
-		return fmt.Errorf("updating mod list: %w", err)
-	}
-
-	return nil
-}
-
-// installModFromLocalFilesystem installs a mod from a local filesystem path by creating a symlink
-func (mm *ModManager) installModFromLocalFilesystem(ctx context.Context, inst *Instance, localPath string) error {
-	// Check if the local path exists
-	if _, err := os.Stat(localPath); os.IsNotExist(err) {
-		return fmt.Errorf("local mod path does not exist: %s", localPath)
-	}
-
-	// Read mod info from the local directory
-	modInfo, err := mm.readModInfoFromDirectory(localPath)
-	if err != nil {
-		return fmt.Errorf("reading mod info from local directory: %w", err)
-	}
-
-	// Check Factorio version compatibility
-	if modInfo.FactorioVersion != "" {
-		if !isVersionCompatible(inst.Config.Version, modInfo.FactorioVersion) {
-			return fmt.Errorf("mod requires Factorio %s but instance uses %s",
-				modInfo.FactorioVersion, inst.Config.Version)
-		}
-	}
-
-	// Cache mod info
-	mm.mu.Lock()
-	mm.modInfos[modInfo.Name] = modInfo
-	mm.mu.Unlock()
-
-	// Install dependencies first
-	if err := mm.installDependencies(ctx, inst, modInfo); err != nil {
-		return fmt.Errorf("installing dependencies: %w", err)
-	}
-
-	// Create symlink to the local mod directory
-	modDir := filepath.Join(inst.Dir, "mods")
-	modLinkPath := filepath.Join(modDir, modInfo.Name)
-
-	// Remove existing symlink if it exists
-	if _, err := os.Lstat(modLinkPath); err == nil {
-		if err := os.Remove(modLinkPath); err != nil {
-			return fmt.Errorf("removing existing symlink: %w", err)
-		}
-	}
-
-	// Create symlink to the local mod directory
-	if err := os.Symlink(localPath, modLinkPath); err != nil {
-		return fmt.Errorf("creating symlink to local mod: %w", err)
-	}
-
-	fmt.Printf("  → Symlinked local mod '%s' from %s\n", modInfo.Name, localPath)
-
-	// Update mod-list.json
-	if err := mm.updateModList(inst, modInfo.Name, true); err != nil {
-		return fmt.Errorf("updating mod list: %w", err)
-	}
-
-	return nil
-}
-
-// readModInfoFromDirectory reads mod info from a local directory
-func (mm *ModManager) readModInfoFromDirectory(dirPath string) (*ModInfo, error) {
-	infoPath := filepath.Join(dirPath, "info.json")
-
-	// Read info.json file
-	data, err := os.ReadFile(infoPath)
-	if err != nil {
-		return nil, fmt.Errorf("reading info.json: %w", err)
+		return nil, fmt.Errorf("reading info.json: %w", err)
 	}
 
 	var modInfo ModInfo
@@ -762,100 +939,44 @@ func (mm *ModManager) readModInfoFromDirectory(dirPath string) (*ModInfo, error)
 	return &modInfo, nil
 }
 
-// installModFromRegistry searches the pre-built registry for a mod and installs it
-func (mm *ModManager) installModFromRegistry(ctx context.Context, inst *Instance, modName string) error {
-	fmt.Printf("  → Searching for mod '%s' in registry...\n", modName)
-
-	// Check if mod exists in the pre-built registry
+// installModFromRegistrySource installs the copy of a mod provided by a specific source
+func (mm *ModManager) installModFromRegistrySource(inst *Instance, modName, sourceName string) error {
 	mm.mu.RLock()
-	modSources, exists := mm.sourceRegistry[modName]
+	entry, ok := mm.sourceRegistry[modName][sourceName]
 	mm.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("mod '%s' not found in registry", modName)
-	}
-
-	// Try each source that has this mod
-	for sourceName, modData := range modSources {
-		fmt.Printf("  → Found mod '%s' in source '%s'\n", modName, sourceName)
-
-		// Extract mod info from the found mod
-		modInfo, err := mm.extractModInfo(bytes.NewReader(modData))
-		if err != nil {
-			fmt.Printf("  → Warning: Failed to extract mod info from source '%s': %v\n", sourceName, err)
-			continue
-		}
-
-		// Check Factorio version compatibility
-		if modInfo.FactorioVersion != "" {
-			if !isVersionCompatible(inst.Config.Version, modInfo.FactorioVersion) {
-				fmt.Printf("  → Warning: Mod '%s' requires Factorio %s but instance uses %s, trying next source...\n",
-					modName, modInfo.FactorioVersion, inst.Config.Version)
-				continue
-			}
-		}
-
-		// Cache mod info
-		mm.mu.Lock()
-		mm.modInfos[modInfo.Name] = modInfo
-		mm.mu.Unlock()
-
-		// Write mod file
-		modDir := filepath.Join(inst.Dir, "mods")
-		modPath := filepath.Join(modDir, fmt.Sprintf("%s_%s.zip", modInfo.Name, modInfo.Version))
-		if err := os.WriteFile(modPath, modData, 0644); err != nil {
-			return fmt.Errorf("writing mod file: %w", err)
-		}
-
-		// Update mod-list.json
-		if err := mm.updateModList(inst, modInfo.Name, true); err != nil {
-			return fmt.Errorf("updating mod list: %w", err)
-		}
-
-		return nil
+	if !ok {
+		return fmt.Errorf("mod '%s' not found in source '%s'", modName, sourceName)
 	}
 
-	return fmt.Errorf("mod '%s' not found in any compatible source", modName)
+	fmt.Printf("  → Using '%s' from source '%s'\n", modName, sourceName)
+	return mm.writeRegistryMod(inst, sourceName, entry)
 }
 
-// installModFromSource downloads a source repository and finds a specific mod
-func (mm *ModManager) installModFromSource(ctx context.Context, inst *Instance, sourceURL, modName string) error {
-	// Download the entire source repository
-	var repoBuf bytes.Buffer
-	if err := mm.downloadMod(ctx, sourceURL, &repoBuf); err != nil {
-		return fmt.Errorf("downloading source repository: %w", err)
-	}
-
-	// Search through the repository to find the mod with the correct name
-	modData, err := mm.findModInRepository(repoBuf.Bytes(), modName)
-	if err != nil {
-		return fmt.Errorf("finding mod in repository: %w", err)
-	}
-
-	// Extract mod info from the found mod
-	modInfo, err := mm.extractModInfo(bytes.NewReader(modData))
-	if err != nil {
-		return fmt.Errorf("extracting mod info: %w", err)
-	}
-
-	// Check Factorio version compatibility
-	if modInfo.FactorioVersion != "" {
-		if !isVersionCompatible(inst.Config.Version, modInfo.FactorioVersion) {
-			return fmt.Errorf("mod requires Factorio %s but instance uses %s",
-				modInfo.FactorioVersion, inst.Config.Version)
-		}
-	}
+// writeRegistryMod extracts a mod from its source archive into the instance,
+// replacing any other installed version
+func (mm *ModManager) writeRegistryMod(inst *Instance, sourceName string, entry *registryEntry) error {
+	modInfo := entry.info
 
 	// Cache mod info
 	mm.mu.Lock()
 	mm.modInfos[modInfo.Name] = modInfo
 	mm.mu.Unlock()
 
-	// Write mod file
-	modDir := filepath.Join(inst.Dir, "mods")
-	modPath := filepath.Join(modDir, fmt.Sprintf("%s_%s.zip", modInfo.Name, modInfo.Version))
-	if err := os.WriteFile(modPath, modData, 0644); err != nil {
-		return fmt.Errorf("writing mod file: %w", err)
+	// Local mods are linked as they are; they change too often to be hashed
+	var hash string
+	if entry.local != "" {
+		if err := linkLocalMod(inst, entry); err != nil {
+			return err
+		}
+	} else {
+		// Extract the mod into the cache and link it into the instance
+		_, blob, err := mm.extractToCache(entry)
+		if err != nil {
+			return fmt.Errorf("extracting mod: %w", err)
+		}
+		if hash, err = mm.installModFile(inst, modInfo, "", blob); err != nil {
+			return err
+		}
 	}
 
 	// Update mod-list.json
@@ -863,630 +984,346 @@ func (mm *ModManager) installModFromSource(ctx context.Context, inst *Instance,
 		return fmt.Errorf("updating mod list: %w", err)
 	}
 
+	mm.mu.RLock()
+	revision := mm.sourceRevisions[sourceName]
+	mm.mu.RUnlock()
+
+	mm.recordLockEntry(&LockedMod{
+		Name:     modInfo.Name,
+		Version:  modInfo.Version,
+		Source:   sourceName,
+		Revision: revision,
+		SHA256:   hash,
+	})
+
 	return nil
 }
 
-// findModInRepository searches through a repository zip to find a mod with the specified name
-func (mm *ModManager) findModInRepository(repoData []byte, modName string) ([]byte, error) {
-	// Read the repository zip
-	zipReader, err := zip.NewReader(bytes.NewReader(repoData), int64(len(repoData)))
-	if err != nil {
-		return nil, fmt.Errorf("reading repository zip: %w", err)
+// linkLocalMod links a local mod folder into the instance under the mod's
+// name, replacing any installed zip of the mod
+func linkLocalMod(inst *Instance, entry *registryEntry) error {
+	modDir := filepath.Join(inst.Dir, "mods")
+	if err := os.MkdirAll(modDir, 0755); err != nil {
+		return fmt.Errorf("creating mod directory: %w", err)
 	}
 
-	// Search through all folders in the repository
-	fmt.Printf("  → Searching for mod '%s' in repository...\n", modName)
-	foundMods := make(map[string]string) // mod name -> folder path
-
-	for _, file := range zipReader.File {
-		// Look for info.json files
-		if filepath.Base(file.Name) == "info.json" {
-			// Open and read the info.json
-			rc, err := file.Open()
-			if err != nil {
-				continue
-			}
-
-			var info ModInfo
-			if err := json.NewDecoder(rc).Decode(&info); err != nil {
-				rc.Close()
-				continue
-			}
-			rc.Close()
-
-			// Store found mods for debugging
-			folderPath := filepath.Dir(file.Name)
-			foundMods[info.Name] = folderPath
-			fmt.Printf("    - Found mod '%s' in folder '%s'\n", info.Name, folderPath)
-
-			// Check if this is the mod we're looking for
-			if info.Name == modName {
-				fmt.Printf("  → Found target mod '%s' in repository\n", modName)
-
-				// Extract the entire folder containing this mod
-				return mm.extractModFolderFromZip(repoData, folderPath)
+	linkPath := filepath.Join(modDir, entry.info.Name)
+	if target, err := os.Readlink(linkPath); err != nil || target != entry.local {
+		if _, err := os.Lstat(linkPath); err == nil {
+			if err := os.Remove(linkPath); err != nil {
+				return fmt.Errorf("removing existing symlink: %w", err)
 			}
 		}
+		if err := os.Symlink(entry.local, linkPath); err != nil {
+			return fmt.Errorf("creating symlink to local mod: %w", err)
+		}
+		fmt.Printf("  → Symlinked local mod '%s' from %s\n", entry.info.Name, entry.local)
 	}
 
-	// If we get here, the mod wasn't found
-	fmt.Printf("  → Available mods in repository:\n")
-	for modName, folderPath := range foundMods {
-		fmt.Printf("    - %s (in %s)\n", modName, folderPath)
-	}
-
-	return nil, fmt.Errorf("mod '%s' not found in repository", modName)
+	return removeOtherVersions(inst, entry.info.Name, linkPath)
 }
 
-// extractAllModsFromRepository extracts all mods from a repository zip and returns a map of modName -> modData
-func (mm *ModManager) extractAllModsFromRepository(repoData []byte) (map[string][]byte, error) {
-	// Read the repository zip
-	zipReader, err := zip.NewReader(bytes.NewReader(repoData), int64(len(repoData)))
+// installModFile places a mod zip from the download cache into the instance,
+// replaces any other installed version, and returns the file's SHA256. The
+// file is linked from the cache according to the instance's link mode. If
+// expectedSHA256 is set, a file with a different hash is rejected.
+func (mm *ModManager) installModFile(inst *Instance, modInfo *ModInfo, expectedSHA256, blob string) (string, error) {
+	hash, err := hashFile(blob)
 	if err != nil {
-		return nil, fmt.Errorf("reading repository zip: %w", err)
+		return "", fmt.Errorf("hashing mod file: %w", err)
 	}
-
-	// Track all mods found in the repository
-	mods := make(map[string][]byte)
-	modFolders := make(map[string]string) // mod name -> folder path
-
-	// First pass: find all mods and their folder paths
-	for _, file := range zipReader.File {
-		// Look for info.json files
-		if filepath.Base(file.Name) == "info.json" {
-			// Open and read the info.json
-			rc, err := file.Open()
-			if err != nil {
-				continue
-			}
-
-			var info ModInfo
-			if err := json.NewDecoder(rc).Decode(&info); err != nil {
-				rc.Close()
-				continue
-			}
-			rc.Close()
-
-			// Store the mod folder path
-			folderPath := filepath.Dir(file.Name)
-			modFolders[info.Name] = folderPath
-		}
+	if expectedSHA256 != "" && hash != expectedSHA256 {
+		return "", fmt.Errorf("mod '%s' does not match the lockfile (sha256 %s, locked %s)", modInfo.Name, hash, expectedSHA256)
 	}
 
-	// Second pass: extract each mod
-	for modName, folderPath := range modFolders {
-		modData, err := mm.extractModFolderFromZip(repoData, folderPath)
-		if err != nil {
-			fmt.Printf("  → Warning: Failed to extract mod '%s': %v\n", modName, err)
-			continue
-		}
-		mods[modName] = modData
+	modDir := filepath.Join(inst.Dir, "mods")
+	if err := os.MkdirAll(modDir, 0755); err != nil {
+		return "", fmt.Errorf("creating mod directory: %w", err)
 	}
 
-	return mods, nil
-}
-
-// extractModFolderFromZip extracts a specific folder from a zip file
-func (mm *ModManager) extractModFolderFromZip(zipData []byte, folderPath string) ([]byte, error) {
-	// Create a new zip writer for the extracted folder
-	var buf bytes.Buffer
-	zipWriter := zip.NewWriter(&buf)
-
-	// Read the original zip
-	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	// Link under a temporary name first so the installed file is replaced atomically
+	tmp := filepath.Join(modDir, fmt.Sprintf(".factctl-%s.tmp", hash[:16]))
+	os.Remove(tmp)
+	mode := inst.Config.Mods.LinkMode()
+	method, err := linkFile(blob, tmp, mode)
 	if err != nil {
-		return nil, fmt.Errorf("reading zip: %w", err)
+		return "", fmt.Errorf("writing mod file: %w", err)
 	}
-
-	// Find files in the folder and copy them to the new zip
-	folderPrefix := folderPath + "/"
-	found := false
-
-	// Extract the mod name from the folder path for the zip structure
-	modName := filepath.Base(folderPath)
-
-	for _, file := range zipReader.File {
-		// Check if this file is in the folder
-		if strings.HasPrefix(file.Name, folderPrefix) {
-			found = true
-
-			// Calculate the relative path within the folder
-			relativePath := strings.TrimPrefix(file.Name, folderPrefix)
-			if relativePath == "" {
-				continue // Skip the folder directory itself
-			}
-
-			// Create the file in the new zip with the proper folder structure
-			// Factorio expects files to be in a folder named after the mod
-			fullPath := modName + "/" + relativePath
-
-			// Create the file in the new zip with original metadata
-			zipFile, err := zipWriter.CreateHeader(&zip.FileHeader{
-				Name:               fullPath,
-				Method:             file.Method,
-				CompressedSize64:   file.CompressedSize64,
-				UncompressedSize64: file.UncompressedSize64,
-				Modified:           file.Modified,
-			})
-			if err != nil {
-				return nil, fmt.Errorf("creating file in zip: %w", err)
-			}
-
-			// Copy file contents
-			rc, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("opening file: %w", err)
-			}
-
-			_, err = io.Copy(zipFile, rc)
-			rc.Close()
-			if err != nil {
-				return nil, fmt.Errorf("copying file: %w", err)
-			}
-
-			// Debug: print info.json content if it's the info.json file
-			if relativePath == "info.json" {
-				fmt.Printf("  → Debug: Found info.json in extracted mod (path: %s)\n", fullPath)
-			}
-		}
+	defer os.Remove(tmp)
+	if mode != LinkAuto && method != mode {
+		fmt.Printf("  → Warning: Could not %s '%s' from the cache, copied it instead\n", mode, modInfo.Name)
 	}
 
-	if !found {
-		return nil, fmt.Errorf("folder '%s' not found in repository", folderPath)
+	modPath := filepath.Join(modDir, fmt.Sprintf("%s_%s.zip", modInfo.Name, modInfo.Version))
+	if err := os.Rename(tmp, modPath); err != nil {
+		return "", fmt.Errorf("writing mod file: %w", err)
 	}
-
-	// Close the zip writer before returning
-	if err := zipWriter.Close(); err != nil {
-		return nil, fmt.Errorf("closing zip writer: %w", err)
+	if err := removeOtherVersions(inst, modInfo.Name, modPath); err != nil {
+		return "", err
 	}
 
-	fmt.Printf("  → Extracted mod folder '%s' successfully\n", folderPath)
-	return buf.Bytes(), nil
+	return hash, nil
 }
 
-// getCachePath returns the path for a cached file based on URL hash
-func (mm *ModManager) getCachePath(url string) string {
-	hash := sha256.Sum256([]byte(url))
-	hashStr := hex.EncodeToString(hash[:])
-	return filepath.Join(mm.cacheDir, hashStr+".zip")
-}
-
-// loadCacheRegistry loads the cache registry from disk
-func (mm *ModManager) loadCacheRegistry() (map[string]*CacheEntry, error) {
-	registryPath := filepath.Join(mm.cacheDir, "registry.json")
-
-	if _, err := os.Stat(registryPath); os.IsNotExist(err) {
-		return make(map[string]*CacheEntry), nil
+// extractToCache repackages a registry mod into the download cache, or returns
+// the cached copy from an earlier run. Source archives are content-addressed,
+// so the archive's file name and the mod's folder identify the result.
+func (mm *ModManager) extractToCache(e *registryEntry) (*cache.Entry, string, error) {
+	key := fmt.Sprintf("extract:%s:%s", filepath.Base(e.archive), e.dir)
+	if entry, path, cached := mm.downloads.Get(key); cached {
+		return entry, path, nil
 	}
 
-	data, err := os.ReadFile(registryPath)
+	w, err := mm.downloads.Create(key)
 	if err != nil {
-		return nil, fmt.Errorf("reading cache registry: %w", err)
+		return nil, "", err
 	}
-
-	var registry map[string]*CacheEntry
-	if err := json.Unmarshal(data, &registry); err != nil {
-		return nil, fmt.Errorf("parsing cache registry: %w", err)
+	if err := e.writeTo(w); err != nil {
+		w.Abort()
+		return nil, "", err
 	}
-
-	return registry, nil
+	return w.Commit()
 }
 
-// saveCacheRegistry saves the cache registry to disk
-func (mm *ModManager) saveCacheRegistry(registry map[string]*CacheEntry) error {
-	// Ensure cache directory exists
-	if err := os.MkdirAll(mm.cacheDir, 0755); err != nil {
-		return fmt.Errorf("creating cache directory: %w", err)
-	}
-
-	registryPath := filepath.Join(mm.cacheDir, "registry.json")
-	data, err := json.MarshalIndent(registry, "", "  ")
+// indexSourceArchive lists the mods in a downloaded source archive without
+// extracting them. If subPath is set, only the mod in that directory is listed.
+func indexSourceArchive(archive, subPath string) (map[string]*registryEntry, error) {
+	f, err := os.Open(archive)
 	if err != nil {
-		return fmt.Errorf("marshaling cache registry: %w", err)
+		return nil, fmt.Errorf("opening source archive: %w", err)
 	}
+	defer f.Close()
 
-	return os.WriteFile(registryPath, data, 0644)
-}
-
-// getCachedDownload checks if a download is cached and returns the cached file
-func (mm *ModManager) getCachedDownload(url string) (*CacheEntry, bool) {
-	registry, err := mm.loadCacheRegistry()
+	stat, err := f.Stat()
 	if err != nil {
-		return nil, false
+		return nil, fmt.Errorf("opening source archive: %w", err)
 	}
 
-	entry, exists := registry[url]
-	if !exists {
-		return nil, false
+	mods, err := resolve.ListArchiveMods(f, stat.Size(), subPath)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if cached file still exists
-	if _, err := os.Stat(entry.FilePath); os.IsNotExist(err) {
-		// File was deleted, remove from registry
-		delete(registry, url)
-		mm.saveCacheRegistry(registry)
-		return nil, false
+	entries := make(map[string]*registryEntry, len(mods))
+	for name, mod := range mods {
+		entries[name] = &registryEntry{
+			archive: archive,
+			dir:     mod.Dir,
+			info:    modInfoFromResolved(mod.Info),
+		}
 	}
-
-	return entry, true
+	return entries, nil
 }
 
-// cacheDownload stores a downloaded file in the cache
-func (mm *ModManager) cacheDownload(url string, data []byte) (*CacheEntry, error) {
-	// Ensure cache directory exists
-	if err := os.MkdirAll(mm.cacheDir, 0755); err != nil {
-		return nil, fmt.Errorf("creating cache directory: %w", err)
-	}
-
-	// Calculate hash of the data
-	hash := sha256.Sum256(data)
-	hashStr := hex.EncodeToString(hash[:])
-
-	// Get cache path
-	cachePath := mm.getCachePath(url)
-
-	// Write cached file
-	if err := os.WriteFile(cachePath, data, 0644); err != nil {
-		return nil, fmt.Errorf("writing cached file: %w", err)
-	}
-
-	// Create cache entry
-	entry := &CacheEntry{
-		URL:      url,
-		Hash:     hashStr,
-		FilePath: cachePath,
-		Size:     int64(len(data)),
-		CachedAt: time.Now(),
-	}
-
-	// Update registry
-	registry, err := mm.loadCacheRegistry()
+// subPathEntry returns the single mod in a subdirectory of a downloaded archive
+func subPathEntry(archive, subPath string) (*registryEntry, error) {
+	entries, err := indexSourceArchive(archive, subPath)
 	if err != nil {
-		return nil, fmt.Errorf("loading cache registry: %w", err)
+		return nil, fmt.Errorf("reading mod in '%s': %w", subPath, err)
 	}
-
-	registry[url] = entry
-	if err := mm.saveCacheRegistry(registry); err != nil {
-		return nil, fmt.Errorf("saving cache registry: %w", err)
+	for _, entry := range entries {
+		return entry, nil
 	}
-
-	return entry, nil
+	return nil, fmt.Errorf("no mod found in '%s'", subPath)
 }
 
-// getLatestCommitSHA gets the latest commit SHA from GitHub API
-func (mm *ModManager) getLatestCommitSHA(ctx context.Context, repoPath string) (string, error) {
-	// Use GitHub API to get the latest commit
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/commits", repoPath)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+// writeTo repackages the mod's folder from its source archive as a mod zip,
+// leaving out the same files as PackMod. Files are streamed one at a time, so
+// the archive is never held in memory.
+func (e *registryEntry) writeTo(w io.Writer) error {
+	data, err := e.readFile(PackIgnoreFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading %s: %w", PackIgnoreFile, err)
 	}
-
-	// Add headers for better API compatibility
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "factctl/1.0")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	ignore, err := newPackIgnore(data)
 	if err != nil {
-		return "", fmt.Errorf("fetching commit info: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("GitHub API error: %d", resp.StatusCode)
-	}
-
-	var commits []struct {
-		SHA string `json:"sha"`
+		return err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
-		return "", fmt.Errorf("parsing commit response: %w", err)
-	}
-
-	if len(commits) == 0 {
-		return "", fmt.Errorf("no commits found for repository: %s", repoPath)
-	}
-
-	return commits[0].SHA, nil
-}
-
-// getCommitSHAForBranch gets the commit SHA for a specific branch or tag
-func (mm *ModManager) getCommitSHAForBranch(ctx context.Context, repoPath, branch string) (string, error) {
-	// Try to get the branch/tag reference from GitHub API
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/git/refs/heads/%s", repoPath, branch)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	zipReader, err := zip.OpenReader(e.archive)
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return fmt.Errorf("reading source archive: %w", err)
 	}
+	defer zipReader.Close()
 
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "factctl/1.0")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("fetching branch info: %w", err)
+	prefix := e.dir + "/"
+	if e.dir == "." {
+		prefix = ""
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 200 {
-		// Found as a branch
-		var ref struct {
-			Object struct {
-				SHA string `json:"sha"`
-			} `json:"object"`
+	z := newModZip(w, e.info)
+	for _, file := range zipReader.File {
+		relativePath, ok := strings.CutPrefix(file.Name, prefix)
+		if !ok || relativePath == "" || strings.HasSuffix(relativePath, "/") {
+			continue
 		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&ref); err != nil {
-			return "", fmt.Errorf("parsing branch response: %w", err)
+		if ignore.ignored(relativePath, false) {
+			continue
 		}
 
-		return ref.Object.SHA, nil
-	}
-
-	// If not found as a branch, try as a tag
-	apiURL = fmt.Sprintf("https://api.github.com/repos/%s/git/refs/tags/%s", repoPath, branch)
-
-	req, err = http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("creating tag request: %w", err)
-	}
-
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "factctl/1.0")
-
-	resp, err = client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("fetching tag info: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 200 {
-		// Found as a tag
-		var ref struct {
-			Object struct {
-				SHA string `json:"sha"`
-			} `json:"object"`
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("opening file: %w", err)
 		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&ref); err != nil {
-			return "", fmt.Errorf("parsing tag response: %w", err)
+		err = z.add(relativePath, file.Modified, rc)
+		rc.Close()
+		if err != nil {
+			return err
 		}
-
-		return ref.Object.SHA, nil
 	}
-
-	return "", fmt.Errorf("branch or tag '%s' not found in repository %s", branch, repoPath)
+	return z.Close()
 }
 
-// extractSubfolderFromZip extracts a specific subfolder from a zip file
-func (mm *ModManager) extractSubfolderFromZip(zipData []byte, subfolder string, buf *bytes.Buffer) error {
-	// Create a new zip writer for the extracted subfolder
-	zipWriter := zip.NewWriter(buf)
-	defer zipWriter.Close()
-
-	// Read the original zip
-	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+// readFile returns the contents of a file in the mod's folder
+func (e *registryEntry) readFile(name string) ([]byte, error) {
+	zipReader, err := zip.OpenReader(e.archive)
 	if err != nil {
-		return fmt.Errorf("reading zip: %w", err)
-	}
-
-	// Find files in the subfolder and copy them to the new zip
-	subfolderPrefix := subfolder + "/"
-	found := false
-
-	// Debug: list available folders
-	fmt.Printf("  → Available folders in repository:\n")
-	seenFolders := make(map[string]bool)
-	for _, file := range zipReader.File {
-		parts := strings.Split(file.Name, "/")
-		if len(parts) > 1 && parts[0] != "" {
-			folder := parts[0]
-			if !seenFolders[folder] {
-				fmt.Printf("    - %s\n", folder)
-				seenFolders[folder] = true
-			}
-		}
-	}
-
-	for _, file := range zipReader.File {
-		// Check if this file is in the subfolder
-		if strings.HasPrefix(file.Name, subfolderPrefix) {
-			found = true
-
-			// Calculate the relative path within the subfolder
-			relativePath := strings.TrimPrefix(file.Name, subfolderPrefix)
-			if relativePath == "" {
-				continue // Skip the subfolder directory itself
-			}
-
-			// Create the file in the new zip
-			zipFile, err := zipWriter.Create(relativePath)
-			if err != nil {
-				return fmt.Errorf("creating file in zip: %w", err)
-			}
-
-			// Copy file contents
-			rc, err := file.Open()
-			if err != nil {
-				return fmt.Errorf("opening file: %w", err)
-			}
-
-			_, err = io.Copy(zipFile, rc)
-			rc.Close()
-			if err != nil {
-				return fmt.Errorf("copying file: %w", err)
-			}
-		}
+		return nil, fmt.Errorf("reading source archive: %w", err)
 	}
+	defer zipReader.Close()
 
-	if !found {
-		return fmt.Errorf("subfolder '%s' not found in repository", subfolder)
-	}
-
-	fmt.Printf("  → Extracted subfolder '%s' successfully\n", subfolder)
-	return nil
-}
-
-// downloadMod downloads a mod from the specified source
-func (mm *ModManager) downloadMod(ctx context.Context, source string, buf *bytes.Buffer) error {
-	// Parse the source specification
-	// Format: "portal:modname" or "github:user/repo" or "git:url" etc.
-	parts := strings.SplitN(source, ":", 2)
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid mod source format: %s", source)
-	}
-
-	sourceType := parts[0]
-	sourcePath := parts[1]
-
-	switch sourceType {
-	case "portal":
-		// For portal downloads, we need an instance for version compatibility
-		// This is a limitation of the current design - portal downloads need instance context
-		return fmt.Errorf("portal downloads require instance context - use installModFromPortal instead")
-	case "github":
-		return mm.downloadFromGitHub(ctx, sourcePath, buf)
-	case "ghpr":
-		return mm.downloadFromGitHubPR(ctx, sourcePath, buf)
-	case "git":
-		return mm.downloadFromGit(ctx, sourcePath, buf)
-	default:
-		return fmt.Errorf("unsupported source type: %s", sourceType)
+	rc, err := zipReader.Open(path.Join(e.dir, name))
+	if err != nil {
+		return nil, err
 	}
+	defer rc.Close()
+	return io.ReadAll(rc)
 }
 
-// downloadFromPortal downloads a mod from the Factorio mod portal
-func (mm *ModManager) downloadFromPortal(ctx context.Context, inst *Instance, modName string, buf *bytes.Buffer) error {
-	fmt.Printf("  → Searching mod portal for '%s'...\n", modName)
-
-	// Search for the mod using the API
-	searchURL := fmt.Sprintf("https://mods.factorio.com/api/mods/%s", modName)
+// downloadSource downloads a parsed source through the resolver into the
+// download cache. It returns the path of the downloaded archive and the revision
+// the source resolved to.
+func (mm *ModManager) downloadSource(ctx context.Context, src *resolve.Source, name string) (string, string, error) {
+	pinned := src.Revision != ""
 
-	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	revision, err := mm.resolver.ResolveRevision(ctx, src)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return "", "", err
 	}
-
-	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("searching mod portal: %w", err)
+	switch {
+	case pinned:
+		fmt.Printf("  → Using locked revision %s\n", shortRevision(revision))
+	case revision != "":
+		fmt.Printf("  → Resolved to revision %s\n", shortRevision(revision))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("mod not found on portal: %s", modName)
+	archive, err := mm.fetchCached(ctx, src, name)
+	if err != nil {
+		return "", "", err
 	}
+	return archive, revision, nil
+}
 
-	var modInfo struct {
-		Name     string `json:"name"`
-		Releases []struct {
-			DownloadURL string `json:"download_url"`
-			Version     string `json:"version"`
-			InfoJSON    struct {
-				FactorioVersion string `json:"factorio_version"`
-			} `json:"info_json"`
-		} `json:"releases"`
+// fetchCached streams a source through the resolver into the download cache and
+// returns the path of the archive. Pinned revisions reuse the cached copy;
+// unpinned sources are downloaded again and replace their previous entry.
+func (mm *ModManager) fetchCached(ctx context.Context, src *resolve.Source, name string) (string, error) {
+	key := cacheKey(src)
+	if key != "" {
+		// A copy cached before the source was pinned by hash must match it too
+		if entry, path, cached := mm.downloads.Get(key); cached && (src.SHA256 == "" || entry.SHA256 == src.SHA256) {
+			fmt.Printf("  → Using cached download (%.1f MB)\n", float64(entry.Size)/(1024*1024))
+			return path, nil
+		}
+		fmt.Printf("  → No cache found, downloading...\n")
+	} else {
+		key = "download:" + name
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&modInfo); err != nil {
-		return fmt.Errorf("parsing mod info: %w", err)
+	w, err := mm.downloads.Create(key)
+	if err != nil {
+		return "", err
 	}
 
-	if len(modInfo.Releases) == 0 {
-		return fmt.Errorf("no releases found for mod: %s", modName)
+	progress := newProgressWriter(w, name)
+	if _, err := mm.resolver.Fetch(ctx, src, progress); err != nil {
+		w.Abort()
+		return "", fmt.Errorf("downloading '%s': %w", name, err)
 	}
+	progress.Done()
 
-	// Find the best release for the current Factorio version
-	var bestRelease *struct {
-		DownloadURL string `json:"download_url"`
-		Version     string `json:"version"`
-		InfoJSON    struct {
-			FactorioVersion string `json:"factorio_version"`
-		} `json:"info_json"`
+	_, path, err := w.Commit()
+	if err != nil {
+		return "", fmt.Errorf("caching download: %w", err)
 	}
+	return path, nil
+}
 
-	// Look for a release compatible with the current Factorio version
-	for i := len(modInfo.Releases) - 1; i >= 0; i-- {
-		release := &modInfo.Releases[i]
-		if isVersionCompatible(inst.Config.Version, release.InfoJSON.FactorioVersion) {
-			bestRelease = release
-			break
+// cacheKey identifies a download in the cache. Only sources pinned to an
+// immutable revision or a sha256 are cached, since branches and URLs can change.
+// A portal source naming an exact release is pinned to it.
+func cacheKey(src *resolve.Source) string {
+	if src.Revision == "" && src.Type == resolve.SourcePortal {
+		if v, err := solver.ParseVersion(src.Version); err == nil && !v.Partial {
+			return fmt.Sprintf("portal:%s:%s", src.ID, v)
 		}
 	}
-
-	// If no compatible release found, use the latest
-	if bestRelease == nil {
-		bestRelease = &modInfo.Releases[len(modInfo.Releases)-1]
-		fmt.Printf("  → Warning: No release found for Factorio %s, using latest version\n", inst.Config.Version)
-	}
-
-	fmt.Printf("  → Found mod '%s' version %s on portal\n", modName, bestRelease.Version)
-
-	// Check for required authentication
-	creds, err := mm.getPortalCredentials()
-	if err != nil || creds.FactorioUsername == "" || creds.FactorioToken == "" {
-		return fmt.Errorf("portal credentials required but not found\nHint: Run 'factctl auth' to authenticate with your Factorio account and set up portal access")
+	if src.Revision == "" {
+		// Content pinned by its hash can't change either
+		if src.SHA256 != "" {
+			return "sha256:" + src.SHA256
+		}
+		return ""
+	}
+
+	switch src.Type {
+	case resolve.SourceGitHub, resolve.SourceGitHubPR:
+		return fmt.Sprintf("github:%s/%s:%s", src.Owner, src.Repo, src.Revision)
+	case resolve.SourceGitHubRelease:
+		// The revision is the ID of the release asset
+		return fmt.Sprintf("ghrel:%s/%s:%s", src.Owner, src.Repo, src.Revision)
+	case resolve.SourceGit:
+		return fmt.Sprintf("git:%s:%s", src.ID, src.Revision)
+	case resolve.SourcePortal:
+		return fmt.Sprintf("portal:%s:%s", src.ID, src.Revision)
+	default:
+		return ""
 	}
+}
 
-	// Download the mod file with authentication as query parameters
-	downloadURL := fmt.Sprintf("https://mods.factorio.com%s?username=%s&token=%s",
-		bestRelease.DownloadURL,
-		url.QueryEscape(creds.FactorioUsername),
-		url.QueryEscape(creds.FactorioToken))
-
-	// Obfuscate credentials in the logged URL
-	obfuscatedURL := fmt.Sprintf("https://mods.factorio.com%s?username=***&token=***",
-		bestRelease.DownloadURL)
-	fmt.Printf("  → Downloading mod '%s' from %s...\n", modName, obfuscatedURL)
+// downloadFromPortal downloads a mod from the Factorio mod portal and returns the
+// path of the downloaded zip and the release version it picked. If version is
+// set, that exact release is downloaded.
+func (mm *ModManager) downloadFromPortal(ctx context.Context, inst *Instance, modName, version string) (string, string, error) {
+	fmt.Printf("  → Searching mod portal for '%s'...\n", modName)
 
-	downloadReq, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
-	if err != nil {
-		return fmt.Errorf("creating download request: %w", err)
+	src := &resolve.Source{
+		Type:            resolve.SourcePortal,
+		ID:              modName,
+		Version:         "latest",
+		Revision:        version,
+		FactorioVersion: inst.Config.Version,
 	}
 
-	fmt.Printf("  → Using authentication for download\n")
-
-	downloadResp, err := client.Do(downloadReq)
+	archive, release, err := mm.downloadSource(ctx, src, modName)
 	if err != nil {
-		return fmt.Errorf("downloading mod: %w", err)
-	}
-	defer downloadResp.Body.Close()
-
-	if downloadResp.StatusCode != 200 {
-		// This will likely be 302 redirect to login page
-		return fmt.Errorf("authentication required for portal downloads (need username/token)")
+		return "", "", err
 	}
 
-	// Copy the actual mod file with progress reporting
-	if err := mm.copyWithProgress(downloadResp.Body, buf, modName); err != nil {
-		return fmt.Errorf("copying mod file: %w", err)
-	}
+	fmt.Printf("  → Downloaded mod '%s' version %s from portal\n", modName, release)
+	return archive, release, nil
+}
 
-	// Debug: Check if we got a valid ZIP file
-	if buf.Len() == 0 {
-		return fmt.Errorf("downloaded file is empty")
+// portalCredentials returns the stored portal username and token for portal downloads
+func (mm *ModManager) portalCredentials() (string, string, error) {
+	creds, err := mm.getPortalCredentials()
+	if err != nil || creds == nil || creds.FactorioUsername == "" || creds.FactorioToken == "" {
+		return "", "", fmt.Errorf("portal credentials required but not found\nHint: Run 'factctl auth' to authenticate with your Factorio account and set up portal access")
 	}
+	return creds.FactorioUsername, creds.FactorioToken, nil
+}
 
-	// Check if it's a valid ZIP by looking at the first few bytes
-	firstBytesLen := 4
-	if buf.Len() < firstBytesLen {
-		firstBytesLen = buf.Len()
+// githubToken returns the GitHub token from GITHUB_TOKEN or the stored
+// credentials, or "" for anonymous requests
+func (mm *ModManager) githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
 	}
-	firstBytes := buf.Bytes()[:firstBytesLen]
-	if len(firstBytes) >= 4 && firstBytes[0] == 0x50 && firstBytes[1] == 0x4B {
-		fmt.Printf("  → Downloaded mod '%s' successfully (%d bytes)\n", modName, buf.Len())
-	} else {
-		fmt.Printf("  → Warning: Downloaded file doesn't appear to be a ZIP file (first bytes: %v)\n", firstBytes)
-		return fmt.Errorf("downloaded file is not a valid ZIP file")
+	creds, err := mm.getPortalCredentials()
+	if err != nil || creds == nil {
+		return ""
 	}
-
-	return nil
+	return creds.GitHubToken
 }
 
 // getPortalCredentials retrieves stored portal credentials
@@ -1507,371 +1344,39 @@ func (mm *ModManager) getPortalCredentials() (*auth.Credentials, error) {
 	return creds, err
 }
 
-// downloadFromGitHub downloads a mod from GitHub
-func (mm *ModManager) downloadFromGitHub(ctx context.Context, repoPath string, buf *bytes.Buffer) error {
-	fmt.Printf("  → Downloading GitHub repository '%s'...\n", repoPath)
-
-	// Parse repository path and branch/tag specification
-	// Format: "user/repo@branch" or "user/repo/subfolder@branch"
-	var baseRepo, subfolder, branch string
-
-	// Check for branch/tag specification
-	if strings.Contains(repoPath, "@") {
-		parts := strings.Split(repoPath, "@")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid repository format: %s (expected user/repo@branch)", repoPath)
-		}
-		repoPath = parts[0]
-		branch = parts[1]
-	}
-
-	// Check if this is a subfolder path (e.g., "user/repo/subfolder")
-	parts := strings.Split(repoPath, "/")
-	if len(parts) >= 3 {
-		// This is a subfolder path
-		baseRepo = strings.Join(parts[:2], "/")
-		subfolder = parts[2]
-		fmt.Printf("  → Detected subfolder '%s' in repository '%s'\n", subfolder, baseRepo)
-	} else {
-		// This is a regular repository
-		baseRepo = repoPath
-	}
-
-	// Get the commit SHA for the specified branch/tag or latest
-	var commitSHA string
-	var err error
-	if branch != "" {
-		commitSHA, err = mm.getCommitSHAForBranch(ctx, baseRepo, branch)
-		if err != nil {
-			return fmt.Errorf("getting commit for branch '%s': %w", branch, err)
-		}
-		fmt.Printf("  → Using branch '%s' (commit %s)\n", branch, commitSHA[:8])
-	} else {
-		commitSHA, err = mm.getLatestCommitSHA(ctx, baseRepo)
-		if err != nil {
-			return fmt.Errorf("getting latest commit: %w", err)
-		}
-		fmt.Printf("  → Using latest commit %s\n", commitSHA[:8])
-	}
-
-	// Use commit SHA as cache key
-	commitURL := fmt.Sprintf("https://github.com/%s/archive/%s.zip", baseRepo, commitSHA)
-
-	// Check cache first for the base repository
-	baseCacheKey := fmt.Sprintf("github:%s:%s", baseRepo, commitSHA)
-	fmt.Printf("  → Checking cache for commit %s...\n", commitSHA[:8])
-	if entry, cached := mm.getCachedDownload(baseCacheKey); cached {
-		fmt.Printf("  → Using cached repository (%.1f MB)\n", float64(entry.Size)/(1024*1024))
-		cachedData, err := os.ReadFile(entry.FilePath)
-		if err != nil {
-			return fmt.Errorf("reading cached file: %w", err)
-		}
-
-		// If this is a subfolder, extract only that subfolder
-		if subfolder != "" {
-			return mm.extractSubfolderFromZip(cachedData, subfolder, buf)
-		}
-
-		buf.Write(cachedData)
-		return nil
-	}
-
-	fmt.Printf("  → No cache found, downloading...\n")
-
-	// Download using commit SHA
-	req, err := http.NewRequestWithContext(ctx, "GET", commitURL, nil)
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-
-	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("downloading from GitHub: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("repository not found or inaccessible: %s", repoPath)
-	}
-
-	// Copy the zip file directly with progress reporting
-	if err := mm.copyWithProgress(resp.Body, buf, baseRepo); err != nil {
-		return fmt.Errorf("copying zip file: %w", err)
-	}
-
-	// Cache the base repository download
-	if _, err := mm.cacheDownload(baseCacheKey, buf.Bytes()); err != nil {
-		fmt.Printf("  → Warning: Failed to cache download: %v\n", err)
-	}
-
-	// If this is a subfolder, extract only that subfolder
-	if subfolder != "" {
-		return mm.extractSubfolderFromZip(buf.Bytes(), subfolder, buf)
-	}
-
-	fmt.Printf("  → Downloaded mod from '%s' successfully\n", repoPath)
-
-	return nil
-}
-
-// downloadFromGitHubPR downloads a mod from a GitHub pull request
-func (mm *ModManager) downloadFromGitHubPR(ctx context.Context, prSpec string, buf *bytes.Buffer) error {
-	fmt.Printf("  → Downloading GitHub PR '%s'...\n", prSpec)
-
-	// Parse the PR specification: "owner/repo#123"
-	parts := strings.Split(prSpec, "#")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid PR specification format: %s (expected owner/repo#123)", prSpec)
-	}
-
-	repoPath := parts[0]
-	prNumber := parts[1]
-
-	// Parse owner/repo
-	repoParts := strings.Split(repoPath, "/")
-	if len(repoParts) != 2 {
-		return fmt.Errorf("invalid repository format: %s (expected owner/repo)", repoPath)
-	}
-
-	// Check if this is a subfolder path (e.g., "user/repo/subfolder#123")
-	var baseRepo, subfolder string
-	if len(repoParts) >= 3 {
-		// This is a subfolder path
-		baseRepo = strings.Join(repoParts[:2], "/")
-		subfolder = repoParts[2]
-		fmt.Printf("  → Detected subfolder '%s' in repository '%s'\n", subfolder, baseRepo)
-	} else {
-		// This is a regular repository
-		baseRepo = repoPath
-	}
-
-	// Get the PR head SHA from GitHub API
-	commitSHA, err := mm.getPRHeadSHA(ctx, baseRepo, prNumber)
-	if err != nil {
-		return fmt.Errorf("getting PR head SHA: %w", err)
-	}
-
-	// Use commit SHA as cache key
-	commitURL := fmt.Sprintf("https://github.com/%s/archive/%s.zip", baseRepo, commitSHA)
-
-	// Check cache first for the base repository
-	baseCacheKey := fmt.Sprintf("githubpr:%s:%s:%s", baseRepo, prNumber, commitSHA)
-	fmt.Printf("  → Checking cache for PR #%s (commit %s)...\n", prNumber, commitSHA[:8])
-	if entry, cached := mm.getCachedDownload(baseCacheKey); cached {
-		fmt.Printf("  → Using cached PR (%.1f MB)\n", float64(entry.Size)/(1024*1024))
-		cachedData, err := os.ReadFile(entry.FilePath)
-		if err != nil {
-			return fmt.Errorf("reading cached file: %w", err)
-		}
-
-		// If this is a subfolder, extract only that subfolder
-		if subfolder != "" {
-			return mm.extractSubfolderFromZip(cachedData, subfolder, buf)
-		}
-
-		buf.Write(cachedData)
-		return nil
-	}
-
-	fmt.Printf("  → No cache found, downloading PR...\n")
-
-	// Download using commit SHA
-	req, err := http.NewRequestWithContext(ctx, "GET", commitURL, nil)
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-
-	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("downloading from GitHub: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("PR not found or inaccessible: %s", prSpec)
-	}
-
-	// Copy the zip file directly with progress reporting
-	if err := mm.copyWithProgress(resp.Body, buf, fmt.Sprintf("PR #%s", prNumber)); err != nil {
-		return fmt.Errorf("copying zip file: %w", err)
-	}
-
-	// Cache the base repository download
-	if _, err := mm.cacheDownload(baseCacheKey, buf.Bytes()); err != nil {
-		fmt.Printf("  → Warning: Failed to cache download: %v\n", err)
-	}
-
-	// If this is a subfolder, extract only that subfolder
-	if subfolder != "" {
-		return mm.extractSubfolderFromZip(buf.Bytes(), subfolder, buf)
-	}
-
-	fmt.Printf("  → Downloaded PR #%s from '%s' successfully\n", prNumber, repoPath)
-
-	return nil
-}
-
-// getPRHeadSHA gets the head commit SHA for a GitHub PR
-func (mm *ModManager) getPRHeadSHA(ctx context.Context, repoPath, prNumber string) (string, error) {
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%s", repoPath, prNumber)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("User-Agent", "factctl")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("GitHub API request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("GitHub API returned status %d for PR #%s", resp.StatusCode, prNumber)
-	}
-
-	var prData struct {
-		Head struct {
-			SHA string `json:"sha"`
-		} `json:"head"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&prData); err != nil {
-		return "", fmt.Errorf("parsing PR response: %w", err)
-	}
-
-	if prData.Head.SHA == "" {
-		return "", fmt.Errorf("PR #%s has no head commit", prNumber)
-	}
-
-	return prData.Head.SHA, nil
+// progressWriter reports progress while a large download is written
+type progressWriter struct {
+	w          io.Writer
+	name       string
+	total      int64
+	lastReport time.Time
 }
 
-// downloadFromGit downloads a mod from a Git repository
-func (mm *ModManager) downloadFromGit(ctx context.Context, gitURL string, buf *bytes.Buffer) error {
-	fmt.Printf("  → Downloading Git repository '%s'...\n", gitURL)
-
-	// Try to convert common Git hosting URLs to zip download URLs
-	zipURL := mm.convertGitURLToZip(gitURL)
-	if zipURL == "" {
-		return fmt.Errorf("unsupported Git hosting service: %s", gitURL)
-	}
-
-	// Check cache first
-	if entry, cached := mm.getCachedDownload(zipURL); cached {
-		fmt.Printf("  → Using cached download (%.1f MB)\n", float64(entry.Size)/(1024*1024))
-		cachedData, err := os.ReadFile(entry.FilePath)
-		if err != nil {
-			return fmt.Errorf("reading cached file: %w", err)
-		}
-		buf.Write(cachedData)
-		return nil
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", zipURL, nil)
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-
-	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("downloading from Git: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("repository not found or inaccessible: %s", gitURL)
-	}
-
-	// Copy the zip file directly with progress reporting
-	if err := mm.copyWithProgress(resp.Body, buf, gitURL); err != nil {
-		return fmt.Errorf("copying zip file: %w", err)
-	}
-
-	// Cache the download
-	if _, err := mm.cacheDownload(zipURL, buf.Bytes()); err != nil {
-		fmt.Printf("  → Warning: Failed to cache download: %v\n", err)
-	}
-
-	fmt.Printf("  → Downloaded mod from '%s' successfully\n", gitURL)
-
-	return nil
+// newProgressWriter wraps w with progress reporting
+func newProgressWriter(w io.Writer, name string) *progressWriter {
+	return &progressWriter{w: w, name: name, lastReport: time.Now()}
 }
 
-// convertGitURLToZip converts Git URLs to zip download URLs for common hosting services
-func (mm *ModManager) convertGitURLToZip(gitURL string) string {
-	// GitHub: https://github.com/user/repo -> https://github.com/user/repo/archive/refs/heads/main.zip
-	if strings.HasPrefix(gitURL, "https://github.com/") {
-		// Remove .git suffix if present
-		repoPath := strings.TrimSuffix(gitURL, ".git")
-		return fmt.Sprintf("%s/archive/refs/heads/main.zip", repoPath)
-	}
-
-	// GitLab: https://gitlab.com/user/repo -> https://gitlab.com/user/repo/-/archive/main/repo-main.zip
-	if strings.HasPrefix(gitURL, "https://gitlab.com/") {
-		// Remove .git suffix if present
-		repoPath := strings.TrimSuffix(gitURL, ".git")
-		parts := strings.Split(strings.TrimPrefix(repoPath, "https://gitlab.com/"), "/")
-		if len(parts) >= 2 {
-			repoName := parts[len(parts)-1]
-			return fmt.Sprintf("%s/-/archive/main/%s-main.zip", repoPath, repoName)
-		}
-	}
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.total += int64(n)
 
-	// Bitbucket: https://bitbucket.org/user/repo -> https://bitbucket.org/user/repo/get/main.zip
-	if strings.HasPrefix(gitURL, "https://bitbucket.org/") {
-		// Remove .git suffix if present
-		repoPath := strings.TrimSuffix(gitURL, ".git")
-		return fmt.Sprintf("%s/get/main.zip", repoPath)
+	// Report progress every 2 seconds for large downloads
+	if time.Since(p.lastReport) >= 2*time.Second {
+		mb := float64(p.total) / (1024 * 1024)
+		fmt.Printf("  → Downloaded %.1f MB for '%s'...\n", mb, p.name)
+		p.lastReport = time.Now()
 	}
 
-	// For other Git hosting services, return empty string (unsupported)
-	return ""
+	return n, err
 }
 
-// copyWithProgress copies data with progress reporting for large downloads
-func (mm *ModManager) copyWithProgress(src io.Reader, dst io.Writer, name string) error {
-	// Create a buffer for reading in chunks
-	buf := make([]byte, 32*1024) // 32KB chunks
-	totalBytes := int64(0)
-	lastReport := time.Now()
-
-	for {
-		n, err := src.Read(buf)
-		if n > 0 {
-			if _, writeErr := dst.Write(buf[:n]); writeErr != nil {
-				return writeErr
-			}
-			totalBytes += int64(n)
-
-			// Report progress every 2 seconds for large downloads
-			if time.Since(lastReport) >= 2*time.Second {
-				mb := float64(totalBytes) / (1024 * 1024)
-				fmt.Printf("  → Downloaded %.1f MB for '%s'...\n", mb, name)
-				lastReport = time.Now()
-			}
-		}
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-	}
-
-	// Final progress report
-	mb := float64(totalBytes) / (1024 * 1024)
+// Done prints the final size of the download
+func (p *progressWriter) Done() {
+	mb := float64(p.total) / (1024 * 1024)
 	if mb > 0.1 { // Only report if more than 100KB
-		fmt.Printf("  → Downloaded %.1f MB for '%s'\n", mb, name)
+		fmt.Printf("  → Downloaded %.1f MB for '%s'\n", mb, p.name)
 	}
-
-	return nil
 }
 
 // isVersionCompatible checks if a mod version is compatible with Factorio version
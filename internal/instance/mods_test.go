@@ -210,6 +210,46 @@ func TestModManager(t *testing.T) {
 	})
 }
 
+func TestLockInstalledMod(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{Name: "test-instance", Version: "1.1"},
+		Dir:    filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	if err := os.MkdirAll(filepath.Join(inst.Dir, "mods"), 0755); err != nil {
+		t.Fatalf("Failed to create mods directory: %v", err)
+	}
+
+	// lib_1x sorts before lib's own file
+	for _, info := range []*ModInfo{
+		{Name: "lib_1x", Version: "0.1.0"},
+		{Name: "lib", Version: "2.0.0"},
+	} {
+		f, err := os.Create(filepath.Join(inst.Dir, "mods", info.Name+"_"+info.Version+".zip"))
+		if err != nil {
+			t.Fatalf("Failed to create mod file: %v", err)
+		}
+		if err := createTestModZip(f, info); err != nil {
+			t.Fatalf("Failed to create test mod zip: %v", err)
+		}
+		f.Close()
+	}
+
+	hash, err := hashFile(filepath.Join(inst.Dir, "mods", "lib_2.0.0.zip"))
+	if err != nil {
+		t.Fatalf("hashFile() error = %v", err)
+	}
+	previous := &Lockfile{Mods: []LockedMod{{Name: "lib", Version: "2.0.0", Source: PortalSourceName, SHA256: hash}}}
+
+	manager := NewModManager(tmpDir)
+	if err := manager.lockInstalledMod(inst, "lib", previous); err != nil {
+		t.Fatalf("lockInstalledMod() error = %v", err)
+	}
+	if entry := manager.lockEntries["lib"]; entry == nil || entry.Version != "2.0.0" || entry.SHA256 != hash {
+		t.Errorf("lock entry = %+v, want lib 2.0.0 carried over from the previous lockfile", entry)
+	}
+}
+
 // createTestModZip creates a mock mod zip file for testing
 func createTestModZip(w io.Writer, info *ModInfo) error {
 	zw := zip.NewWriter(w)
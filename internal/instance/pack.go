@@ -0,0 +1,252 @@
+package instance
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+// PackIgnoreFile names the file in a mod's folder that lists files to leave
+// out of the mod's zip, one pattern per line as in .gitignore
+const PackIgnoreFile = ".factctlignore"
+
+// defaultIgnore leaves hidden files, such as .git, and zips, such as earlier
+// releases, out of every mod zip. A .factctlignore can add to or negate them.
+const defaultIgnore = ".*\n*.zip\n"
+
+// ignoreRule is one pattern of a .factctlignore
+type ignoreRule struct {
+	pattern  string
+	anchored bool // Match the path from the mod's folder rather than any name
+	dirOnly  bool // Match only directories (pattern ended in "/")
+	negate   bool // Keep what earlier rules left out (pattern started with "!")
+}
+
+// packIgnore decides which files of a mod's folder are left out of its zip.
+// As in .gitignore, the last matching rule wins.
+type packIgnore []ignoreRule
+
+// newPackIgnore parses the contents of a .factctlignore after the default
+// rules. data may be nil when the mod has none.
+func newPackIgnore(data []byte) (packIgnore, error) {
+	var rules packIgnore
+	lines := strings.Split(defaultIgnore+string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			rule.negate, line = true, rest
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly, line = true, rest
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+
+		if _, err := path.Match(rule.pattern, ""); err != nil || rule.pattern == "" {
+			// Count lines from the start of the user's file
+			return nil, fmt.Errorf("%s line %d: invalid pattern %q", PackIgnoreFile, i+1-strings.Count(defaultIgnore, "\n"), lines[i])
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ignored reports whether a file, given by its slash-separated path in the
+// mod's folder, is left out. Everything in a left out directory is too.
+func (p packIgnore) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		last := i == len(parts)-1
+		if p.match(strings.Join(parts[:i+1], "/"), !last || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// match applies the rules to a single path
+func (p packIgnore) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range p {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := rel
+		if !rule.anchored {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(rule.pattern, name); ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// modZip writes a mod zip in the layout the mod portal publishes, with every
+// file in a <name>_<version>/ folder
+type modZip struct {
+	zw     *zip.Writer
+	folder string
+}
+
+// newModZip starts a zip for the mod described by info
+func newModZip(w io.Writer, info *ModInfo) *modZip {
+	return &modZip{
+		zw:     zip.NewWriter(w),
+		folder: info.Name + "_" + info.Version + "/",
+	}
+}
+
+// add stores a file under its slash-separated path in the mod's folder
+func (z *modZip) add(rel string, modified time.Time, r io.Reader) error {
+	dst, err := z.zw.CreateHeader(&zip.FileHeader{
+		Name:     z.folder + rel,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("creating file in zip: %w", err)
+	}
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("adding %s: %w", rel, err)
+	}
+	return nil
+}
+
+// Close finishes the zip
+func (z *modZip) Close() error {
+	if err := z.zw.Close(); err != nil {
+		return fmt.Errorf("closing zip writer: %w", err)
+	}
+	return nil
+}
+
+// readModInfoFile reads the info.json in a mod's folder
+func readModInfoFile(dir string) (*ModInfo, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "info.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading info.json: %w", err)
+	}
+
+	var info ModInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("parsing info.json: %w", err)
+	}
+	return &info, nil
+}
+
+// checkModInfo checks that the fields Factorio reads from an info.json are
+// well formed
+func checkModInfo(info *ModInfo) error {
+	if err := validateModName(info.Name); err != nil {
+		return fmt.Errorf("info.json: %w", err)
+	}
+	if v, err := solver.ParseVersion(info.Version); err != nil || v.Partial {
+		return fmt.Errorf("info.json has invalid version %q: expected major.minor.patch", info.Version)
+	}
+	if _, err := solver.ParseDependencies(info.Dependencies); err != nil {
+		return fmt.Errorf("info.json has invalid dependencies: %w", err)
+	}
+	if info.FactorioVersion != "" {
+		if v, err := solver.ParseVersion(info.FactorioVersion); err != nil || !v.Partial {
+			return fmt.Errorf("info.json has invalid factorio_version %q: expected major.minor", info.FactorioVersion)
+		}
+	}
+	return nil
+}
+
+// CheckModFolder checks that the mod in dir is ready to be released: its
+// info.json must be well formed and name the Factorio version it is for
+func CheckModFolder(dir string) (*ModInfo, error) {
+	info, err := readModInfoFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkModInfo(info); err != nil {
+		return nil, err
+	}
+	if info.FactorioVersion == "" {
+		return nil, fmt.Errorf("info.json has no factorio_version")
+	}
+	return info, nil
+}
+
+// PackMod checks the mod in dir and writes it to w as a release zip named
+// <name>_<version>.zip. Hidden files, zips and the files its .factctlignore
+// lists are left out.
+func PackMod(dir string, w io.Writer) (*ModInfo, error) {
+	info, err := CheckModFolder(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, PackIgnoreFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", PackIgnoreFile, err)
+	}
+	ignore, err := newPackIgnore(data)
+	if err != nil {
+		return nil, err
+	}
+
+	z := newModZip(w, info)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if ignore.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		// Links are followed, but only to files
+		stat, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !stat.Mode().IsRegular() {
+			fmt.Printf("  → Warning: Skipping '%s': not a regular file\n", rel)
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return z.add(rel, stat.ModTime(), f)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("packing mod: %w", err)
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,119 @@
+package instance
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackIgnore(t *testing.T) {
+	ignore, err := newPackIgnore([]byte("# Build output\nbuild/\n/docs\n*.psd\n!.luarc.json\n"))
+	if err != nil {
+		t.Fatalf("newPackIgnore() error = %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"info.json", false, false},
+		{".git", true, true},
+		{".git/config", false, true},
+		{"graphics/.DS_Store", false, true},
+		{".luarc.json", false, false},
+		{"my-mod_0.9.0.zip", false, true},
+		{"build", true, true},
+		{"build/out.lua", false, true},
+		{"build", false, false},
+		{"docs/readme.md", false, true},
+		{"locale/docs/readme.md", false, false},
+		{"graphics/icon.psd", false, true},
+		{"graphics/icon.png", false, false},
+	}
+	for _, tt := range tests {
+		if got := ignore.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if _, err := newPackIgnore([]byte("*.psd\n[z-a\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("newPackIgnore() error = %v, want invalid pattern on line 2", err)
+	}
+}
+
+func TestPackMod(t *testing.T) {
+	writeMod := func(t *testing.T, files map[string]string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("release zip", func(t *testing.T) {
+		dir := writeMod(t, map[string]string{
+			"info.json":         `{"name": "my-mod", "version": "1.2.3", "factorio_version": "2.0", "dependencies": ["base >= 2.0", "? flib"]}`,
+			"control.lua":       "script.on_init(function() end)",
+			"locale/en/en.cfg":  "[mod-name]\nmy-mod=My Mod",
+			".git/HEAD":         "ref: refs/heads/main",
+			".factctlignore":    "tests/\n",
+			"tests/control.lua": "-- not shipped",
+			"my-mod_1.2.2.zip":  "old release",
+		})
+
+		var buf bytes.Buffer
+		info, err := PackMod(dir, &buf)
+		if err != nil {
+			t.Fatalf("PackMod() error = %v", err)
+		}
+		if info.Name != "my-mod" || info.Version != "1.2.3" {
+			t.Errorf("PackMod() = %s %s, want my-mod 1.2.3", info.Name, info.Version)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("Failed to read packed zip: %v", err)
+		}
+		var names []string
+		for _, file := range zr.File {
+			names = append(names, file.Name)
+		}
+		want := []string{"my-mod_1.2.3/control.lua", "my-mod_1.2.3/info.json", "my-mod_1.2.3/locale/en/en.cfg"}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("PackMod() files = %v, want %v", names, want)
+		}
+	})
+
+	invalid := []struct {
+		name string
+		info string
+		want string
+	}{
+		{"missing name", `{"version": "1.0.0", "factorio_version": "2.0"}`, "name"},
+		{"short version", `{"name": "my-mod", "version": "1.0", "factorio_version": "2.0"}`, "invalid version"},
+		{"bad dependency", `{"name": "my-mod", "version": "1.0.0", "factorio_version": "2.0", "dependencies": ["base >= two"]}`, "dependencies"},
+		{"no factorio version", `{"name": "my-mod", "version": "1.0.0"}`, "factorio_version"},
+		{"full factorio version", `{"name": "my-mod", "version": "1.0.0", "factorio_version": "2.0.28"}`, "factorio_version"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeMod(t, map[string]string{"info.json": tt.info})
+			_, err := PackMod(dir, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("PackMod() error = %v, want error about %s", err, tt.want)
+			}
+		})
+	}
+}
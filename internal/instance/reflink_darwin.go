@@ -0,0 +1,10 @@
+//go:build darwin
+
+package instance
+
+import "golang.org/x/sys/unix"
+
+// reflink clones src to dst with clonefile(2), which APFS supports
+func reflink(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
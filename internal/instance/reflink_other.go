@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package instance
+
+// reflink is not available on this platform; linkFile falls back to a hardlink or copy
+func reflink(src, dst string) error {
+	return errReflinkUnsupported
+}
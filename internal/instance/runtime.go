@@ -261,6 +261,14 @@ func (rm *RuntimeManager) buildArgs(inst *Instance) []string {
 		args = append(args, "--port", fmt.Sprintf("%d", inst.Config.Port))
 	}
 
+	// Enable RCON if configured
+	if inst.Config.RCONPort > 0 {
+		args = append(args,
+			"--rcon-port", fmt.Sprintf("%d", inst.Config.RCONPort),
+			"--rcon-password", inst.Config.RCONPassword,
+		)
+	}
+
 	// Add mod directory
 	args = append(args,
 		"--mod-directory", filepath.Join(inst.Dir, "mods"),
@@ -290,6 +298,16 @@ func (rm *RuntimeManager) IsRunning(name string) bool {
 	return exists
 }
 
+// Restart stops an instance if it is running and starts it again
+func (rm *RuntimeManager) Restart(ctx context.Context, inst *Instance) error {
+	if rm.IsRunning(inst.Config.Name) {
+		if err := rm.Stop(inst.Config.Name); err != nil {
+			return fmt.Errorf("stopping instance: %w", err)
+		}
+	}
+	return rm.Start(ctx, inst)
+}
+
 // WaitFor waits for an instance to stop
 func (rm *RuntimeManager) WaitFor(name string) error {
 	rm.mu.RLock()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -85,6 +86,18 @@ func TestRuntimeManager(t *testing.T) {
 		}
 	})
 
+	t.Run("rcon arguments", func(t *testing.T) {
+		rconInst := *inst
+		cfg := *inst.Config
+		cfg.RCONPort, cfg.RCONPassword = 27015, "secret"
+		rconInst.Config = &cfg
+
+		args := strings.Join(rm.buildArgs(&rconInst), " ")
+		if !strings.Contains(args, "--rcon-port 27015 --rcon-password secret") {
+			t.Errorf("buildArgs() = %s, want the RCON port and password", args)
+		}
+	})
+
 	// Test process management
 	t.Run("process management", func(t *testing.T) {
 		// Skip actual process starting if Factorio is not installed
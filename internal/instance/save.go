@@ -0,0 +1,63 @@
+package instance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/WhyIsSandwich/factctl/internal/savegame"
+)
+
+// ApplySave makes a configuration load a save as it was played: the Factorio
+// version that wrote it, exactly its mods, each pinned to the portal release
+// of the saved version, and the save itself as the save file.
+func (c *Config) ApplySave(h *savegame.Header, saveFile string) {
+	c.Version = h.Version
+	c.SaveFile = filepath.Base(saveFile)
+
+	m := &c.Mods
+	m.Enabled = nil
+	if m.Sources == nil {
+		m.Sources = make(map[string]string)
+	}
+	if m.Pin == nil {
+		m.Pin = make(map[string]string)
+	}
+
+	for _, mod := range h.Mods {
+		m.Enabled = append(m.Enabled, mod.Name)
+		m.Disabled = removeName(m.Disabled, mod.Name)
+		if isBuiltinMod(mod.Name) {
+			continue
+		}
+		m.Sources[mod.Name] = fmt.Sprintf("portal:%s@%s", mod.Name, mod.Version)
+		m.Pin[mod.Name] = mod.Name
+	}
+	if !slices.Contains(m.Enabled, "base") {
+		m.Enabled = append([]string{"base"}, m.Enabled...)
+	}
+}
+
+// CopySave copies a save into the instance's saves directory, replacing a save
+// of the same name
+func CopySave(inst *Instance, saveFile string) error {
+	savesDir := filepath.Join(inst.Dir, "saves")
+	if err := os.MkdirAll(savesDir, 0755); err != nil {
+		return fmt.Errorf("creating saves directory: %w", err)
+	}
+
+	dst := filepath.Join(savesDir, filepath.Base(saveFile))
+	if src, err := os.Stat(saveFile); err != nil {
+		return fmt.Errorf("reading save: %w", err)
+	} else if existing, err := os.Stat(dst); err == nil && os.SameFile(src, existing) {
+		return nil
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("replacing %s: %w", dst, err)
+	}
+	if err := copyFile(saveFile, dst); err != nil {
+		return fmt.Errorf("copying save: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/WhyIsSandwich/factctl/internal/savegame"
+)
+
+func TestApplySave(t *testing.T) {
+	cfg := &Config{
+		Name:    "joined",
+		Version: "1.1",
+		Mods: ModsConfig{
+			Enabled:  []string{"base", "old-mod"},
+			Disabled: []string{"flib"},
+			Sources:  map[string]string{"mods": "github:someone/mods"},
+		},
+	}
+	header := &savegame.Header{
+		Version: "2.0.28",
+		Mods: []savegame.Mod{
+			{Name: "base", Version: "2.0.28"},
+			{Name: "quality", Version: "2.0.28"},
+			{Name: "flib", Version: "0.15.0"},
+		},
+	}
+
+	cfg.ApplySave(header, "/tmp/friends-map.zip")
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("ApplySave() made an invalid config: %v", err)
+	}
+
+	if cfg.Version != "2.0.28" || cfg.SaveFile != "friends-map.zip" {
+		t.Errorf("ApplySave() version %s, save file %s", cfg.Version, cfg.SaveFile)
+	}
+	if !slices.Equal(cfg.Mods.Enabled, []string{"base", "quality", "flib"}) || len(cfg.Mods.Disabled) != 0 {
+		t.Errorf("ApplySave() enabled %v disabled %v, want exactly the saved mods", cfg.Mods.Enabled, cfg.Mods.Disabled)
+	}
+	if cfg.Mods.Sources["flib"] != "portal:flib@0.15.0" || cfg.Mods.Pin["flib"] != "flib" {
+		t.Errorf("ApplySave() sources %v pins %v, want flib pinned to 0.15.0", cfg.Mods.Sources, cfg.Mods.Pin)
+	}
+	if _, ok := cfg.Mods.Sources["quality"]; ok {
+		t.Errorf("ApplySave() added a source for a built-in mod")
+	}
+}
+
+func TestCopySave(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{Dir: filepath.Join(tmpDir, "instance")}
+	save := filepath.Join(tmpDir, "world.zip")
+	if err := os.WriteFile(save, []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to write save: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := CopySave(inst, save); err != nil {
+			t.Fatalf("CopySave() error = %v", err)
+		}
+	}
+	copied := filepath.Join(inst.Dir, "saves", "world.zip")
+	if data, err := os.ReadFile(copied); err != nil || string(data) != "new" {
+		t.Fatalf("CopySave() wrote %q, %v", data, err)
+	}
+
+	// Copying a save onto itself keeps it
+	if err := CopySave(inst, copied); err != nil {
+		t.Fatalf("CopySave() of the instance's own save error = %v", err)
+	}
+	if data, err := os.ReadFile(copied); err != nil || string(data) != "new" {
+		t.Errorf("CopySave() of the instance's own save left %q, %v", data, err)
+	}
+}
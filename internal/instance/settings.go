@@ -0,0 +1,155 @@
+package instance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/WhyIsSandwich/factctl/internal/modsettings"
+)
+
+// ModSettingsPath returns the path of an instance's mod-settings.dat
+func ModSettingsPath(inst *Instance) string {
+	return filepath.Join(inst.Dir, "mods", "mod-settings.dat")
+}
+
+// LoadModSettings reads an instance's mod-settings.dat. An instance without
+// one gets an empty file for its Factorio version.
+func LoadModSettings(inst *Instance) (*modsettings.File, error) {
+	f, err := os.Open(ModSettingsPath(inst))
+	if os.IsNotExist(err) {
+		version, err := modsettings.ParseVersion(inst.Config.Version)
+		if err != nil {
+			return nil, err
+		}
+		return modsettings.New(version), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening mod settings: %w", err)
+	}
+	defer f.Close()
+
+	settings, err := modsettings.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading mod settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SaveModSettings writes an instance's mod-settings.dat
+func SaveModSettings(inst *Instance, settings *modsettings.File) error {
+	path := ModSettingsPath(inst)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating mod directory: %w", err)
+	}
+
+	// Write to a temporary file first so Factorio never sees a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mod-settings-*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing mod settings: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := modsettings.Encode(tmp, settings); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encoding mod settings: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing mod settings: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("writing mod settings: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing mod settings: %w", err)
+	}
+	return nil
+}
+
+// WriteModSettings merges the settings from the instance config into
+// mod-settings.dat and returns how many were written. Settings the config
+// doesn't mention keep their current values.
+func WriteModSettings(inst *Instance) (int, error) {
+	scoped, err := inst.Config.Mods.ScopedSettings()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, settings := range scoped {
+		count += len(settings)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	file, err := LoadModSettings(inst)
+	if err != nil {
+		return 0, err
+	}
+	for scope, settings := range scoped {
+		for name, value := range settings {
+			if err := file.Set(scope, name, value); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := SaveModSettings(inst, file); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SetModSetting changes a setting in both mod-settings.dat and the instance
+// config, so the next up writes the same value. An empty scope keeps the
+// setting's current scope, or uses startup for a new setting.
+func SetModSetting(inst *Instance, scope, name string, value any) error {
+	file, err := LoadModSettings(inst)
+	if err != nil {
+		return err
+	}
+	if scope == "" {
+		if current, _, ok := file.Get(name); ok {
+			scope = current
+		} else {
+			scope = modsettings.Startup
+		}
+	}
+	if !isSettingScope(scope) {
+		return fmt.Errorf("unknown setting scope %q (use startup, runtime-global or runtime-per-user)", scope)
+	}
+
+	if err := file.Set(scope, name, value); err != nil {
+		return err
+	}
+
+	mods := &inst.Config.Mods
+	if mods.Settings == nil {
+		mods.Settings = make(map[string]interface{})
+	}
+	if _, flat := mods.Settings[name]; flat && scope == modsettings.Startup {
+		mods.Settings[name] = value
+	} else {
+		scoped, _ := mods.Settings[scope].(map[string]interface{})
+		if scoped == nil {
+			scoped = make(map[string]interface{})
+			mods.Settings[scope] = scoped
+		}
+		scoped[name] = value
+	}
+
+	if err := inst.Config.SaveConfig(ConfigPath(inst)); err != nil {
+		return err
+	}
+	return SaveModSettings(inst, file)
+}
+
+// isSettingScope reports whether a name is one of mod-settings.dat's scopes
+func isSettingScope(name string) bool {
+	for _, scope := range modsettings.Scopes {
+		if name == scope {
+			return true
+		}
+	}
+	return false
+}
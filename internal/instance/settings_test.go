@@ -0,0 +1,94 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WhyIsSandwich/factctl/internal/modsettings"
+)
+
+func TestModSettings(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{
+			Name:    "test-instance",
+			Version: "1.1.87",
+			Mods: ModsConfig{
+				Settings: map[string]interface{}{
+					"flat-setting": true,
+					"runtime-global": map[string]interface{}{
+						"global-setting": "value",
+					},
+				},
+			},
+		},
+		Dir: filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+	if err := os.MkdirAll(filepath.Join(inst.Dir, "config"), 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	// A setting changed in game that the config doesn't mention
+	existing := modsettings.New(modsettings.Version{Major: 1, Minor: 1, Patch: 80})
+	existing.Settings[modsettings.Startup]["count"] = int64(3)
+	existing.Settings[modsettings.Startup]["flat-setting"] = false
+	if err := SaveModSettings(inst, existing); err != nil {
+		t.Fatalf("SaveModSettings() error = %v", err)
+	}
+
+	t.Run("write", func(t *testing.T) {
+		count, err := WriteModSettings(inst)
+		if err != nil || count != 2 {
+			t.Fatalf("WriteModSettings() = %d, %v, want 2", count, err)
+		}
+
+		file, err := LoadModSettings(inst)
+		if err != nil {
+			t.Fatalf("LoadModSettings() error = %v", err)
+		}
+		for name, want := range map[string]any{"flat-setting": true, "global-setting": "value", "count": int64(3)} {
+			if _, got, ok := file.Get(name); !ok || got != want {
+				t.Errorf("Get(%s) = %v, want %v", name, got, want)
+			}
+		}
+		if file.Version.Patch != 80 {
+			t.Errorf("WriteModSettings() changed the file version to %v", file.Version)
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		if err := SetModSetting(inst, "", "count", 7.0); err != nil {
+			t.Fatalf("SetModSetting() error = %v", err)
+		}
+		if err := SetModSetting(inst, "", "flat-setting", false); err != nil {
+			t.Fatalf("SetModSetting() error = %v", err)
+		}
+		if err := SetModSetting(inst, "bogus", "x", 1.0); err == nil {
+			t.Error("SetModSetting() accepted an unknown scope")
+		}
+
+		file, err := LoadModSettings(inst)
+		if err != nil {
+			t.Fatalf("LoadModSettings() error = %v", err)
+		}
+		if scope, got, _ := file.Get("count"); scope != modsettings.Startup || got != int64(7) {
+			t.Errorf("count = %s %v (%T), want startup 7 as an integer", scope, got, got)
+		}
+
+		cfg, err := LoadConfig(ConfigPath(inst))
+		if err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		scoped, err := cfg.Mods.ScopedSettings()
+		if err != nil {
+			t.Fatalf("ScopedSettings() error = %v", err)
+		}
+		if scoped[modsettings.Startup]["count"] != 7.0 || scoped[modsettings.Startup]["flat-setting"] != false {
+			t.Errorf("config settings = %v, want count 7 and flat-setting false", scoped)
+		}
+		if _, nested := cfg.Mods.Settings[modsettings.Startup].(map[string]interface{})["flat-setting"]; nested {
+			t.Error("SetModSetting() moved a flat setting into the startup group")
+		}
+	})
+}
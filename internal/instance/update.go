@@ -0,0 +1,386 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+// OutdatedMod describes an installed mod for which an update is available
+type OutdatedMod struct {
+	// Mod name
+	Name string
+
+	// Installed version
+	Installed string
+
+	// Version the update would install
+	Latest string
+
+	// Source the update comes from ("portal" for the mod portal)
+	Source string
+
+	// Commit SHA or portal release the update resolves to
+	Revision string
+
+	// Changelog entries for the versions between Installed and Latest
+	Changelog string
+}
+
+// ModUpdate records a mod whose installed version changed during an update
+type ModUpdate struct {
+	Name string
+	From string
+	To   string
+}
+
+// Outdated compares every installed mod with the newest compatible version in
+// the configured sources (at the head of their branch or pull request) and on
+// the mod portal, and returns the mods for which an update is available
+func (mm *ModManager) Outdated(ctx context.Context, inst *Instance) ([]*OutdatedMod, error) {
+	if err := mm.BuildSourceRegistry(ctx, inst); err != nil {
+		return nil, fmt.Errorf("building source registry: %w", err)
+	}
+
+	previous, err := LoadLockfile(inst)
+	if err != nil && err != ErrNoLockfile {
+		fmt.Printf("Warning: Ignoring unreadable lockfile: %v\n", err)
+	}
+
+	installed, err := mm.ListMods(inst)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(installed, func(i, j int) bool {
+		return installed[i].Name < installed[j].Name
+	})
+
+	update := make(map[string]bool)
+	for _, info := range installed {
+		update[info.Name] = true
+	}
+	registry := &modRegistry{ctx: ctx, mm: mm, inst: inst, previous: previous, update: update}
+	names := make([]string, len(installed))
+	for i, info := range installed {
+		names[i] = info.Name
+	}
+	registry.prefetchPortal(names)
+
+	var outdated []*OutdatedMod
+	for _, info := range installed {
+		if isBuiltinMod(info.Name) {
+			continue
+		}
+		if candidate := registry.newestUpdate(info.Name); candidate != nil {
+			outdated = append(outdated, &OutdatedMod{
+				Name:      info.Name,
+				Installed: info.Version,
+				Latest:    candidate.Version.String(),
+				Source:    candidate.Source,
+				Revision:  mm.candidateRevision(candidate),
+				Changelog: changelogBetween(mm.changelog(ctx, candidate), info.Version, candidate.Version.String()),
+			})
+		}
+	}
+
+	return outdated, nil
+}
+
+// newestUpdate returns the most preferred candidate that would update the
+// installed copy of a mod and targets the instance's Factorio version, or nil
+// if the installed copy is current
+func (r *modRegistry) newestUpdate(name string) *solver.Candidate {
+	installed := r.installedCandidate(name)
+	if installed == nil {
+		return nil
+	}
+
+	candidates, err := r.Candidates(name)
+	if err != nil {
+		return nil
+	}
+	for _, c := range candidates {
+		// Updates come first, so the first candidate that isn't one ends the search
+		if !r.isUpdate(installed, c) {
+			return nil
+		}
+		if c.FactorioVersion == "" || isVersionCompatible(r.inst.Config.Version, c.FactorioVersion) {
+			return c
+		}
+	}
+	return nil
+}
+
+// candidateRevision returns the commit or release a candidate would install
+func (mm *ModManager) candidateRevision(c *solver.Candidate) string {
+	if c.Source == PortalSourceName {
+		return c.Version.String()
+	}
+
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.sourceRevisions[c.Source]
+}
+
+// changelog returns the changelog.txt shipped with a candidate, or "" if it
+// has none or it can't be read
+func (mm *ModManager) changelog(ctx context.Context, c *solver.Candidate) string {
+	if c.Source == PortalSourceName {
+		text, err := mm.portal.Changelog(ctx, c.Name)
+		if err != nil {
+			return ""
+		}
+		return text
+	}
+
+	mm.mu.RLock()
+	entry := mm.sourceRegistry[c.Name][c.Source]
+	mm.mu.RUnlock()
+	if entry == nil {
+		return ""
+	}
+	data, err := entry.readFile("changelog.txt")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// UpdateMods updates the given installed mods, or every installed mod if none
+// are given, to the newest versions that still resolve with the rest of the
+// mod set. Saves and the current mods are backed up first; if resolution or
+// any install fails, the previous mod set is restored. It returns the mods
+// that changed and the backup directory.
+func (mm *ModManager) UpdateMods(ctx context.Context, inst *Instance, modNames []string) ([]ModUpdate, string, error) {
+	installed, err := mm.ListMods(inst)
+	if err != nil {
+		return nil, "", err
+	}
+	before := make(map[string]string)
+	for _, info := range installed {
+		before[info.Name] = info.Version
+	}
+	previous, _ := LoadLockfile(inst)
+
+	update := make(map[string]bool)
+	for _, name := range modNames {
+		if _, ok := before[name]; !ok {
+			return nil, "", fmt.Errorf("mod '%s' is not installed", name)
+		}
+		update[name] = true
+	}
+	if len(modNames) == 0 {
+		for name := range before {
+			update[name] = true
+		}
+	}
+
+	backupDir, err := mm.backupModSet(inst)
+	if err != nil {
+		return nil, "", fmt.Errorf("backing up saves and mods: %w", err)
+	}
+	fmt.Printf("Backed up saves and mods to %s\n", backupDir)
+
+	if _, err := mm.installMods(ctx, inst, inst.Config.Mods.Enabled, update); err != nil {
+		fmt.Println("Update failed, restoring the previous mod set...")
+		if restoreErr := mm.restoreModSet(inst, backupDir); restoreErr != nil {
+			return nil, backupDir, fmt.Errorf("%w; restoring previous mod set also failed: %v", err, restoreErr)
+		}
+		return nil, backupDir, err
+	}
+
+	// Every update copies all saves, so only the newest backups are kept
+	if err := mm.pruneUpdateBackups(inst, UpdateBackupsKept); err != nil {
+		fmt.Printf("  → Warning: Could not remove old backups: %v\n", err)
+	}
+
+	after, err := mm.ListMods(inst)
+	if err != nil {
+		return nil, backupDir, err
+	}
+	current, _ := LoadLockfile(inst)
+
+	var updates []ModUpdate
+	for _, info := range after {
+		from, ok := before[info.Name]
+		if !ok {
+			continue
+		}
+		if from != info.Version {
+			updates = append(updates, ModUpdate{Name: info.Name, From: from, To: info.Version})
+			continue
+		}
+
+		// Same version from a newer commit of its source
+		old, updated := lockedRevision(previous, info.Name), lockedRevision(current, info.Name)
+		if old != "" && updated != "" && old != updated {
+			updates = append(updates, ModUpdate{
+				Name: info.Name,
+				From: fmt.Sprintf("%s (%s)", from, shortRevision(old)),
+				To:   fmt.Sprintf("%s (%s)", info.Version, shortRevision(updated)),
+			})
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Name < updates[j].Name
+	})
+
+	return updates, backupDir, nil
+}
+
+// lockedRevision returns the revision a lockfile records for a mod, or "" if
+// there is no lockfile or the mod isn't in it
+func lockedRevision(lock *Lockfile, modName string) string {
+	if lock == nil {
+		return ""
+	}
+	if locked := lock.Mod(modName); locked != nil {
+		return locked.Revision
+	}
+	return ""
+}
+
+// modSetFiles are the files in config/ that belong to an instance's mod set
+var modSetFiles = []string{"mod-list.json", LockfileName}
+
+// UpdateBackupsKept is how many update backups of an instance are kept
+const UpdateBackupsKept = 3
+
+// updateBackupTime is the timestamp format in update backup directory names
+const updateBackupTime = "20060102-150405"
+
+// backupModSet copies an instance's saves, mods, mod list and lockfile into
+// a new directory under backups/ and returns its path
+func (mm *ModManager) backupModSet(inst *Instance) (string, error) {
+	backupDir := filepath.Join(mm.baseDir, "backups",
+		fmt.Sprintf("%s-update-%s", inst.Config.Name, time.Now().Format(updateBackupTime)))
+	if err := os.MkdirAll(filepath.Join(backupDir, "config"), 0755); err != nil {
+		return "", err
+	}
+
+	// Saves are written in place by Factorio, so they are always copied. Mod
+	// zips are only ever replaced, so they can share storage with the backup.
+	if err := copyTree(filepath.Join(inst.Dir, "saves"), filepath.Join(backupDir, "saves"), false); err != nil {
+		return "", fmt.Errorf("copying saves: %w", err)
+	}
+	if err := copyTree(filepath.Join(inst.Dir, "mods"), filepath.Join(backupDir, "mods"), true); err != nil {
+		return "", fmt.Errorf("copying mods: %w", err)
+	}
+
+	for _, name := range modSetFiles {
+		err := copyFile(filepath.Join(inst.Dir, "config", name), filepath.Join(backupDir, "config", name))
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("copying %s: %w", name, err)
+		}
+	}
+
+	return backupDir, nil
+}
+
+// pruneUpdateBackups removes all but the newest keep update backups of an
+// instance. Backups of other instances and of `down --backup` are left alone.
+func (mm *ModManager) pruneUpdateBackups(inst *Instance, keep int) error {
+	entries, err := os.ReadDir(filepath.Join(mm.baseDir, "backups"))
+	if err != nil {
+		return err
+	}
+
+	// Another instance's name can start with this one's, so the rest of the
+	// name has to be a timestamp
+	prefix := inst.Config.Name + "-update-"
+	var backups []string
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || !entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(updateBackupTime, stamp); err == nil {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	// Timestamps sort in time order
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err := os.RemoveAll(filepath.Join(mm.baseDir, "backups", backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// restoreModSet replaces an instance's mods, mod list and lockfile with the
+// ones saved by backupModSet. Saves are left alone.
+func (mm *ModManager) restoreModSet(inst *Instance, backupDir string) error {
+	modDir := filepath.Join(inst.Dir, "mods")
+	entries, err := os.ReadDir(modDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		// RemoveAll only removes the link for symlinked local mods
+		if err := os.RemoveAll(filepath.Join(modDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	if err := copyTree(filepath.Join(backupDir, "mods"), modDir, true); err != nil {
+		return fmt.Errorf("restoring mods: %w", err)
+	}
+
+	for _, name := range modSetFiles {
+		dst := filepath.Join(inst.Dir, "config", name)
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		err := copyFile(filepath.Join(backupDir, "config", name), dst)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("restoring %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// copyTree copies a directory tree, recreating symlinks as they are. With
+// linkZips set, zip files are linked with linkFile instead of copied. A
+// missing source directory is treated as empty.
+func copyTree(src, dst string, linkZips bool) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case linkZips && strings.HasSuffix(d.Name(), ".zip"):
+			_, err := linkFile(path, target, LinkAuto)
+			return err
+		default:
+			return copyFile(path, target)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestoreModSet(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{Name: "test-instance", Version: "1.1"},
+		Dir:    filepath.Join(tmpDir, "instances", "test-instance"),
+	}
+
+	files := map[string]string{
+		"saves/world.zip":        "save data",
+		"mods/old-mod_1.0.0.zip": "old mod",
+		"mods/mod-settings.dat":  "settings",
+		"config/mod-list.json":   `{"mods":[{"name":"old-mod","enabled":true}]}`,
+		"config/" + LockfileName: `{"version":1}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(inst.Dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// A local mod symlinked from elsewhere
+	localMod := filepath.Join(tmpDir, "local-mod")
+	if err := os.MkdirAll(localMod, 0755); err != nil {
+		t.Fatalf("Failed to create local mod: %v", err)
+	}
+	if err := os.Symlink(localMod, filepath.Join(inst.Dir, "mods", "local-mod")); err != nil {
+		t.Fatalf("Failed to symlink local mod: %v", err)
+	}
+
+	manager := NewModManager(tmpDir)
+	backupDir, err := manager.backupModSet(inst)
+	if err != nil {
+		t.Fatalf("backupModSet() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "saves", "world.zip")); err != nil {
+		t.Errorf("backup is missing the save: %v", err)
+	}
+
+	// A failed update replaced the mod, rewrote the mod list and the lockfile
+	// and was playing the save
+	os.Remove(filepath.Join(inst.Dir, "mods", "old-mod_1.0.0.zip"))
+	os.WriteFile(filepath.Join(inst.Dir, "mods", "old-mod_2.0.0.zip"), []byte("new mod"), 0644)
+	os.WriteFile(filepath.Join(inst.Dir, "config", "mod-list.json"), []byte(`{"mods":[]}`), 0644)
+	os.Remove(filepath.Join(inst.Dir, "config", LockfileName))
+	os.WriteFile(filepath.Join(inst.Dir, "saves", "world.zip"), []byte("newer save"), 0644)
+
+	if err := manager.restoreModSet(inst, backupDir); err != nil {
+		t.Fatalf("restoreModSet() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"mods/old-mod_1.0.0.zip": "old mod",
+		"mods/mod-settings.dat":  "settings",
+		"config/mod-list.json":   files["config/mod-list.json"],
+		"config/" + LockfileName: files["config/"+LockfileName],
+		"saves/world.zip":        "newer save",
+	} {
+		data, err := os.ReadFile(filepath.Join(inst.Dir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(inst.Dir, "mods", "old-mod_2.0.0.zip")); !os.IsNotExist(err) {
+		t.Error("restoreModSet() kept the updated mod")
+	}
+	if target, err := os.Readlink(filepath.Join(inst.Dir, "mods", "local-mod")); err != nil || target != localMod {
+		t.Errorf("local mod link = %q, %v, want %q", target, err, localMod)
+	}
+	if _, err := os.Stat(localMod); err != nil {
+		t.Errorf("restoreModSet() removed the local mod's directory: %v", err)
+	}
+}
+
+func TestPruneUpdateBackups(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &Instance{
+		Config: &Config{Name: "test", Version: "1.1"},
+		Dir:    filepath.Join(tmpDir, "instances", "test"),
+	}
+
+	backups := []string{
+		"test-update-20260101-120000",
+		"test-update-20260102-120000",
+		"test-update-20260103-120000",
+		"test-update-20260104-120000",
+		"test-update-extra-update-20260101-120000", // Update backup of instance test-update-extra
+		"test-20260101-120000.tar.gz",              // Made by down --backup
+	}
+	for _, name := range backups {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "backups", name), 0755); err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+	}
+
+	manager := NewModManager(tmpDir)
+	if err := manager.pruneUpdateBackups(inst, 2); err != nil {
+		t.Fatalf("pruneUpdateBackups() error = %v", err)
+	}
+
+	for i, name := range backups {
+		_, err := os.Stat(filepath.Join(tmpDir, "backups", name))
+		if removed := os.IsNotExist(err); removed != (i < 2) {
+			t.Errorf("%s removed = %v, want %v", name, removed, i < 2)
+		}
+	}
+}
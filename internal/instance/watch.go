@@ -0,0 +1,87 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often directories are rescanned where inotify isn't available
+const pollInterval = 500 * time.Millisecond
+
+// errWatchUnsupported is returned where the platform has no native file watching
+var errWatchUnsupported = errors.New("file watching not supported")
+
+// watchDirs sends the path of every file created, changed or removed below
+// dirs until ctx is done. It uses inotify where available and falls back to
+// rescanning the directories.
+func watchDirs(ctx context.Context, dirs []string, changes chan<- string) error {
+	if err := watchNative(ctx, dirs, changes); !errors.Is(err, errWatchUnsupported) {
+		return err
+	}
+	return pollDirs(ctx, dirs, pollInterval, changes)
+}
+
+// fileState is what pollDirs compares to notice a change
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// pollDirs rescans dirs every interval and reports the files that changed
+func pollDirs(ctx context.Context, dirs []string, interval time.Duration, changes chan<- string) error {
+	previous := scanDirs(dirs)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current := scanDirs(dirs)
+		var changed []string
+		for path, state := range current {
+			if old, ok := previous[path]; !ok || old != state {
+				changed = append(changed, path)
+			}
+		}
+		for path := range previous {
+			if _, ok := current[path]; !ok {
+				changed = append(changed, path)
+			}
+		}
+		previous = current
+
+		for _, path := range changed {
+			select {
+			case changes <- path:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// scanDirs records the state of every file below dirs. Files that vanish
+// during the scan are skipped.
+func scanDirs(dirs []string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				states[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			}
+			return nil
+		})
+	}
+	return states
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchMask selects the inotify events that change a mod's files
+const watchMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// watchNative watches dirs and their subdirectories with inotify. If inotify
+// can't be set up, for example because the watch limit is reached, it returns
+// errWatchUnsupported so the caller polls instead.
+func watchNative(ctx context.Context, dirs []string, changes chan<- string) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		fmt.Printf("  → Warning: inotify unavailable (%v), polling for changes instead\n", err)
+		return errWatchUnsupported
+	}
+	defer unix.Close(fd)
+
+	// Watch descriptor -> watched directory
+	watches := make(map[int]string)
+	addTree := func(root string) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			wd, err := unix.InotifyAddWatch(fd, path, watchMask)
+			if err != nil {
+				return fmt.Errorf("watching %s: %w", path, err)
+			}
+			watches[wd] = path
+			return nil
+		})
+	}
+	for _, dir := range dirs {
+		if err := addTree(dir); err != nil {
+			fmt.Printf("  → Warning: %v, polling for changes instead\n", err)
+			return errWatchUnsupported
+		}
+	}
+
+	send := func(path string) bool {
+		select {
+		case changes <- path:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for ctx.Err() == nil {
+		// Wake up regularly to notice ctx being done
+		n, err := unix.Poll(fds, 200)
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("waiting for file changes: %w", err)
+		}
+
+		n, err = unix.Read(fd, buf)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading file changes: %w", err)
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(event.Len)]
+			off += unix.SizeofInotifyEvent + int(event.Len)
+
+			// Events were lost, so treat everything as changed
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				for _, dir := range dirs {
+					if !send(dir) {
+						return nil
+					}
+				}
+				continue
+			}
+
+			dir, ok := watches[int(event.Wd)]
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(watches, int(event.Wd))
+				continue
+			}
+			if !ok {
+				continue
+			}
+
+			path := filepath.Join(dir, strings.TrimRight(string(name), "\x00"))
+			if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				// A failed watch only misses changes inside the new directory
+				addTree(path)
+			}
+			if !send(path) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
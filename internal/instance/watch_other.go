@@ -0,0 +1,10 @@
+//go:build !linux
+
+package instance
+
+import "context"
+
+// watchNative is not available on this platform; watchDirs falls back to polling
+func watchNative(ctx context.Context, dirs []string, changes chan<- string) error {
+	return errWatchUnsupported
+}
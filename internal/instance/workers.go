@@ -0,0 +1,110 @@
+package instance
+
+import (
+	"sync"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+// DefaultWorkers is how many sources or mods are downloaded at once when
+// neither the command line nor the instance config says otherwise
+const DefaultWorkers = 4
+
+// SetWorkers overrides the number of parallel downloads. Values below 1 fall
+// back to the instance config or DefaultWorkers.
+func (mm *ModManager) SetWorkers(n int) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.workers = n
+}
+
+// workerCount returns the number of parallel downloads to use for an instance
+func (mm *ModManager) workerCount(inst *Instance) int {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	switch {
+	case mm.workers > 0:
+		return mm.workers
+	case inst.Config.Mods.Workers > 0:
+		return inst.Config.Mods.Workers
+	default:
+		return DefaultWorkers
+	}
+}
+
+// forEach calls fn with the index of every item using at most workers goroutines.
+// Errors are returned in item order, so the outcome does not depend on scheduling.
+func forEach[T any](workers int, items []T, fn func(int, T) error) []error {
+	errs := make([]error, len(items))
+	if workers < 1 {
+		workers = 1
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = fn(i, items[i])
+			}
+		}()
+	}
+
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return errs
+}
+
+// installLevels groups the mods of a solution by dependency depth, deepest
+// first. Mods in the same level don't depend on each other, so each level can
+// be installed in parallel once the levels below it are done.
+func installLevels(solution *solver.Solution, roots []string) [][]string {
+	depth := make(map[string]int)
+	var queue []string
+	for _, name := range roots {
+		if c, ok := solution.Mods[name]; ok && !c.Builtin {
+			if _, seen := depth[name]; !seen {
+				depth[name] = 0
+				queue = append(queue, name)
+			}
+		}
+	}
+
+	// A mod sits one level below the deepest mod that needs it. Depth can never
+	// exceed the number of mods, which also stops dependency cycles.
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		for _, dep := range solution.Mods[name].Dependencies {
+			c, ok := solution.Mods[dep.Name]
+			if !ok || c.Builtin || dep.Kind == solver.Incompatible {
+				continue
+			}
+			d := depth[name] + 1
+			if current, seen := depth[dep.Name]; (!seen || d > current) && d < len(solution.Mods) {
+				depth[dep.Name] = d
+				queue = append(queue, dep.Name)
+			}
+		}
+	}
+
+	maxDepth := 0
+	for _, d := range depth {
+		maxDepth = max(maxDepth, d)
+	}
+
+	levels := make([][]string, maxDepth+1)
+	for _, name := range solution.Names() {
+		d := depth[name] // Mods no root reaches are installed with the roots
+		levels[maxDepth-d] = append(levels[maxDepth-d], name)
+	}
+	return levels
+}
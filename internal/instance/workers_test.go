@@ -0,0 +1,94 @@
+package instance
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
+)
+
+func TestForEach(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8}
+
+	var active, peak int32
+	errs := forEach(3, items, func(i, item int) error {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if item%3 == 0 {
+			return fmt.Errorf("item %d at %d", item, i)
+		}
+		return nil
+	})
+
+	if peak > 3 {
+		t.Errorf("forEach() ran %d workers at once, want at most 3", peak)
+	}
+	for i, err := range errs {
+		want := ""
+		if items[i]%3 == 0 {
+			want = fmt.Sprintf("item %d at %d", items[i], i)
+		}
+		if got := fmt.Sprint(err); (err == nil && want != "") || (err != nil && got != want) {
+			t.Errorf("forEach() errs[%d] = %v, want %q", i, err, want)
+		}
+	}
+}
+
+func TestInstallLevels(t *testing.T) {
+	candidate := func(name string, deps ...string) *solver.Candidate {
+		parsed, err := solver.ParseDependencies(deps)
+		if err != nil {
+			t.Fatalf("ParseDependencies() error = %v", err)
+		}
+		return &solver.Candidate{Name: name, Version: solver.MustParseVersion("1.0.0"), Dependencies: parsed}
+	}
+
+	solution := &solver.Solution{Mods: map[string]*solver.Candidate{
+		"base":     {Name: "base", Builtin: true},
+		"app":      candidate("app", "base", "lib", "ui", "! legacy"),
+		"ui":       candidate("ui", "lib"),
+		"lib":      candidate("lib", "base"),
+		"extra":    candidate("extra", "? ui"),
+		"cyclic-a": candidate("cyclic-a", "cyclic-b"),
+		"cyclic-b": candidate("cyclic-b", "cyclic-a"),
+	}}
+
+	got := installLevels(solution, []string{"app", "extra", "cyclic-a"})
+
+	// Every mod must come after all of its dependencies
+	position := make(map[string]int)
+	for i, level := range got {
+		for _, name := range level {
+			if _, dup := position[name]; dup {
+				t.Fatalf("installLevels() lists %s twice: %v", name, got)
+			}
+			position[name] = i
+		}
+	}
+	if len(position) != 6 {
+		t.Fatalf("installLevels() = %v, want all 6 non-builtin mods", got)
+	}
+	for _, pair := range [][2]string{{"lib", "ui"}, {"ui", "app"}, {"ui", "extra"}} {
+		if position[pair[0]] >= position[pair[1]] {
+			t.Errorf("installLevels() = %v, want %s before %s", got, pair[0], pair[1])
+		}
+	}
+
+	// The result must not depend on map iteration order
+	for i := 0; i < 10; i++ {
+		if again := installLevels(solution, []string{"app", "extra", "cyclic-a"}); !reflect.DeepEqual(again, got) {
+			t.Fatalf("installLevels() = %v, then %v", got, again)
+		}
+	}
+}
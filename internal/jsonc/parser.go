@@ -1,7 +1,6 @@
 package jsonc
 
 import (
-	"bytes"
 	"encoding/json"
 	"io"
 )
@@ -14,33 +13,55 @@ func Parse(r io.Reader, v interface{}) error {
 		return err
 	}
 
-	// Strip // line comments
-	lines := bytes.Split(data, []byte("\n"))
-	for i, line := range lines {
-		if idx := bytes.Index(line, []byte("//")); idx != -1 {
-			lines[i] = line[:idx]
-		}
-	}
-	data = bytes.Join(lines, []byte("\n"))
+	return json.Unmarshal(stripComments(data), v)
+}
 
-	// Strip /* */ block comments
-	var result []byte
-	inComment := false
+// stripComments removes comments outside of strings, so values such as URLs
+// keep their slashes
+func stripComments(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	inString := false
 	for i := 0; i < len(data); i++ {
-		if i < len(data)-1 && data[i] == '/' && data[i+1] == '*' {
-			inComment = true
-			i++
+		c := data[i]
+
+		if inString {
+			result = append(result, c)
+			switch c {
+			case '\\':
+				if i+1 < len(data) {
+					i++
+					result = append(result, data[i])
+				}
+			case '"':
+				inString = false
+			}
 			continue
 		}
-		if i < len(data)-1 && data[i] == '*' && data[i+1] == '/' {
-			inComment = false
+
+		switch {
+		case c == '"':
+			inString = true
+			result = append(result, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			// Line comment, up to the end of the line
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				result = append(result, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			// Block comment, keeping its line breaks
+			result = append(result, ' ')
+			for i += 2; i < len(data) && !(data[i] == '*' && i+1 < len(data) && data[i+1] == '/'); i++ {
+				if data[i] == '\n' {
+					result = append(result, '\n')
+				}
+			}
 			i++
-			continue
-		}
-		if !inComment {
-			result = append(result, data[i])
+		default:
+			result = append(result, c)
 		}
 	}
-
-	return json.Unmarshal(result, v)
-}
\ No newline at end of file
+	return result
+}
@@ -51,6 +51,19 @@ func TestParse(t *testing.T) {
 				"baz": float64(123),
 			},
 		},
+		{
+			name: "comment markers inside strings",
+			input: `{
+				"url": "url:https://example.com/mod.zip", // comment
+				"repo": "gh:owner/repo//mods/a /* not a comment */",
+				"quote": "say \"//\" twice" /* comment */
+			}`,
+			expected: map[string]interface{}{
+				"url":   "url:https://example.com/mod.zip",
+				"repo":  "gh:owner/repo//mods/a /* not a comment */",
+				"quote": `say "//" twice`,
+			},
+		},
 		{
 			name:    "invalid json",
 			input:   `{"foo": }`,
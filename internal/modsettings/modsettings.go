@@ -0,0 +1,485 @@
+// Package modsettings reads and writes Factorio's mod-settings.dat.
+//
+// The file starts with the version of Factorio that wrote it, followed by a
+// property tree: a dictionary with one entry per setting scope, mapping each
+// setting name to a dictionary holding its "value".
+package modsettings
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Setting scopes stored in mod-settings.dat
+const (
+	Startup        = "startup"
+	RuntimeGlobal  = "runtime-global"
+	RuntimePerUser = "runtime-per-user"
+)
+
+// Scopes lists the setting scopes in the order Factorio writes them
+var Scopes = []string{Startup, RuntimeGlobal, RuntimePerUser}
+
+// Property tree types
+const (
+	typeNone       = 0
+	typeBool       = 1
+	typeNumber     = 2
+	typeString     = 3
+	typeList       = 4
+	typeDictionary = 5
+	typeSigned     = 6
+	typeUnsigned   = 7
+)
+
+// maxStringLength guards against allocating huge buffers for corrupt files
+const maxStringLength = 16 << 20
+
+// ErrInvalid is returned for data that is not a valid mod-settings.dat
+var ErrInvalid = errors.New("invalid mod-settings.dat")
+
+// Version is the version of Factorio that wrote a file
+type Version struct {
+	Major, Minor, Patch, Developer uint16
+}
+
+// ParseVersion parses a Factorio version such as "1.1" or "1.1.87"
+func ParseVersion(s string) (Version, error) {
+	var parts [4]uint16
+	fields := strings.Split(s, ".")
+	if len(fields) < 2 || len(fields) > 4 {
+		return Version{}, fmt.Errorf("invalid Factorio version %q", s)
+	}
+	for i, field := range fields {
+		n, err := strconv.ParseUint(field, 10, 16)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid Factorio version %q", s)
+		}
+		parts[i] = uint16(n)
+	}
+	return Version{parts[0], parts[1], parts[2], parts[3]}, nil
+}
+
+// String returns the version as major.minor.patch
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// File is the decoded contents of a mod-settings.dat
+type File struct {
+	// Version of Factorio that wrote the file
+	Version Version
+
+	// Setting values by scope and setting name. Values are nil, bool,
+	// float64, int64, uint64, string, []any or map[string]any (colors).
+	Settings map[string]map[string]any
+}
+
+// New returns an empty settings file for a Factorio version
+func New(version Version) *File {
+	f := &File{Version: version, Settings: make(map[string]map[string]any)}
+	for _, scope := range Scopes {
+		f.Settings[scope] = make(map[string]any)
+	}
+	return f
+}
+
+// Get looks a setting up in every scope and returns its scope and value
+func (f *File) Get(name string) (string, any, bool) {
+	for _, scope := range f.scopes() {
+		if value, ok := f.Settings[scope][name]; ok {
+			return scope, value, true
+		}
+	}
+	return "", nil, false
+}
+
+// Set stores a setting value. A value replacing an existing one is converted
+// to the existing value's number type, so that integer settings stay integers.
+func (f *File) Set(scope, name string, value any) error {
+	value, err := normalize(value)
+	if err != nil {
+		return fmt.Errorf("setting %s: %w", name, err)
+	}
+
+	if f.Settings == nil {
+		f.Settings = make(map[string]map[string]any)
+	}
+	if f.Settings[scope] == nil {
+		f.Settings[scope] = make(map[string]any)
+	}
+	if existing, ok := f.Settings[scope][name]; ok {
+		value = convertNumber(value, existing)
+	}
+	f.Settings[scope][name] = value
+	return nil
+}
+
+// scopes returns the known scopes followed by any others in the file
+func (f *File) scopes() []string {
+	scopes := append([]string(nil), Scopes...)
+	var extra []string
+	for scope := range f.Settings {
+		if !isKnownScope(scope) {
+			extra = append(extra, scope)
+		}
+	}
+	sort.Strings(extra)
+	return append(scopes, extra...)
+}
+
+// isKnownScope reports whether a scope is one Factorio defines
+func isKnownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode reads a mod-settings.dat
+func Decode(r io.Reader) (*File, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+
+	var version [4]uint16
+	if err := binary.Read(d.r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("%w: reading version: %v", ErrInvalid, err)
+	}
+	// Unused flag written since Factorio 0.17
+	if _, err := d.readByte(); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %v", ErrInvalid, err)
+	}
+
+	tree, err := d.readTree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	root, ok := tree.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: root is not a dictionary", ErrInvalid)
+	}
+
+	f := New(Version{version[0], version[1], version[2], version[3]})
+	for scope, settings := range root {
+		entries, ok := settings.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: scope %q is not a dictionary", ErrInvalid, scope)
+		}
+		f.Settings[scope] = make(map[string]any, len(entries))
+		for name, entry := range entries {
+			setting, ok := entry.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: setting %q is not a dictionary", ErrInvalid, name)
+			}
+			f.Settings[scope][name] = setting["value"]
+		}
+	}
+	return f, nil
+}
+
+// Encode writes a mod-settings.dat. Dictionary keys are written in sorted
+// order, so the same settings always produce the same file.
+func Encode(w io.Writer, f *File) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	v := f.Version
+	if err := binary.Write(e.w, binary.LittleEndian, [4]uint16{v.Major, v.Minor, v.Patch, v.Developer}); err != nil {
+		return err
+	}
+	e.w.WriteByte(0)
+
+	root := make(map[string]any)
+	for _, scope := range f.scopes() {
+		settings := make(map[string]any, len(f.Settings[scope]))
+		for name, value := range f.Settings[scope] {
+			settings[name] = map[string]any{"value": value}
+		}
+		root[scope] = settings
+	}
+	if err := e.writeTree(root); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// decoder reads property trees
+type decoder struct {
+	r *bufio.Reader
+}
+
+func (d *decoder) readByte() (byte, error) {
+	return d.r.ReadByte()
+}
+
+func (d *decoder) readBool() (bool, error) {
+	b, err := d.r.ReadByte()
+	return b != 0, err
+}
+
+func (d *decoder) readUint32() (uint32, error) {
+	var n uint32
+	err := binary.Read(d.r, binary.LittleEndian, &n)
+	return n, err
+}
+
+// readString reads an optional string: an empty flag, then a length that takes
+// one byte below 255 and five bytes otherwise, then the bytes
+func (d *decoder) readString() (string, error) {
+	empty, err := d.readBool()
+	if err != nil || empty {
+		return "", err
+	}
+
+	b, err := d.readByte()
+	if err != nil {
+		return "", err
+	}
+	length := uint32(b)
+	if b == 0xFF {
+		if length, err = d.readUint32(); err != nil {
+			return "", err
+		}
+	}
+
+	if length > maxStringLength {
+		return "", fmt.Errorf("string of %d bytes is too long", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// readTree reads a property tree node
+func (d *decoder) readTree() (any, error) {
+	kind, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	// Any-type flag, only meaningful to Factorio itself
+	if _, err := d.readByte(); err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case typeNone:
+		return nil, nil
+	case typeBool:
+		return d.readBool()
+	case typeNumber:
+		var n float64
+		err := binary.Read(d.r, binary.LittleEndian, &n)
+		return n, err
+	case typeString:
+		return d.readString()
+	case typeList, typeDictionary:
+		count, err := d.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		var list []any
+		dict := make(map[string]any)
+		for i := uint32(0); i < count; i++ {
+			key, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.readTree()
+			if err != nil {
+				return nil, err
+			}
+			if kind == typeList {
+				list = append(list, value)
+			} else {
+				dict[key] = value
+			}
+		}
+		if kind == typeList {
+			return list, nil
+		}
+		return dict, nil
+	case typeSigned:
+		var n int64
+		err := binary.Read(d.r, binary.LittleEndian, &n)
+		return n, err
+	case typeUnsigned:
+		var n uint64
+		err := binary.Read(d.r, binary.LittleEndian, &n)
+		return n, err
+	default:
+		return nil, fmt.Errorf("unknown property tree type %d", kind)
+	}
+}
+
+// encoder writes property trees
+type encoder struct {
+	w *bufio.Writer
+}
+
+func (e *encoder) writeBool(b bool) {
+	if b {
+		e.w.WriteByte(1)
+	} else {
+		e.w.WriteByte(0)
+	}
+}
+
+func (e *encoder) writeUint32(n uint32) {
+	binary.Write(e.w, binary.LittleEndian, n)
+}
+
+func (e *encoder) writeString(s string) {
+	e.writeBool(s == "")
+	if s == "" {
+		return
+	}
+	if len(s) < 0xFF {
+		e.w.WriteByte(byte(len(s)))
+	} else {
+		e.w.WriteByte(0xFF)
+		e.writeUint32(uint32(len(s)))
+	}
+	e.w.WriteString(s)
+}
+
+// writeTree writes a property tree node
+func (e *encoder) writeTree(value any) error {
+	value, err := normalize(value)
+	if err != nil {
+		return err
+	}
+
+	header := func(kind byte) {
+		e.w.WriteByte(kind)
+		e.w.WriteByte(0)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		header(typeNone)
+	case bool:
+		header(typeBool)
+		e.writeBool(v)
+	case float64:
+		header(typeNumber)
+		binary.Write(e.w, binary.LittleEndian, v)
+	case int64:
+		header(typeSigned)
+		binary.Write(e.w, binary.LittleEndian, v)
+	case uint64:
+		header(typeUnsigned)
+		binary.Write(e.w, binary.LittleEndian, v)
+	case string:
+		header(typeString)
+		e.writeString(v)
+	case []any:
+		header(typeList)
+		e.writeUint32(uint32(len(v)))
+		for _, item := range v {
+			e.writeString("")
+			if err := e.writeTree(item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		header(typeDictionary)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.writeUint32(uint32(len(keys)))
+		for _, key := range keys {
+			e.writeString(key)
+			if err := e.writeTree(v[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// normalize converts Go values, such as those decoded from JSON, to the
+// types a property tree holds
+func normalize(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, float64, int64, uint64, string:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case float32:
+		return float64(v), nil
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = n
+		}
+		return list, nil
+	case map[string]any:
+		dict := make(map[string]any, len(v))
+		for key, item := range v {
+			n, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = n
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("unsupported setting value type %T", value)
+	}
+}
+
+// convertNumber converts a number to the type of the value it replaces, as
+// long as no precision is lost
+func convertNumber(value, existing any) any {
+	switch v := value.(type) {
+	case float64:
+		switch existing.(type) {
+		case int64:
+			if v == math.Trunc(v) && v >= -(1<<63) && v < 1<<63 {
+				return int64(v)
+			}
+		case uint64:
+			if v == math.Trunc(v) && v >= 0 && v < 1<<64 {
+				return uint64(v)
+			}
+		}
+	case int64:
+		switch existing.(type) {
+		case float64:
+			return float64(v)
+		case uint64:
+			if v >= 0 {
+				return uint64(v)
+			}
+		}
+	case uint64:
+		switch existing.(type) {
+		case float64:
+			return float64(v)
+		case int64:
+			if v <= math.MaxInt64 {
+				return int64(v)
+			}
+		}
+	}
+	return value
+}
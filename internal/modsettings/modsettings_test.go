@@ -0,0 +1,144 @@
+package modsettings
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	// Factorio 1.1.87 with one startup bool setting
+	data := []byte{
+		0x01, 0x00, 0x01, 0x00, 0x57, 0x00, 0x00, 0x00, // version
+		0x00,       // unused flag
+		0x05, 0x00, // root dictionary
+		0x03, 0x00, 0x00, 0x00,
+		0x00, 0x0e, 'r', 'u', 'n', 't', 'i', 'm', 'e', '-', 'g', 'l', 'o', 'b', 'a', 'l',
+		0x05, 0x00, 0x00, 0x00, 0x00, 0x00, // empty dictionary
+		0x00, 0x10, 'r', 'u', 'n', 't', 'i', 'm', 'e', '-', 'p', 'e', 'r', '-', 'u', 's', 'e', 'r',
+		0x05, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x07, 's', 't', 'a', 'r', 't', 'u', 'p',
+		0x05, 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x00, 0x0a, 'm', 'y', '-', 's', 'e', 't', 't', 'i', 'n', 'g',
+		0x05, 0x00,
+		0x01, 0x00, 0x00, 0x00,
+		0x00, 0x05, 'v', 'a', 'l', 'u', 'e',
+		0x01, 0x00, 0x01, // bool true
+	}
+
+	f, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if f.Version != (Version{1, 1, 87, 0}) {
+		t.Errorf("Decode() version = %v, want 1.1.87", f.Version)
+	}
+	if scope, value, ok := f.Get("my-setting"); !ok || scope != Startup || value != true {
+		t.Errorf("Get() = %s, %v, %v, want startup true", scope, value, ok)
+	}
+
+	// Writing the file back produces the same bytes
+	var buf bytes.Buffer
+	if err := Encode(&buf, f); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Encode() = % x, want % x", buf.Bytes(), data)
+	}
+
+	if _, err := Decode(bytes.NewReader(data[:20])); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Decode() of a truncated file error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	f := New(Version{2, 0, 28, 0})
+	values := map[string]any{
+		"bool":     false,
+		"double":   1.5,
+		"signed":   int64(-42),
+		"unsigned": uint64(42),
+		"string":   "hello",
+		"long":     strings.Repeat("x", 300),
+		"empty":    "",
+		"color":    map[string]any{"r": 1.0, "g": 0.5, "b": 0.0, "a": 1.0},
+		"list":     []any{"a", 2.0, true},
+		"none":     nil,
+	}
+	for name, value := range values {
+		if err := f.Set(RuntimeGlobal, name, value); err != nil {
+			t.Fatalf("Set(%s) error = %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, f); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, f) {
+		t.Errorf("round trip = %+v, want %+v", decoded, f)
+	}
+}
+
+func TestSet(t *testing.T) {
+	f := New(Version{1, 1, 0, 0})
+	f.Settings[Startup]["count"] = int64(5)
+	f.Settings[Startup]["ratio"] = 0.5
+
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{"count", 10.0, int64(10)},
+		{"count", 2.5, 2.5},
+		{"ratio", int64(2), 2.0},
+		{"new", 3.0, 3.0},
+		{"new-int", 3, int64(3)},
+	}
+
+	for _, tt := range tests {
+		if err := f.Set(Startup, tt.name, tt.value); err != nil {
+			t.Fatalf("Set(%s, %v) error = %v", tt.name, tt.value, err)
+		}
+		if got := f.Settings[Startup][tt.name]; got != tt.want {
+			t.Errorf("Set(%s, %v) stored %v (%T), want %v (%T)", tt.name, tt.value, got, got, tt.want, tt.want)
+		}
+		// Restore the original types for the next case
+		f.Settings[Startup]["count"] = int64(5)
+		f.Settings[Startup]["ratio"] = 0.5
+	}
+
+	if err := f.Set(Startup, "bad", struct{}{}); err == nil {
+		t.Error("Set() accepted an unsupported value type")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{"1.1", Version{1, 1, 0, 0}, false},
+		{"1.1.87", Version{1, 1, 87, 0}, false},
+		{"2.0.28.1", Version{2, 0, 28, 1}, false},
+		{"latest", Version{}, true},
+		{"1", Version{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package rcon implements the client side of the Source RCON protocol, which
+// Factorio servers speak when started with --rcon-port and --rcon-password.
+package rcon
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Packet types
+const (
+	typeResponse = 0
+	typeCommand  = 2
+	typeAuth     = 3
+)
+
+// maxPacketSize is the largest packet a server may send
+const maxPacketSize = 4096 + 10
+
+var (
+	// ErrAuth is returned when the server rejects the password
+	ErrAuth = errors.New("RCON password rejected")
+)
+
+// Client is a connection to an RCON server
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int32
+
+	// Timeout for each command round trip
+	timeout time.Duration
+}
+
+// Dial connects to an RCON server and authenticates with password
+func Dial(ctx context.Context, addr, password string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to RCON at %s: %w", addr, err)
+	}
+
+	c := &Client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		nextID:  1,
+		timeout: 10 * time.Second,
+	}
+
+	id, err := c.send(typeAuth, password)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The server may send an empty response before the auth result; the result
+	// carries the request ID, or -1 for a wrong password
+	for {
+		got, typ, _, err := c.read()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if typ != typeCommand {
+			continue
+		}
+		if got == -1 {
+			conn.Close()
+			return nil, ErrAuth
+		}
+		if got != id {
+			conn.Close()
+			return nil, fmt.Errorf("unexpected RCON auth response %d", got)
+		}
+		return c, nil
+	}
+}
+
+// Execute runs a console command, such as "/silent-command game.reload_mods()",
+// and returns its output
+func (c *Client) Execute(command string) (string, error) {
+	id, err := c.send(typeCommand, command)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		got, typ, body, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		if typ == typeResponse && got == id {
+			return body, nil
+		}
+	}
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// send writes a packet and returns its ID
+func (c *Client) send(typ int32, body string) (int32, error) {
+	id := c.nextID
+	c.nextID++
+
+	// Size counts the ID, type, body and the two terminating NULs
+	packet := make([]byte, 0, 14+len(body))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(10+len(body)))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(id))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(typ))
+	packet = append(packet, body...)
+	packet = append(packet, 0, 0)
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(packet); err != nil {
+		return 0, fmt.Errorf("sending RCON packet: %w", err)
+	}
+	return id, nil
+}
+
+// read reads one packet and returns its ID, type and body
+func (c *Client) read() (int32, int32, string, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	var size int32
+	if err := binary.Read(c.reader, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", fmt.Errorf("reading RCON packet: %w", err)
+	}
+	if size < 10 || size > maxPacketSize {
+		return 0, 0, "", fmt.Errorf("invalid RCON packet size %d", size)
+	}
+
+	packet := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, packet); err != nil {
+		return 0, 0, "", fmt.Errorf("reading RCON packet: %w", err)
+	}
+
+	id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+	typ := int32(binary.LittleEndian.Uint32(packet[4:8]))
+	body := string(packet[8 : size-2])
+	return id, typ, body, nil
+}
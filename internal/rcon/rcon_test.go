@@ -0,0 +1,94 @@
+package rcon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// testServer answers RCON packets like a Factorio server with the given password
+func testServer(t *testing.T, password string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, password)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serve(conn net.Conn, password string) {
+	defer conn.Close()
+
+	write := func(id, typ int32, body string) {
+		packet := binary.LittleEndian.AppendUint32(nil, uint32(10+len(body)))
+		packet = binary.LittleEndian.AppendUint32(packet, uint32(id))
+		packet = binary.LittleEndian.AppendUint32(packet, uint32(typ))
+		packet = append(append(packet, body...), 0, 0)
+		conn.Write(packet)
+	}
+
+	for {
+		var size int32
+		if err := binary.Read(conn, binary.LittleEndian, &size); err != nil {
+			return
+		}
+		packet := make([]byte, size)
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			return
+		}
+		id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+		typ := int32(binary.LittleEndian.Uint32(packet[4:8]))
+		body := string(packet[8 : size-2])
+
+		switch typ {
+		case typeAuth:
+			write(id, typeResponse, "")
+			if body != password {
+				id = -1
+			}
+			write(id, typeCommand, "")
+		case typeCommand:
+			write(id, typeResponse, "ran "+body)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	addr := testServer(t, "secret")
+	ctx := context.Background()
+
+	if _, err := Dial(ctx, addr, "wrong"); !errors.Is(err, ErrAuth) {
+		t.Errorf("Dial() with a wrong password error = %v, want ErrAuth", err)
+	}
+
+	c, err := Dial(ctx, addr, "secret")
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+
+	for _, command := range []string{"/silent-command game.reload_mods()", "/players"} {
+		out, err := c.Execute(command)
+		if err != nil {
+			t.Fatalf("Execute(%q) error = %v", command, err)
+		}
+		if out != "ran "+command {
+			t.Errorf("Execute(%q) = %q, want %q", command, out, "ran "+command)
+		}
+	}
+}
@@ -36,15 +36,3 @@ func TestConfig(baseURL string, client HTTPClient) ClientConfig {
 		UserAgent: "factctl-test",
 	}
 }
-
-// knownMultiModRepos is a list of repositories that are known to contain multiple mods
-var knownMultiModRepos = map[string]bool{
-	"modded-factorio/SeaBlock":   true,
-	"Arch666Angel/mods":          true,
-	"KiwiHawk/SeaBlock":          true, // Fork that PR 343 is from
-}
-
-// isMultiModRepo checks if a repository is known to contain multiple mods
-func isMultiModRepo(owner, repo string) bool {
-	return knownMultiModRepos[owner+"/"+repo]
-}
\ No newline at end of file
@@ -48,7 +48,7 @@ type URLFetcher struct {
 // NewURLFetcher creates a new URLFetcher
 func NewURLFetcher() *URLFetcher {
 	return &URLFetcher{
-		client: &http.Client{},
+		client: newHTTPClient(),
 	}
 }
 
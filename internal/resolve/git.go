@@ -1,76 +1,209 @@
 package resolve
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strings"
 )
 
-// Static config for testing
-var gitlabConfig = struct {
-	baseURL string
-}{
-	baseURL: "https://gitlab.com",
-}
+// commitSHA matches a full commit SHA (SHA-1 or SHA-256)
+var commitSHA = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
 
-// GitFetcher implements Fetcher for generic Git repositories
+// GitFetcher implements Fetcher for any Git remote the git binary can reach:
+// smart HTTP, ssh://, user@host:path, file:// and local paths
 type GitFetcher struct {
-	client *http.Client
+	// git binary to run (defaults to "git" on the PATH)
+	binary string
 }
 
 // NewGitFetcher creates a new GitFetcher
 func NewGitFetcher() *GitFetcher {
-	return &GitFetcher{
-		client: &http.Client{},
-	}
+	return &GitFetcher{binary: "git"}
 }
 
-// Fetch downloads a Git repository as a zip archive
-// Currently supports GitLab and custom GitLab instances
+// Fetch downloads a Git repository at its ref with a shallow fetch and writes
+// it as a zip archive with a top-level folder named after the repository
 func (f *GitFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (string, error) {
 	if src.Type != SourceGit {
 		return "", fmt.Errorf("invalid source type for Git fetcher: %v", src.Type)
 	}
 
-	// Parse host and path from repo ID
-	repoURL, err := url.Parse("https://" + src.ID)
+	remote := gitRemote(src.ID)
+
+	// Prefer the pinned commit over the branch or tag
+	ref := src.Revision
+	if ref == "" {
+		ref = src.Version
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	dir, err := os.MkdirTemp("", "factctl-git-*")
 	if err != nil {
-		return "", fmt.Errorf("invalid repository URL: %w", err)
+		return "", fmt.Errorf("creating temp directory: %w", err)
 	}
+	defer os.RemoveAll(dir)
 
-	// Always use the configured GitLab URL
-	path := strings.TrimPrefix(repoURL.Path, "/")
-	downloadURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/archive.zip?sha=%s",
-		gitlabConfig.baseURL, url.PathEscape(path), url.QueryEscape(src.Version))
+	if _, err := f.git(ctx, "", "init", "--quiet", "--bare", dir); err != nil {
+		return "", err
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
-	if err != nil {
+	// Servers that refuse to hand out a commit by SHA need a full fetch. The
+	// "--" keeps the remote and ref from being read as options such as
+	// --upload-pack, which runs a command.
+	if _, err := f.git(ctx, dir, "fetch", "--quiet", "--depth=1", "--no-tags", "--", remote, ref); err != nil {
+		if !commitSHA.MatchString(ref) {
+			return "", err
+		}
+		if _, err := f.git(ctx, dir, "fetch", "--quiet", "--tags", "--", remote, "+refs/heads/*:refs/heads/*"); err != nil {
+			return "", err
+		}
+	}
+
+	commit := ref
+	if !commitSHA.MatchString(ref) {
+		out, err := f.git(ctx, dir, "rev-parse", "FETCH_HEAD^{commit}")
+		if err != nil {
+			return "", err
+		}
+		commit = strings.TrimSpace(string(out))
+	}
+
+	h := sha256.New()
+	cmd := f.command(ctx, dir, "archive", "--format=zip", "--prefix="+repoName(src.ID)+"/", commit)
+	cmd.Stdout = io.MultiWriter(w, h)
+	if err := runGit(cmd, "archive"); err != nil {
 		return "", err
 	}
 
-	req.Header.Set("User-Agent", "factctl")
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ResolveRevision returns the commit SHA the source's branch or tag points to,
+// asking the remote without downloading anything. Without a ref the remote's
+// HEAD is used; a full commit SHA is returned as is.
+func (f *GitFetcher) ResolveRevision(ctx context.Context, src *Source) (string, error) {
+	if src.Type != SourceGit {
+		return "", fmt.Errorf("invalid source type for Git fetcher: %v", src.Type)
+	}
+
+	ref := src.Version
+	if commitSHA.MatchString(ref) {
+		return ref, nil
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
 
-	resp, err := f.client.Do(req)
+	out, err := f.git(ctx, "", "ls-remote", "--", gitRemote(src.ID), ref, ref+"^{}")
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Git API returned status %d", resp.StatusCode)
+	refs := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if sha, name, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			refs[name] = sha
+		}
 	}
 
-	h := sha256.New()
-	mw := io.MultiWriter(w, h)
+	// Same order as git itself, with annotated tags peeled to their commit
+	for _, name := range []string{
+		ref,
+		"refs/tags/" + ref + "^{}",
+		"refs/tags/" + ref,
+		"refs/heads/" + ref,
+	} {
+		if sha, ok := refs[name]; ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("ref %q not found in %s", ref, src.ID)
+}
 
-	if _, err := io.Copy(mw, resp.Body); err != nil {
-		return "", err
+// git runs a git command and returns its output
+func (f *GitFetcher) git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := f.command(ctx, dir, args...)
+	cmd.Stdout = &out
+	if err := runGit(cmd, args[0]); err != nil {
+		return nil, err
 	}
+	return out.Bytes(), nil
+}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
-}
\ No newline at end of file
+// command prepares a git command that never waits for credentials on the
+// terminal
+func (f *GitFetcher) command(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	if dir != "" {
+		args = append([]string{"--git-dir", dir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, f.binary, args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	return cmd
+}
+
+// runGit runs a git command, turning its stderr into the error
+func runGit(cmd *exec.Cmd, subcommand string) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("git is not installed: %w", err)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git %s: %s", subcommand, msg)
+		}
+		return fmt.Errorf("git %s: %w", subcommand, err)
+	}
+	return nil
+}
+
+// gitRemote turns the repository of a git: spec into something git can fetch.
+// URLs, scp-style user@host:path remotes and local paths are used as they are,
+// apart from expanding ~/; host/path is fetched over HTTPS.
+func gitRemote(id string) string {
+	if rest, ok := strings.CutPrefix(id, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+
+	switch {
+	case strings.Contains(id, "://"), strings.HasPrefix(id, "/"), strings.HasPrefix(id, "."):
+		return id
+	case isSCPRemote(id):
+		return id
+	default:
+		return "https://" + id
+	}
+}
+
+// isSCPRemote reports whether a remote is written user@host:path
+func isSCPRemote(id string) bool {
+	colon := strings.Index(id, ":")
+	return colon > 0 && !strings.Contains(id[:colon], "/") && strings.Contains(id[:colon], "@")
+}
+
+// repoName returns the name of a repository without its .git suffix
+func repoName(id string) string {
+	name := path.Base(strings.TrimRight(strings.ReplaceAll(id, ":", "/"), "/"))
+	if name = strings.TrimSuffix(name, ".git"); name == "" || name == "." || name == "/" {
+		return "repo"
+	}
+	return name
+}
@@ -1,117 +1,174 @@
 package resolve
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
-	"net/http"
-	"net/http/httptest"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
-func TestGitFetcher(t *testing.T) {
-	tests := []struct {
-		name      string
-		src       *Source
-		respCode  int
-		respBody  []byte
-		wantErr   bool
-		wantBytes []byte
-	}{
-		{
-			name: "successful gitlab.com fetch",
-			src: &Source{
-				Type:    SourceGit,
-				ID:      "gitlab.com/test/repo",
-				Version: "main",
-			},
-			respCode:  http.StatusOK,
-			respBody:  []byte("test gitlab content"),
-			wantBytes: []byte("test gitlab content"),
-		},
-		{
-			name: "successful self-hosted gitlab fetch",
-			src: &Source{
-				Type:    SourceGit,
-				ID:      "gitlab.example.com/test/repo",
-				Version: "main",
-			},
-			respCode:  http.StatusOK,
-			respBody:  []byte("test self-hosted content"),
-			wantBytes: []byte("test self-hosted content"),
-		},
-		{
-			name: "unsupported git host",
-			src: &Source{
-				Type:    SourceGit,
-				ID:      "unsupported.com/test/repo",
-				Version: "main",
-			},
-			wantErr: true,
-		},
-		{
-			name: "wrong source type",
-			src: &Source{
-				Type: SourcePortal,
-			},
-			wantErr: true,
-		},
+// testGitRepo creates a local repository with two commits of a mod on main and
+// an annotated tag on the first. It returns the repository and both commits.
+func testGitRepo(t *testing.T) (string, string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Start test server
-			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if ua := r.Header.Get("User-Agent"); ua != "factctl" {
-					t.Errorf("Expected User-Agent header 'factctl', got %q", ua)
-				}
-
-				if strings.Contains(tt.src.ID, "127.0.0.1") {
-					// Return success for our test server
-					w.WriteHeader(tt.respCode)
-					w.Write(tt.respBody)
-					return
-				}
-
-				w.WriteHeader(http.StatusNotFound)
-			}))
-			defer server.Close()
+	dir := filepath.Join(t.TempDir(), "my-mod.git")
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(version string) string {
+		info := `{"name": "my-mod", "version": "` + version + `"}`
+		if err := os.WriteFile(filepath.Join(dir, "info.json"), []byte(info), 0644); err != nil {
+			t.Fatalf("Failed to write info.json: %v", err)
+		}
+		git("add", "info.json")
+		git("commit", "--quiet", "-m", version)
+		return git("rev-parse", "HEAD")
+	}
 
-			// Override GitLab URL in tests
-			gitlabConfig.baseURL = server.URL
-			origID := tt.src.ID
-			if !tt.wantErr {
-				// Keep the path but use test server host
-				path := tt.src.ID[strings.Index(tt.src.ID, "/")+1:]
-				tt.src.ID = server.URL[7:] + "/" + path
-			}
-			
-			f := NewGitFetcher()
-			var buf bytes.Buffer
-			hash, err := f.Fetch(context.Background(), tt.src, &buf)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	first := commit("1.0.0")
+	git("tag", "-a", "v1.0.0", "-m", "release")
+	second := commit("1.1.0")
+	return dir, first, second
+}
 
-			// Restore original ID
-			tt.src.ID = origID
+func TestGitFetcher(t *testing.T) {
+	repo, first, second := testGitRepo(t)
+	ctx := context.Background()
+	f := NewGitFetcher()
 
+	t.Run("resolve revision", func(t *testing.T) {
+		tests := []struct {
+			ref     string
+			want    string
+			wantErr bool
+		}{
+			{ref: "", want: second},
+			{ref: "main", want: second},
+			{ref: "v1.0.0", want: first},
+			{ref: first, want: first},
+			{ref: "missing", wantErr: true},
+		}
+		for _, tt := range tests {
+			got, err := f.ResolveRevision(ctx, &Source{Type: SourceGit, ID: repo, Version: tt.ref})
 			if tt.wantErr {
 				if err == nil {
-					t.Error("Fetch() expected error but got nil")
+					t.Errorf("ResolveRevision(%q) expected error but got %s", tt.ref, got)
 				}
-				return
+				continue
 			}
-
-			if err != nil {
-				t.Errorf("Fetch() error = %v", err)
-				return
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveRevision(%q) = %s, %v, want %s", tt.ref, got, err, tt.want)
 			}
+		}
+	})
 
-			if len(hash) == 0 {
-				t.Error("Fetch() returned empty hash")
-			}
+	t.Run("fetch", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			src         *Source
+			wantVersion string
+		}{
+			{
+				name:        "branch from a local path",
+				src:         &Source{Type: SourceGit, ID: repo, Version: "main"},
+				wantVersion: "1.1.0",
+			},
+			{
+				name:        "tag over file://",
+				src:         &Source{Type: SourceGit, ID: "file://" + repo, Version: "v1.0.0"},
+				wantVersion: "1.0.0",
+			},
+			{
+				name:        "pinned commit",
+				src:         &Source{Type: SourceGit, ID: repo, Version: "main", Revision: first},
+				wantVersion: "1.0.0",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				hash, err := f.Fetch(ctx, tt.src, &buf)
+				if err != nil {
+					t.Fatalf("Fetch() error = %v", err)
+				}
+				if len(hash) != 64 {
+					t.Errorf("Fetch() hash = %q, want a SHA256", hash)
+				}
 
-			if !bytes.Equal(buf.Bytes(), tt.wantBytes) {
-				t.Errorf("Fetch() got content = %v, want %v", buf.String(), string(tt.wantBytes))
-			}
-		})
+				zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+				if err != nil {
+					t.Fatalf("Fetch() wrote an invalid zip: %v", err)
+				}
+				rc, err := zr.Open("my-mod/info.json")
+				if err != nil {
+					t.Fatalf("Fetch() archive has no my-mod/info.json: %v", err)
+				}
+				data, _ := io.ReadAll(rc)
+				rc.Close()
+				if !strings.Contains(string(data), tt.wantVersion) {
+					t.Errorf("Fetch() info.json = %s, want version %s", data, tt.wantVersion)
+				}
+			})
+		}
+	})
+
+	t.Run("option-like ref", func(t *testing.T) {
+		// The parser rejects such refs, but they must not reach git as
+		// options either
+		marker := filepath.Join(t.TempDir(), "pwned")
+		src := &Source{Type: SourceGit, ID: repo, Version: "--upload-pack=touch " + marker + "; git-upload-pack"}
+		if _, err := f.Fetch(ctx, src, io.Discard); err == nil {
+			t.Error("Fetch() expected error but got nil")
+		}
+		if _, err := f.ResolveRevision(ctx, src); err == nil {
+			t.Error("ResolveRevision() expected error but got nil")
+		}
+		if _, err := os.Stat(marker); err == nil {
+			t.Error("git ran the command given as a ref")
+		}
+	})
+
+	if _, err := f.Fetch(ctx, &Source{Type: SourceGit, ID: repo, Version: "missing"}, io.Discard); err == nil {
+		t.Error("Fetch() of a missing ref expected error but got nil")
+	}
+	if _, err := f.Fetch(ctx, &Source{Type: SourcePortal}, io.Discard); err == nil {
+		t.Error("Fetch() of a portal source expected error but got nil")
+	}
+}
+
+func TestGitRemote(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"gitlab.com/user/repo", "https://gitlab.com/user/repo"},
+		{"https://git.example.com/user/repo.git", "https://git.example.com/user/repo.git"},
+		{"ssh://git@git.example.com/user/repo.git", "ssh://git@git.example.com/user/repo.git"},
+		{"git@codeberg.org:user/repo.git", "git@codeberg.org:user/repo.git"},
+		{"/srv/mirrors/repo.git", "/srv/mirrors/repo.git"},
+		{"./mirror", "./mirror"},
+	}
+	for _, tt := range tests {
+		if got := gitRemote(tt.id); got != tt.want {
+			t.Errorf("gitRemote(%q) = %q, want %q", tt.id, got, tt.want)
+		}
 	}
-}
\ No newline at end of file
+}
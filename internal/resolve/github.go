@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 // Static config for testing
@@ -18,48 +20,47 @@ var githubConfig = struct {
 
 // GitHubFetcher implements Fetcher for GitHub repositories
 type GitHubFetcher struct {
-	client *http.Client
+	github *GitHubClient
 }
 
 // NewGitHubFetcher creates a new GitHubFetcher
 func NewGitHubFetcher() *GitHubFetcher {
 	return &GitHubFetcher{
-		client: &http.Client{},
+		github: defaultGitHubClient,
 	}
 }
 
+// WithClient sets the GitHub client, e.g. one with a token
+func (f *GitHubFetcher) WithClient(client *GitHubClient) *GitHubFetcher {
+	f.github = client
+	return f
+}
+
 // Fetch downloads a mod from GitHub as a zip archive
 func (f *GitHubFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (string, error) {
 	if src.Type != SourceGitHub {
 		return "", fmt.Errorf("invalid source type for GitHub fetcher: %v", src.Type)
 	}
 
-	// Verify SubPath is provided for multi-mod repos
-	if isMultiModRepo(src.Owner, src.Repo) && src.SubPath == "" {
-		return "", fmt.Errorf("SubPath is required for multi-mod repository %s/%s", src.Owner, src.Repo)
+	// Prefer the pinned commit over the branch or tag
+	ref := src.Revision
+	if ref == "" {
+		ref = src.Version
 	}
 
 	// GitHub API provides zip downloads at /repos/{owner}/{repo}/zipball/{ref}
 	downloadURL := fmt.Sprintf("%s/repos/%s/%s/zipball/%s",
 		githubConfig.baseURL,
-		src.Owner, src.Repo, src.Version)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
-	if err != nil {
-		return "", err
-	}
+		src.Owner, src.Repo, ref)
 
-	// GitHub requires a User-Agent
-	req.Header.Set("User-Agent", "factctl")
-
-	resp, err := f.client.Do(req)
+	resp, err := f.github.get(ctx, downloadURL, "", false)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return "", githubError(resp, fmt.Sprintf("zip download of %s/%s", src.Owner, src.Repo))
 	}
 
 	// Calculate SHA256 while copying to writer
@@ -71,4 +72,47 @@ func (f *GitHubFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (st
 	}
 
 	return hex.EncodeToString(h.Sum(nil)), nil
-}
\ No newline at end of file
+}
+
+// ResolveRevision returns the commit SHA the source's branch, tag or commit
+// points to. Without a ref the repository's default branch is used.
+func (f *GitHubFetcher) ResolveRevision(ctx context.Context, src *Source) (string, error) {
+	if src.Type != SourceGitHub {
+		return "", fmt.Errorf("invalid source type for GitHub fetcher: %v", src.Type)
+	}
+
+	ref := src.Version
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	return resolveGitHubCommit(ctx, f.github, src.Owner, src.Repo, ref)
+}
+
+// resolveGitHubCommit asks the GitHub API for the commit SHA of a ref
+func resolveGitHubCommit(ctx context.Context, github *GitHubClient, owner, repo, ref string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/commits/%s",
+		githubConfig.baseURL, owner, repo, url.PathEscape(ref))
+
+	// The sha media type returns the bare commit SHA
+	resp, err := github.get(ctx, apiURL, "application/vnd.github.sha", true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", githubError(resp, fmt.Sprintf("ref %q of %s/%s", ref, owner, repo))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("reading commit response: %w", err)
+	}
+
+	sha := strings.TrimSpace(string(body))
+	if len(sha) != 40 {
+		return "", fmt.Errorf("unexpected commit response for ref %q of %s/%s", ref, owner, repo)
+	}
+	return sha, nil
+}
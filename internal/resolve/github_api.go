@@ -0,0 +1,282 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxCachedResponse is the largest API response kept for conditional requests
+const maxCachedResponse = 1 << 20
+
+// ErrRateLimited is returned when the GitHub API rate limit is used up
+var ErrRateLimited = errors.New("GitHub API rate limit exceeded")
+
+// RateLimitError reports when an exhausted GitHub rate limit resets
+type RateLimitError struct {
+	Reset         time.Time
+	Authenticated bool
+}
+
+func (e *RateLimitError) Error() string {
+	msg := fmt.Sprintf("%v; it resets at %s (in %s)", ErrRateLimited,
+		e.Reset.Local().Format("15:04:05"), time.Until(e.Reset).Round(time.Minute))
+	if !e.Authenticated {
+		msg += ". Anonymous requests are limited to 60 per hour; set GITHUB_TOKEN or run 'factctl auth github' to raise the limit"
+	}
+	return msg
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// GitHubToken looks up the token sent with GitHub API requests. An empty
+// token means anonymous requests.
+type GitHubToken func() string
+
+// GitHubClient sends GitHub API requests for the GitHub fetchers. Requests
+// carry the token when there is one, API responses are revalidated with their
+// ETag (GitHub doesn't count a 304 against the rate limit), and a short wait
+// for the rate limit to reset is sat out rather than failing.
+type GitHubClient struct {
+	client *http.Client
+
+	token       GitHubToken
+	tokenOnce   sync.Once
+	cachedToken string
+
+	// Directory keeping API responses between runs; memory only when empty
+	cacheDir string
+
+	// Longest wait for a rate limit to reset before giving up
+	maxWait time.Duration
+
+	mu        sync.Mutex
+	responses map[string]*cachedResponse
+}
+
+// cachedResponse is an API response kept for a conditional request
+type cachedResponse struct {
+	ETag string `json:"etag"`
+	Body []byte `json:"body"`
+}
+
+// NewGitHubClient creates a GitHub client for anonymous requests
+func NewGitHubClient() *GitHubClient {
+	return &GitHubClient{
+		client:    newHTTPClient(),
+		maxWait:   time.Minute,
+		responses: make(map[string]*cachedResponse),
+	}
+}
+
+// defaultGitHubClient is shared by the GitHub fetchers unless given another one
+var defaultGitHubClient = NewGitHubClient()
+
+// WithToken sets how the API token is looked up; the lookup happens on first use
+func (c *GitHubClient) WithToken(token GitHubToken) *GitHubClient {
+	c.token = token
+	return c
+}
+
+// WithCache keeps API responses in dir, so conditional requests also work
+// across runs
+func (c *GitHubClient) WithCache(dir string) *GitHubClient {
+	c.cacheDir = dir
+	return c
+}
+
+// get sends a GET request to the GitHub API. Responses other than 200 are
+// returned for the caller to report, except an exhausted rate limit that
+// doesn't reset within maxWait, which is a *RateLimitError. With conditional,
+// the response is revalidated against an earlier copy.
+func (c *GitHubClient) get(ctx context.Context, url, accept string, conditional bool) (*http.Response, error) {
+	key := ""
+	var cached *cachedResponse
+	if conditional {
+		key = cacheKeyFor(url, accept)
+		cached = c.load(key)
+	}
+
+	token := c.lookupToken()
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		// GitHub requires a User-Agent
+		req.Header.Set("User-Agent", "factctl")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if cached != nil {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if reset, limited := rateLimitReset(resp); limited {
+			resp.Body.Close()
+			wait := time.Until(reset)
+			if wait > c.maxWait {
+				return nil, &RateLimitError{Reset: reset, Authenticated: token != ""}
+			}
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached != nil:
+			resp.Body.Close()
+			return cachedHTTPResponse(resp, cached.Body), nil
+		case resp.StatusCode == http.StatusOK && conditional && resp.Header.Get("ETag") != "":
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedResponse+1))
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("reading GitHub response: %w", err)
+			}
+			if len(body) <= maxCachedResponse {
+				c.store(key, &cachedResponse{ETag: resp.Header.Get("ETag"), Body: body})
+			}
+			return cachedHTTPResponse(resp, body), nil
+		default:
+			return resp, nil
+		}
+	}
+}
+
+// lookupToken returns the API token, looking it up once
+func (c *GitHubClient) lookupToken() string {
+	c.tokenOnce.Do(func() {
+		if c.token != nil {
+			c.cachedToken = c.token()
+		}
+	})
+	return c.cachedToken
+}
+
+// rateLimitReset reports whether a response was refused for exceeding a rate
+// limit and when the request may be retried
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, false
+	}
+
+	// Secondary rate limits ask for a pause
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(seconds) * time.Second), true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		// The primary limit resets hourly
+		return time.Now().Add(time.Hour), true
+	}
+	return time.Unix(reset, 0), true
+}
+
+// cachedHTTPResponse returns a 200 response with a body read earlier
+func cachedHTTPResponse(resp *http.Response, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        resp.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       resp.Request,
+	}
+}
+
+// cacheKeyFor names the cached response of a request
+func cacheKeyFor(url, accept string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + accept))
+	return hex.EncodeToString(sum[:])
+}
+
+// load returns the cached response for a key, from memory or disk
+func (c *GitHubClient) load(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.responses[key]; ok {
+		return cached
+	}
+	if c.cacheDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, key+".json"))
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil || cached.ETag == "" {
+		return nil
+	}
+	c.responses[key] = &cached
+	return &cached
+}
+
+// store keeps a response for later conditional requests. Failing to write
+// it to disk only costs a full request next time.
+func (c *GitHubClient) store(key string, cached *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.responses[key] = cached
+	if c.cacheDir == "" {
+		return
+	}
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.cacheDir, 0755); err != nil {
+		return
+	}
+	tmp := filepath.Join(c.cacheDir, key+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, filepath.Join(c.cacheDir, key+".json"))
+}
+
+// githubError describes a failed GitHub API response, including GitHub's own
+// message when it sent one
+func githubError(resp *http.Response, what string) error {
+	var body struct {
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if body.Message != "" {
+		return fmt.Errorf("GitHub API returned status %d for %s: %s", resp.StatusCode, what, body.Message)
+	}
+	return fmt.Errorf("GitHub API returned status %d for %s", resp.StatusCode, what)
+}
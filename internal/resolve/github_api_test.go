@@ -0,0 +1,133 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("token", func(t *testing.T) {
+		var got []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = append(got, r.Header.Get("Authorization"))
+		}))
+		defer server.Close()
+
+		for _, token := range []string{"", "secret"} {
+			c := NewGitHubClient().WithToken(func() string { return token })
+			resp, err := c.get(ctx, server.URL, "", false)
+			if err != nil {
+				t.Fatalf("get() error = %v", err)
+			}
+			resp.Body.Close()
+		}
+		if got[0] != "" || got[1] != "Bearer secret" {
+			t.Errorf("Authorization headers = %q, want none and then the token", got)
+		}
+	})
+
+	t.Run("conditional requests", func(t *testing.T) {
+		requests, notModified := 0, 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("abc123"))
+		}))
+		defer server.Close()
+
+		// The second client shares the first one's responses through the cache directory
+		dir := t.TempDir()
+		for _, c := range []*GitHubClient{
+			NewGitHubClient().WithCache(dir),
+			NewGitHubClient().WithCache(dir),
+		} {
+			for range 2 {
+				resp, err := c.get(ctx, server.URL, "application/vnd.github.sha", true)
+				if err != nil {
+					t.Fatalf("get() error = %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK || string(body) != "abc123" {
+					t.Errorf("get() = %d %q, want 200 \"abc123\"", resp.StatusCode, body)
+				}
+			}
+		}
+		if requests != 4 || notModified != 3 {
+			t.Errorf("server saw %d requests with %d not modified, want 4 with 3", requests, notModified)
+		}
+	})
+
+	t.Run("waits for a short rate limit", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		resp, err := NewGitHubClient().get(ctx, server.URL, "", false)
+		if err != nil {
+			t.Fatalf("get() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || requests != 2 {
+			t.Errorf("get() = %d after %d requests, want 200 after 2", resp.StatusCode, requests)
+		}
+	})
+
+	t.Run("reports a long rate limit", func(t *testing.T) {
+		reset := time.Now().Add(30 * time.Minute)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+
+		_, err := NewGitHubClient().get(ctx, server.URL, "", false)
+		var rateErr *RateLimitError
+		if !errors.As(err, &rateErr) || !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("get() error = %v, want a RateLimitError", err)
+		}
+		if !strings.Contains(err.Error(), reset.Local().Format("15:04:05")) || !strings.Contains(err.Error(), "GITHUB_TOKEN") {
+			t.Errorf("get() error = %q, want the reset time and a token hint", err)
+		}
+	})
+
+	t.Run("error message", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}))
+		defer server.Close()
+		origURL := githubConfig.baseURL
+		githubConfig.baseURL = server.URL
+		defer func() { githubConfig.baseURL = origURL }()
+
+		f := NewGitHubFetcher().WithClient(NewGitHubClient())
+		_, err := f.ResolveRevision(ctx, &Source{Type: SourceGitHub, Owner: "user", Repo: "missing", Version: "main"})
+		if err == nil || !strings.Contains(err.Error(), "status 404") || !strings.Contains(err.Error(), "Not Found") {
+			t.Errorf("ResolveRevision() error = %v, want the status and GitHub's message", err)
+		}
+	})
+}
@@ -12,16 +12,22 @@ import (
 
 // GitHubPRFetcher implements Fetcher for GitHub pull requests
 type GitHubPRFetcher struct {
-	client *http.Client
+	github *GitHubClient
 }
 
 // NewGitHubPRFetcher creates a new GitHubPRFetcher
 func NewGitHubPRFetcher() *GitHubPRFetcher {
 	return &GitHubPRFetcher{
-		client: &http.Client{},
+		github: defaultGitHubClient,
 	}
 }
 
+// WithClient sets the GitHub client, e.g. one with a token
+func (f *GitHubPRFetcher) WithClient(client *GitHubClient) *GitHubPRFetcher {
+	f.github = client
+	return f
+}
+
 type prResponse struct {
 	Head struct {
 		SHA string `json:"sha"`
@@ -34,66 +40,69 @@ func (f *GitHubPRFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (
 		return "", fmt.Errorf("invalid source type for GitHub PR fetcher: %v", src.Type)
 	}
 
-	// Verify SubPath is provided for multi-mod repos
-	if isMultiModRepo(src.Owner, src.Repo) && src.SubPath == "" {
-		return "", fmt.Errorf("SubPath is required for multi-mod repository %s/%s", src.Owner, src.Repo)
-	}
-
-	// First get the PR to find its head SHA
-	prURL := fmt.Sprintf("%s/repos/%s/%s/pulls/%d",
-		githubConfig.baseURL, // imported from github.go
-		src.Owner, src.Repo, src.PR)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", prURL, nil)
+	// Download the pinned commit, or the current head of the PR
+	sha, err := f.ResolveRevision(ctx, src)
 	if err != nil {
 		return "", err
 	}
 
-	req.Header.Set("User-Agent", "factctl")
+	// Now download the zip using the head SHA
+	downloadURL := fmt.Sprintf("%s/repos/%s/%s/zipball/%s",
+		githubConfig.baseURL, src.Owner, src.Repo, sha)
 
-	resp, err := f.client.Do(req)
+	resp, err := f.github.get(ctx, downloadURL, "", false)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned status %d for PR info", resp.StatusCode)
+		return "", githubError(resp, "zip download")
 	}
 
-	var pr prResponse
-	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
-		return "", fmt.Errorf("parsing PR response: %w", err)
+	// Calculate SHA256 while copying to writer
+	h := sha256.New()
+	mw := io.MultiWriter(w, h)
+
+	if _, err := io.Copy(mw, resp.Body); err != nil {
+		return "", err
 	}
 
-	// Now download the zip using the head SHA
-	downloadURL := fmt.Sprintf("%s/repos/%s/%s/zipball/%s",
-		githubConfig.baseURL, src.Owner, src.Repo, pr.Head.SHA)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	req, err = http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
-	if err != nil {
-		return "", err
+// ResolveRevision returns the head commit SHA of the pull request, or the
+// pinned revision if the source has one
+func (f *GitHubPRFetcher) ResolveRevision(ctx context.Context, src *Source) (string, error) {
+	if src.Type != SourceGitHubPR {
+		return "", fmt.Errorf("invalid source type for GitHub PR fetcher: %v", src.Type)
+	}
+	if src.Revision != "" {
+		return src.Revision, nil
 	}
 
-	req.Header.Set("User-Agent", "factctl")
+	prURL := fmt.Sprintf("%s/repos/%s/%s/pulls/%d",
+		githubConfig.baseURL, // imported from github.go
+		src.Owner, src.Repo, src.PR)
 
-	resp, err = f.client.Do(req)
+	resp, err := f.github.get(ctx, prURL, "", true)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned status %d for zip download", resp.StatusCode)
+		return "", githubError(resp, fmt.Sprintf("PR #%d of %s/%s", src.PR, src.Owner, src.Repo))
 	}
 
-	// Calculate SHA256 while copying to writer
-	h := sha256.New()
-	mw := io.MultiWriter(w, h)
+	var pr prResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return "", fmt.Errorf("parsing PR response: %w", err)
+	}
 
-	if _, err := io.Copy(mw, resp.Body); err != nil {
-		return "", err
+	if pr.Head.SHA == "" {
+		return "", fmt.Errorf("PR #%d has no head commit", src.PR)
 	}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
-}
\ No newline at end of file
+	return pr.Head.SHA, nil
+}
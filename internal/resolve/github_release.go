@@ -0,0 +1,182 @@
+package resolve
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// GitHubReleaseFetcher implements Fetcher for mod zips published as GitHub
+// release assets. The revision of a source is the ID of the asset, which
+// changes whenever the asset is uploaded again.
+type GitHubReleaseFetcher struct {
+	github *GitHubClient
+}
+
+// NewGitHubReleaseFetcher creates a new GitHubReleaseFetcher
+func NewGitHubReleaseFetcher() *GitHubReleaseFetcher {
+	return &GitHubReleaseFetcher{
+		github: defaultGitHubClient,
+	}
+}
+
+// WithClient sets the GitHub client, e.g. one with a token
+func (f *GitHubReleaseFetcher) WithClient(client *GitHubClient) *GitHubReleaseFetcher {
+	f.github = client
+	return f
+}
+
+type releaseResponse struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// Fetch downloads the release asset and checks that it is a mod before writing
+// it to w
+func (f *GitHubReleaseFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (string, error) {
+	if src.Type != SourceGitHubRelease {
+		return "", fmt.Errorf("invalid source type for GitHub release fetcher: %v", src.Type)
+	}
+
+	// Download the pinned asset, or the one the release currently has
+	id, err := f.ResolveRevision(ctx, src)
+	if err != nil {
+		return "", err
+	}
+
+	downloadURL := fmt.Sprintf("%s/repos/%s/%s/releases/assets/%s",
+		githubConfig.baseURL, src.Owner, src.Repo, id)
+
+	// The octet-stream media type redirects to the file itself
+	resp, err := f.github.get(ctx, downloadURL, "application/octet-stream", false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", githubError(resp, fmt.Sprintf("release asset %s of %s/%s", id, src.Owner, src.Repo))
+	}
+
+	// Keep a copy on disk so info.json can be checked before anything is written
+	tmp, err := os.CreateTemp("", "factctl-release-*.zip")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("downloading release asset: %w", err)
+	}
+	if _, err := ReadModInfo(tmp, size, ""); err != nil {
+		return "", fmt.Errorf("release asset %s of %s/%s is not a mod: %w", id, src.Owner, src.Repo, err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("reading temp file: %w", err)
+	}
+
+	// Calculate SHA256 while copying to writer
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), tmp); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ResolveRevision returns the ID of the release asset the source selects, or
+// the pinned asset if the source has one
+func (f *GitHubReleaseFetcher) ResolveRevision(ctx context.Context, src *Source) (string, error) {
+	if src.Type != SourceGitHubRelease {
+		return "", fmt.Errorf("invalid source type for GitHub release fetcher: %v", src.Type)
+	}
+	if src.Revision != "" {
+		return src.Revision, nil
+	}
+
+	tag := src.Version
+	if tag == "" {
+		tag = "latest"
+	}
+
+	releaseURL := fmt.Sprintf("%s/repos/%s/%s/releases/latest", githubConfig.baseURL, src.Owner, src.Repo)
+	if tag != "latest" {
+		releaseURL = fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s",
+			githubConfig.baseURL, src.Owner, src.Repo, url.PathEscape(tag))
+	}
+
+	resp, err := f.github.get(ctx, releaseURL, "application/vnd.github+json", true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", githubError(resp, fmt.Sprintf("release %q of %s/%s", tag, src.Owner, src.Repo))
+	}
+
+	var release releaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("parsing release response: %w", err)
+	}
+
+	asset, err := selectAsset(&release, src.Asset)
+	if err != nil {
+		return "", fmt.Errorf("release %s of %s/%s: %w", release.TagName, src.Owner, src.Repo, err)
+	}
+	return strconv.FormatInt(asset.ID, 10), nil
+}
+
+// selectAsset returns the one asset of a release whose name matches pattern,
+// which defaults to any zip
+func selectAsset(release *releaseResponse, pattern string) (*releaseAsset, error) {
+	if pattern == "" {
+		pattern = "*.zip"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: invalid asset pattern %q", ErrInvalidSource, pattern)
+	}
+
+	var matches []*releaseAsset
+	var names []string
+	for i := range release.Assets {
+		asset := &release.Assets[i]
+		names = append(names, asset.Name)
+		if ok, _ := path.Match(pattern, asset.Name); ok {
+			matches = append(matches, asset)
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		if len(names) == 0 {
+			return nil, fmt.Errorf("release has no assets")
+		}
+		return nil, fmt.Errorf("no asset matches %q (assets: %s)", pattern, strings.Join(names, ", "))
+	default:
+		var matched []string
+		for _, asset := range matches {
+			matched = append(matched, asset.Name)
+		}
+		return nil, fmt.Errorf("several assets match %q (%s); select one with /<asset-glob> after the tag", pattern, strings.Join(matched, ", "))
+	}
+}
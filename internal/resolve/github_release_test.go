@@ -0,0 +1,137 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitHubReleaseFetcher(t *testing.T) {
+	assets := map[string]string{
+		"1": testZip(t, map[string]*ModInfo{"my-mod_1.0.0": {Name: "my-mod", Version: "1.0.0"}}),
+		"2": testZip(t, map[string]*ModInfo{"my-mod_1.1.0": {Name: "my-mod", Version: "1.1.0"}}),
+		"3": "sha256 checksums",
+	}
+	releases := map[string]string{
+		"latest": `{"tag_name": "v1.1.0", "assets": [
+			{"id": 2, "name": "my-mod_1.1.0.zip"},
+			{"id": 3, "name": "checksums.txt"}]}`,
+		"tags/v1.0.0": `{"tag_name": "v1.0.0", "assets": [
+			{"id": 1, "name": "my-mod_1.0.0.zip"},
+			{"id": 4, "name": "my-mod-extras_1.0.0.zip"}]}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if release, ok := strings.CutPrefix(r.URL.Path, "/repos/user/my-mod/releases/assets/"); ok {
+			// GitHub redirects asset downloads to their storage
+			http.Redirect(w, r, "/download/"+release, http.StatusFound)
+			return
+		}
+		if id, ok := strings.CutPrefix(r.URL.Path, "/download/"); ok {
+			if r.Header.Get("Accept") != "application/octet-stream" {
+				t.Errorf("Asset requested with Accept %q", r.Header.Get("Accept"))
+			}
+			w.Write([]byte(assets[id]))
+			return
+		}
+		if release, ok := releases[strings.TrimPrefix(r.URL.Path, "/repos/user/my-mod/releases/")]; ok {
+			w.Write([]byte(release))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	}))
+	defer server.Close()
+
+	origURL := githubConfig.baseURL
+	githubConfig.baseURL = server.URL
+	defer func() { githubConfig.baseURL = origURL }()
+
+	tests := []struct {
+		name         string
+		src          *Source
+		wantRevision string
+		wantVersion  string
+		wantErr      string
+	}{
+		{
+			name:         "latest release",
+			src:          &Source{Version: "latest"},
+			wantRevision: "2",
+			wantVersion:  "1.1.0",
+		},
+		{
+			name:         "tag with asset glob",
+			src:          &Source{Version: "v1.0.0", Asset: "my-mod_*"},
+			wantRevision: "1",
+			wantVersion:  "1.0.0",
+		},
+		{
+			name:         "pinned asset",
+			src:          &Source{Version: "v9.9.9", Revision: "1"},
+			wantRevision: "1",
+			wantVersion:  "1.0.0",
+		},
+		{
+			name:    "several matching assets",
+			src:     &Source{Version: "v1.0.0"},
+			wantErr: "several assets match",
+		},
+		{
+			name:    "no matching asset",
+			src:     &Source{Version: "latest", Asset: "*.tar.gz"},
+			wantErr: "no asset matches",
+		},
+		{
+			name:         "asset that is not a mod",
+			src:          &Source{Version: "latest", Asset: "*.txt"},
+			wantRevision: "3",
+			wantErr:      "not a mod",
+		},
+		{
+			name:    "missing release",
+			src:     &Source{Version: "v2.0.0"},
+			wantErr: "Not Found",
+		},
+	}
+
+	f := NewGitHubReleaseFetcher().WithClient(NewGitHubClient())
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := *tt.src
+			src.Type, src.Owner, src.Repo = SourceGitHubRelease, "user", "my-mod"
+
+			revision, err := f.ResolveRevision(ctx, &src)
+			if err == nil && revision != tt.wantRevision {
+				t.Errorf("ResolveRevision() = %q, want %q", revision, tt.wantRevision)
+			}
+
+			var buf bytes.Buffer
+			hash, fetchErr := f.Fetch(ctx, &src, &buf)
+			if tt.wantErr != "" {
+				if fetchErr == nil || !strings.Contains(fetchErr.Error(), tt.wantErr) {
+					t.Errorf("Fetch() error = %v, want %q", fetchErr, tt.wantErr)
+				}
+				if buf.Len() != 0 {
+					t.Errorf("Fetch() wrote %d bytes despite the error", buf.Len())
+				}
+				return
+			}
+			if err != nil || fetchErr != nil {
+				t.Fatalf("ResolveRevision() error = %v, Fetch() error = %v", err, fetchErr)
+			}
+
+			if len(hash) != 64 {
+				t.Errorf("Fetch() hash = %q, want a SHA256", hash)
+			}
+			info, err := ReadModInfo(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "")
+			if err != nil || info.Version != tt.wantVersion {
+				t.Errorf("Fetch() wrote mod %v (%v), want version %s", info, err, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -12,10 +12,8 @@ import (
 // saveAPIs saves the current API URLs and returns a function to restore them
 func saveAPIs() func() {
 	origGitHubURL := githubConfig.baseURL
-	origGitLabURL := gitlabConfig.baseURL
 	return func() {
 		githubConfig.baseURL = origGitHubURL
-		gitlabConfig.baseURL = origGitLabURL
 	}
 }
 
@@ -28,7 +26,6 @@ func TestIntegrationWithRealRepos(t *testing.T) {
 	restore := saveAPIs()
 	defer restore()
 	githubConfig.baseURL = "https://api.github.com"
-	gitlabConfig.baseURL = "https://gitlab.com"
 
 	tests := []struct {
 		name    string
@@ -81,7 +78,7 @@ func TestIntegrationWithRealRepos(t *testing.T) {
 				Owner:   "modded-factorio",
 				Repo:    "SeaBlock",
 				Version: "main",
-				// Missing SubPath for multi-mod repo should error
+				// The repository holds several mods, so one must be selected
 			},
 			wantErr: true,
 		},
@@ -101,39 +98,30 @@ func TestIntegrationWithRealRepos(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var fetcher Fetcher
-			switch tt.src.Type {
-			case SourceGitHub:
-				fetcher = NewGitHubFetcher()
-			case SourceGitHubPR:
-				fetcher = NewGitHubPRFetcher()
-			case SourceGit:
-				fetcher = NewGitFetcher()
-			default:
-				t.Fatalf("Unsupported source type: %v", tt.src.Type)
-			}
+			resolver := NewDefaultResolver()
 
 			var buf bytes.Buffer
-			hash, err := fetcher.Fetch(ctx, tt.src, &buf)
+			info, err := resolver.ResolveSource(ctx, tt.src, &buf)
 
 			if tt.wantErr {
 				if err == nil {
-					t.Error("Fetch() expected error but got nil")
+					t.Error("ResolveSource() expected error but got nil")
 				}
 				return
 			}
 
 			if err != nil {
-				t.Errorf("Fetch() error = %v", err)
+				t.Errorf("ResolveSource() error = %v", err)
 				return
 			}
 
+			hash := info.Hash
 			if len(hash) == 0 {
-				t.Error("Fetch() returned empty hash")
+				t.Error("ResolveSource() returned empty hash")
 			}
 
 			if buf.Len() == 0 {
-				t.Error("Fetch() returned no data")
+				t.Error("ResolveSource() returned no data")
 			}
 
 			// Validate the downloaded content
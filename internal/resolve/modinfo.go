@@ -0,0 +1,202 @@
+package resolve
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNoModInfo is returned when an archive contains no usable info.json
+	ErrNoModInfo = errors.New("no info.json found in archive")
+)
+
+// ReadModInfo reads the info.json of the mod contained in a zip archive.
+// Mod zips keep info.json in a top-level folder and repository archives may
+// nest it deeper, so the shallowest info.json is used. If subPath is set, only
+// an info.json in that directory below the archive's top-level folder counts,
+// or failing that, the mod named subPath anywhere in the archive.
+func ReadModInfo(r io.ReaderAt, size int64, subPath string) (*ModInfo, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("reading zip: %w", err)
+	}
+
+	subPath = strings.Trim(subPath, "/")
+
+	var candidates []*zip.File
+	best := -1
+	for _, file := range zr.File {
+		if path.Base(file.Name) != "info.json" {
+			continue
+		}
+
+		dir := path.Dir(file.Name)
+		if subPath != "" && !matchesSubPath(dir, subPath) {
+			continue
+		}
+
+		depth := dirDepth(dir)
+		switch {
+		case best == -1 || depth < best:
+			best = depth
+			candidates = []*zip.File{file}
+		case depth == best:
+			candidates = append(candidates, file)
+		}
+	}
+
+	if len(candidates) == 0 {
+		if subPath == "" {
+			return nil, ErrNoModInfo
+		}
+		mods := indexArchive(zr)
+		if mod, ok := mods[subPath]; ok {
+			return mod.Info, nil
+		}
+		return nil, notFound(subPath, mods)
+	}
+
+	if len(candidates) > 1 {
+		mods := make(map[string]*ArchiveMod)
+		for _, file := range candidates {
+			dir := path.Dir(file.Name)
+			mods[path.Base(dir)] = &ArchiveMod{Dir: dir}
+		}
+		return nil, fmt.Errorf("archive contains several mods (%s); select one with //<directory> in the source", describeMods(mods))
+	}
+
+	rc, err := candidates[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening info.json: %w", err)
+	}
+	defer rc.Close()
+
+	var info ModInfo
+	if err := json.NewDecoder(rc).Decode(&info); err != nil {
+		return nil, fmt.Errorf("parsing info.json: %w", err)
+	}
+
+	if info.Name == "" || info.Version == "" {
+		return nil, fmt.Errorf("info.json is missing name or version")
+	}
+
+	return &info, nil
+}
+
+// matchesSubPath reports whether dir is subPath, either at the archive root or
+// below a single top-level folder
+func matchesSubPath(dir, subPath string) bool {
+	if dir == subPath {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(dir, "/"+subPath)
+	return ok && !strings.Contains(prefix, "/")
+}
+
+// ArchiveMod locates a mod inside a zip archive
+type ArchiveMod struct {
+	Info *ModInfo
+	Dir  string // Folder containing the mod's info.json, "." for the archive root
+}
+
+// ListArchiveMods indexes every mod in a zip archive by name without extracting
+// anything. When a mod appears more than once the shallowest copy wins. If
+// subPath is set, only the mod in that directory is listed, or failing that,
+// the mod named subPath.
+func ListArchiveMods(r io.ReaderAt, size int64, subPath string) (map[string]*ArchiveMod, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("reading zip: %w", err)
+	}
+
+	mods := indexArchive(zr)
+	if len(mods) == 0 {
+		return nil, ErrNoModInfo
+	}
+
+	subPath = strings.Trim(subPath, "/")
+	if subPath == "" {
+		return mods, nil
+	}
+
+	var match *ArchiveMod
+	for _, mod := range mods {
+		if matchesSubPath(mod.Dir, subPath) && (match == nil || dirDepth(mod.Dir) < dirDepth(match.Dir)) {
+			match = mod
+		}
+	}
+	if match == nil {
+		match = mods[subPath]
+	}
+	if match == nil {
+		return nil, notFound(subPath, mods)
+	}
+	return map[string]*ArchiveMod{match.Info.Name: match}, nil
+}
+
+// indexArchive reads every valid info.json in a zip archive
+func indexArchive(zr *zip.Reader) map[string]*ArchiveMod {
+	mods := make(map[string]*ArchiveMod)
+	for _, file := range zr.File {
+		if path.Base(file.Name) != "info.json" {
+			continue
+		}
+		dir := path.Dir(file.Name)
+
+		rc, err := file.Open()
+		if err != nil {
+			continue
+		}
+		var info ModInfo
+		err = json.NewDecoder(rc).Decode(&info)
+		rc.Close()
+		if err != nil || info.Name == "" || info.Version == "" {
+			continue
+		}
+
+		if existing, ok := mods[info.Name]; ok && dirDepth(existing.Dir) <= dirDepth(dir) {
+			continue
+		}
+		mods[info.Name] = &ArchiveMod{Info: &info, Dir: dir}
+	}
+	return mods
+}
+
+// notFound explains that an archive has no mod at subPath, listing the mods it
+// does have
+func notFound(subPath string, mods map[string]*ArchiveMod) error {
+	if len(mods) == 0 {
+		return fmt.Errorf("%w in directory %q", ErrNoModInfo, subPath)
+	}
+	return fmt.Errorf("%w in directory %q; the archive contains %s", ErrNoModInfo, subPath, describeMods(mods))
+}
+
+// describeMods lists mods by name with the directory each one is in
+func describeMods(mods map[string]*ArchiveMod) string {
+	names := make([]string, 0, len(mods))
+	for name := range mods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		if dir := mods[name].Dir; path.Base(dir) != name {
+			names[i] = fmt.Sprintf("%s (in %s)", name, dir)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// dirDepth returns how many folders deep a zip directory is
+func dirDepth(dir string) int {
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
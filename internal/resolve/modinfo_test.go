@@ -0,0 +1,186 @@
+package resolve
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadModInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		mods     map[string]*ModInfo
+		subPath  string
+		wantName string
+		wantErr  string
+	}{
+		{
+			name:     "mod zip",
+			mods:     map[string]*ModInfo{"test-mod_1.0.0": {Name: "test-mod", Version: "1.0.0"}},
+			wantName: "test-mod",
+		},
+		{
+			name:     "info.json at root",
+			mods:     map[string]*ModInfo{"": {Name: "test-mod", Version: "1.0.0"}},
+			wantName: "test-mod",
+		},
+		{
+			name: "shallowest info.json wins",
+			mods: map[string]*ModInfo{
+				"repo-abc":               {Name: "outer", Version: "1.0.0"},
+				"repo-abc/tests/fixture": {Name: "fixture", Version: "0.0.1"},
+			},
+			wantName: "outer",
+		},
+		{
+			name: "several mods",
+			mods: map[string]*ModInfo{
+				"repo-abc/mod-a": {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/mod-b": {Name: "mod-b", Version: "1.0.0"},
+			},
+			wantErr: "several mods (mod-a, mod-b)",
+		},
+		{
+			name: "subpath",
+			mods: map[string]*ModInfo{
+				"repo-abc/mod-a": {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/mod-b": {Name: "mod-b", Version: "1.0.0"},
+			},
+			subPath:  "mod-b",
+			wantName: "mod-b",
+		},
+		{
+			name:    "missing subpath",
+			mods:    map[string]*ModInfo{"repo-abc/mod-a": {Name: "mod-a", Version: "1.0.0"}},
+			subPath: "mod-c",
+			wantErr: "no info.json",
+		},
+		{
+			name: "subpath names a mod",
+			mods: map[string]*ModInfo{
+				"repo-abc/src/a": {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/src/b": {Name: "mod-b", Version: "1.0.0"},
+			},
+			subPath:  "mod-b",
+			wantName: "mod-b",
+		},
+		{
+			name: "missing subpath lists the mods found",
+			mods: map[string]*ModInfo{
+				"repo-abc/mod-a": {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/src/b": {Name: "mod-b", Version: "1.0.0"},
+			},
+			subPath: "mod-c",
+			wantErr: "the archive contains mod-a, mod-b (in repo-abc/src/b)",
+		},
+		{
+			name:    "missing version",
+			mods:    map[string]*ModInfo{"test-mod": {Name: "test-mod"}},
+			wantErr: "missing name or version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testZip(t, tt.mods)
+			info, err := ReadModInfo(strings.NewReader(data), int64(len(data)), tt.subPath)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ReadModInfo() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadModInfo() error = %v", err)
+			}
+			if info.Name != tt.wantName {
+				t.Errorf("ReadModInfo() got name = %v, want %v", info.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestListArchiveMods(t *testing.T) {
+	tests := []struct {
+		name    string
+		mods    map[string]*ModInfo
+		subPath string
+		want    map[string]string // mod name -> folder
+		wantErr string
+	}{
+		{
+			name: "repository",
+			mods: map[string]*ModInfo{
+				"repo-abc/mod-a":       {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/mod-b":       {Name: "mod-b", Version: "1.0.0"},
+				"repo-abc/mod-b/tests": {Name: "mod-b", Version: "0.0.1"},
+				"repo-abc/broken":      {Name: "broken"},
+			},
+			want: map[string]string{"mod-a": "repo-abc/mod-a", "mod-b": "repo-abc/mod-b"},
+		},
+		{
+			name: "subpath",
+			mods: map[string]*ModInfo{
+				"repo-abc/mod-a": {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/mod-b": {Name: "mod-b", Version: "1.0.0"},
+			},
+			subPath: "mod-b",
+			want:    map[string]string{"mod-b": "repo-abc/mod-b"},
+		},
+		{
+			name: "subpath names a mod",
+			mods: map[string]*ModInfo{
+				"repo-abc/src/a": {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/src/b": {Name: "mod-b", Version: "1.0.0"},
+			},
+			subPath: "mod-b",
+			want:    map[string]string{"mod-b": "repo-abc/src/b"},
+		},
+		{
+			name: "missing subpath",
+			mods: map[string]*ModInfo{
+				"repo-abc/mod-a": {Name: "mod-a", Version: "1.0.0"},
+				"repo-abc/mod-b": {Name: "mod-b", Version: "1.0.0"},
+			},
+			subPath: "mod-c",
+			wantErr: "the archive contains mod-a, mod-b",
+		},
+		{
+			name:    "empty archive",
+			mods:    map[string]*ModInfo{"repo-abc": {Name: "broken"}},
+			wantErr: "no info.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testZip(t, tt.mods)
+			mods, err := ListArchiveMods(strings.NewReader(data), int64(len(data)), tt.subPath)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ListArchiveMods() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ListArchiveMods() error = %v", err)
+			}
+			if len(mods) != len(tt.want) {
+				t.Fatalf("ListArchiveMods() returned %d mods, want %d", len(mods), len(tt.want))
+			}
+			for name, dir := range tt.want {
+				mod, ok := mods[name]
+				if !ok {
+					t.Errorf("ListArchiveMods() missing mod %s", name)
+					continue
+				}
+				if mod.Dir != dir {
+					t.Errorf("ListArchiveMods() got %s in %s, want %s", name, mod.Dir, dir)
+				}
+			}
+		})
+	}
+}
@@ -2,84 +2,172 @@ package resolve
 
 import (
 	"context"
+	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+
+	"github.com/WhyIsSandwich/factctl/internal/solver"
 )
 
 var (
 	// factorioModPortalAPI can be overridden for testing
 	factorioModPortalAPI = "https://mods.factorio.com/api/mods"
+
+	// ErrModNotFound is returned when the portal does not know a mod
+	ErrModNotFound = errors.New("mod not found on portal")
 )
 
+// PortalCredentials returns the username and token used for portal downloads
+type PortalCredentials func() (username, token string, err error)
+
 // PortalFetcher implements Fetcher for the Factorio mod portal
 type PortalFetcher struct {
-	client *http.Client
+	client      *http.Client
+	credentials PortalCredentials
+
+	// Releases chosen by ResolveRevision, keyed by "id@version", so that
+	// fetching the resolved source doesn't list the releases again
+	mu       sync.Mutex
+	resolved map[string]PortalRelease
 }
 
 // NewPortalFetcher creates a new PortalFetcher
 func NewPortalFetcher() *PortalFetcher {
 	return &PortalFetcher{
-		client: &http.Client{},
+		client:   newHTTPClient(),
+		resolved: make(map[string]PortalRelease),
 	}
 }
 
+// PortalRelease is a single release listed by the mod portal API
+type PortalRelease struct {
+	Version     string `json:"version"`
+	DownloadURL string `json:"download_url"`
+	SHA1        string `json:"sha1"`
+	InfoJSON    struct {
+		FactorioVersion string   `json:"factorio_version"`
+		Dependencies    []string `json:"dependencies"`
+	} `json:"info_json"`
+}
+
 type modPortalResponse struct {
-	Releases []struct {
-		Version     string `json:"version"`
-		DownloadURL string `json:"download_url"`
-		SHA1        string `json:"sha1"`
-	} `json:"releases"`
+	Releases  []PortalRelease `json:"releases"`
+	Changelog string          `json:"changelog"`
 }
 
-// Fetch downloads a mod from the Factorio mod portal
-func (f *PortalFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (string, error) {
+// WithCredentials sets how portal credentials are looked up. The portal only
+// serves downloads to authenticated users; the lookup happens on first download.
+func (f *PortalFetcher) WithCredentials(credentials PortalCredentials) *PortalFetcher {
+	f.credentials = credentials
+	return f
+}
+
+// ResolveRevision returns the version of the release the source selects
+func (f *PortalFetcher) ResolveRevision(ctx context.Context, src *Source) (string, error) {
 	if src.Type != SourcePortal {
 		return "", fmt.Errorf("invalid source type for portal fetcher: %v", src.Type)
 	}
 
-	// Query mod info from the portal API
-	apiURL := fmt.Sprintf("%s/%s", factorioModPortalAPI, url.PathEscape(src.ID))
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	releases, err := f.Releases(ctx, src.ID)
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := f.client.Do(req)
+	release, err := selectRelease(releases, src)
 	if err != nil {
 		return "", err
 	}
+
+	f.mu.Lock()
+	f.resolved[src.ID+"@"+release.Version] = *release
+	f.mu.Unlock()
+	return release.Version, nil
+}
+
+// Releases queries the portal API for every release of a mod, including the
+// dependencies each release declares
+func (f *PortalFetcher) Releases(ctx context.Context, id string) ([]PortalRelease, error) {
+	mod, err := f.mod(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mod.Releases, nil
+}
+
+// Changelog returns the contents of a mod's changelog.txt as published on the
+// portal, or "" if the mod has none
+func (f *PortalFetcher) Changelog(ctx context.Context, id string) (string, error) {
+	mod, err := f.mod(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return mod.Changelog, nil
+}
+
+// mod queries the portal API for the full details of a mod
+func (f *PortalFetcher) mod(ctx context.Context, id string) (*modPortalResponse, error) {
+	apiURL := fmt.Sprintf("%s/%s/full", factorioModPortalAPI, url.PathEscape(id))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrModNotFound, id)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("mod portal API returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("mod portal API returned status %d", resp.StatusCode)
 	}
 
-	var modInfo modPortalResponse
-	if err := json.NewDecoder(resp.Body).Decode(&modInfo); err != nil {
-		return "", err
+	var mod modPortalResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mod); err != nil {
+		return nil, err
+	}
+	return &mod, nil
+}
+
+// Fetch downloads a mod from the Factorio mod portal
+func (f *PortalFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (string, error) {
+	if src.Type != SourcePortal {
+		return "", fmt.Errorf("invalid source type for portal fetcher: %v", src.Type)
 	}
 
-	// TODO: Implement version constraint matching
-	if len(modInfo.Releases) == 0 {
-		return "", fmt.Errorf("no releases found for mod %s", src.ID)
+	latest, err := f.release(ctx, src)
+	if err != nil {
+		return "", err
 	}
-	latest := modInfo.Releases[len(modInfo.Releases)-1]
 
 	// Download the mod file
 	baseURL := strings.TrimSuffix(factorioModPortalAPI, "/api/mods")
 	downloadURL := baseURL + latest.DownloadURL
-	req, err = http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+	if f.credentials != nil {
+		username, token, err := f.credentials()
+		if err != nil {
+			return "", fmt.Errorf("portal credentials: %w", err)
+		}
+		downloadURL += fmt.Sprintf("?username=%s&token=%s", url.QueryEscape(username), url.QueryEscape(token))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
 	if err != nil {
 		return "", err
 	}
 
-	resp, err = f.client.Do(req)
+	resp, err := f.client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -89,13 +177,100 @@ func (f *PortalFetcher) Fetch(ctx context.Context, src *Source, w io.Writer) (st
 		return "", fmt.Errorf("mod download failed with status %d", resp.StatusCode)
 	}
 
-	// Calculate SHA256 while copying to writer
+	// Calculate SHA256 while copying to writer, and SHA1 to compare with the portal
 	h := sha256.New()
-	mw := io.MultiWriter(w, h)
+	s1 := sha1.New()
+	mw := io.MultiWriter(w, h, s1)
 
 	if _, err := io.Copy(mw, resp.Body); err != nil {
 		return "", err
 	}
 
+	// The bytes were already written, so callers must discard them on error
+	if latest.SHA1 != "" {
+		if got := hex.EncodeToString(s1.Sum(nil)); !strings.EqualFold(got, latest.SHA1) {
+			return "", fmt.Errorf("checksum mismatch for %s %s: portal lists sha1 %s, downloaded %s",
+				src.ID, latest.Version, latest.SHA1, got)
+		}
+	}
+
 	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// release returns the release a source selects, reusing the one ResolveRevision
+// picked for its revision instead of asking the API again
+func (f *PortalFetcher) release(ctx context.Context, src *Source) (*PortalRelease, error) {
+	if src.Revision != "" {
+		f.mu.Lock()
+		release, ok := f.resolved[src.ID+"@"+src.Revision]
+		f.mu.Unlock()
+		if ok {
+			return &release, nil
+		}
+	}
+
+	releases, err := f.Releases(ctx, src.ID)
+	if err != nil {
+		return nil, err
+	}
+	return selectRelease(releases, src)
+}
+
+// selectRelease picks the release pinned by src.Revision, or else the newest
+// release matching the source's version range and, if set, its Factorio version
+func selectRelease(releases []PortalRelease, src *Source) (*PortalRelease, error) {
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("no releases found for mod %s", src.ID)
+	}
+
+	if src.Revision != "" {
+		for i := range releases {
+			if releases[i].Version == src.Revision {
+				return &releases[i], nil
+			}
+		}
+		return nil, fmt.Errorf("release %s of mod %s not found on portal", src.Revision, src.ID)
+	}
+
+	versionRange, err := solver.ParseRange(src.Version)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSource, err)
+	}
+
+	var game solver.Version
+	if src.FactorioVersion != "" {
+		if game, err = solver.ParseVersion(src.FactorioVersion); err != nil {
+			return nil, fmt.Errorf("parsing Factorio version: %w", err)
+		}
+	}
+
+	var best *PortalRelease
+	var bestVersion solver.Version
+	for i := range releases {
+		release := &releases[i]
+
+		version, err := solver.ParseVersion(release.Version)
+		if err != nil || !versionRange.Allows(version) {
+			continue
+		}
+
+		if src.FactorioVersion != "" && release.InfoJSON.FactorioVersion != "" {
+			target, err := solver.ParseVersion(release.InfoJSON.FactorioVersion)
+			if err != nil || !target.SameRelease(game) {
+				continue
+			}
+		}
+
+		if best == nil || version.Compare(bestVersion) > 0 {
+			best, bestVersion = release, version
+		}
+	}
+
+	if best == nil {
+		if src.FactorioVersion != "" {
+			return nil, fmt.Errorf("no release of %s matches %s for Factorio %s", src.ID, versionRange, src.FactorioVersion)
+		}
+		return nil, fmt.Errorf("no release of %s matches %s", src.ID, versionRange)
+	}
+	return best, nil
 }
\ No newline at end of file
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -18,6 +19,7 @@ func TestPortalFetcher(t *testing.T) {
 		modData   string
 		wantErr   bool
 		wantBytes []byte
+		wantPath  string
 	}{
 		{
 			name: "successful fetch",
@@ -27,15 +29,11 @@ func TestPortalFetcher(t *testing.T) {
 				Version: "1.0.0",
 			},
 			apiResp: modPortalResponse{
-				Releases: []struct {
-					Version     string `json:"version"`
-					DownloadURL string `json:"download_url"`
-					SHA1        string `json:"sha1"`
-				}{
+				Releases: []PortalRelease{
 					{
 						Version:     "1.0.0",
 						DownloadURL: "/download/test-mod/1.0.0",
-						SHA1:        "test-sha1",
+						SHA1:        testModSHA1,
 					},
 				},
 			},
@@ -50,14 +48,109 @@ func TestPortalFetcher(t *testing.T) {
 				Version: "1.0.0",
 			},
 			apiResp: modPortalResponse{
-				Releases: []struct {
-					Version     string `json:"version"`
-					DownloadURL string `json:"download_url"`
-					SHA1        string `json:"sha1"`
-				}{},
+				Releases: []PortalRelease{},
 			},
 			wantErr: true,
 		},
+		{
+			name: "newest release in range",
+			src: &Source{
+				Type:    SourcePortal,
+				ID:      "test-mod",
+				Version: ">=1.2.0 <2.0.0",
+			},
+			apiResp: modPortalResponse{
+				Releases: []PortalRelease{
+					testRelease("1.1.0", "1.1"),
+					testRelease("1.3.0", "1.1"),
+					testRelease("1.2.5", "1.1"),
+					testRelease("2.0.0", "1.1"),
+				},
+			},
+			modData:   "test mod content",
+			wantBytes: []byte("test mod content"),
+			wantPath:  "/download/test-mod/1.3.0",
+		},
+		{
+			name: "latest compatible with Factorio version",
+			src: &Source{
+				Type:            SourcePortal,
+				ID:              "test-mod",
+				Version:         "latest",
+				FactorioVersion: "1.1",
+			},
+			apiResp: modPortalResponse{
+				Releases: []PortalRelease{
+					testRelease("1.0.0", "1.0"),
+					testRelease("1.1.0", "1.1"),
+					testRelease("2.0.0", "2.0"),
+				},
+			},
+			modData:   "test mod content",
+			wantBytes: []byte("test mod content"),
+			wantPath:  "/download/test-mod/1.1.0",
+		},
+		{
+			name: "exact pin",
+			src: &Source{
+				Type:    SourcePortal,
+				ID:      "test-mod",
+				Version: "1.0.0",
+			},
+			apiResp: modPortalResponse{
+				Releases: []PortalRelease{
+					testRelease("1.0.0", "1.1"),
+					testRelease("1.1.0", "1.1"),
+				},
+			},
+			modData:   "test mod content",
+			wantBytes: []byte("test mod content"),
+			wantPath:  "/download/test-mod/1.0.0",
+		},
+		{
+			name: "no release matches",
+			src: &Source{
+				Type:            SourcePortal,
+				ID:              "test-mod",
+				Version:         ">=1.0.0",
+				FactorioVersion: "2.0",
+			},
+			apiResp: modPortalResponse{
+				Releases: []PortalRelease{
+					testRelease("1.1.0", "1.1"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid range",
+			src: &Source{
+				Type:    SourcePortal,
+				ID:      "test-mod",
+				Version: ">=banana",
+			},
+			apiResp: modPortalResponse{
+				Releases: []PortalRelease{
+					testRelease("1.1.0", "1.1"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sha1 mismatch",
+			src: &Source{
+				Type:    SourcePortal,
+				ID:      "test-mod",
+				Version: "1.0.0",
+			},
+			apiResp: modPortalResponse{
+				Releases: []PortalRelease{
+					testRelease("1.0.0", "1.1"),
+				},
+			},
+			modData: "tampered content",
+			wantErr: true,
+		},
 		{
 			name: "wrong source type",
 			src: &Source{
@@ -71,11 +164,13 @@ func TestPortalFetcher(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create a test server that handles both API and download requests
+			var downloaded string
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				switch {
 				case strings.HasPrefix(r.URL.Path, "/api/mods/"):
 					json.NewEncoder(w).Encode(tt.apiResp)
 				case strings.HasPrefix(r.URL.Path, "/download/"):
+					downloaded = r.URL.Path
 					w.Write([]byte(tt.modData))
 				default:
 					t.Logf("Unexpected request to %s", r.URL.Path)
@@ -112,6 +207,97 @@ func TestPortalFetcher(t *testing.T) {
 			if !bytes.Equal([]byte(buf.String()), tt.wantBytes) {
 				t.Errorf("Fetch() got content = %v, want %v", buf.String(), string(tt.wantBytes))
 			}
+
+			if tt.wantPath != "" && downloaded != tt.wantPath {
+				t.Errorf("Fetch() downloaded %s, want %s", downloaded, tt.wantPath)
+			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// testModSHA1 is the SHA1 of "test mod content"
+const testModSHA1 = "5b7ce35864c899293238184cf2a1d1d2cec1e74c"
+
+// testRelease builds a portal release of test-mod whose content is "test mod content"
+func testRelease(version, factorioVersion string) PortalRelease {
+	release := PortalRelease{
+		Version:     version,
+		DownloadURL: "/download/test-mod/" + version,
+		SHA1:        testModSHA1,
+	}
+	release.InfoJSON.FactorioVersion = factorioVersion
+	return release
+}
+func TestPortalFetcherRevision(t *testing.T) {
+	var query string
+	var listed int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/mods/test-mod/full":
+			listed++
+			json.NewEncoder(w).Encode(modPortalResponse{
+				Releases: []PortalRelease{
+					testRelease("1.0.0", "1.1"),
+					testRelease("1.1.0", "1.1"),
+				},
+				Changelog: "Version: 1.1.0\n  Bugfixes:\n    - Fixed a crash\n",
+			})
+		case strings.HasPrefix(r.URL.Path, "/download/"):
+			query = r.URL.RawQuery
+			w.Write([]byte("test mod content"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	originalAPI := factorioModPortalAPI
+	factorioModPortalAPI = server.URL + "/api/mods"
+	defer func() { factorioModPortalAPI = originalAPI }()
+
+	f := NewPortalFetcher().WithCredentials(func() (string, string, error) {
+		return "user", "secret token", nil
+	})
+
+	src := &Source{Type: SourcePortal, ID: "test-mod", Version: "latest"}
+	revision, err := f.ResolveRevision(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ResolveRevision() error = %v", err)
+	}
+	if revision != "1.1.0" {
+		t.Errorf("ResolveRevision() = %q, want 1.1.0", revision)
+	}
+
+	// Fetching the resolved source reuses the release ResolveRevision picked
+	src.Revision = revision
+	var resolved strings.Builder
+	if _, err := f.Fetch(context.Background(), src, &resolved); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if listed != 1 {
+		t.Errorf("releases were listed %d times, want once", listed)
+	}
+
+	// A pinned revision wins over the range
+	src.Revision = "1.0.0"
+	var buf strings.Builder
+	if _, err := f.Fetch(context.Background(), src, &buf); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if query != "username=user&token=secret+token" {
+		t.Errorf("Fetch() sent query %q, want credentials", query)
+	}
+
+	src.Revision = "9.9.9"
+	if _, err := f.Fetch(context.Background(), src, &buf); err == nil {
+		t.Error("Fetch() expected error for unknown revision but got nil")
+	}
+
+	if changelog, err := f.Changelog(context.Background(), "test-mod"); err != nil || !strings.Contains(changelog, "Fixed a crash") {
+		t.Errorf("Changelog() = %q, %v", changelog, err)
+	}
+
+	if _, err := f.Releases(context.Background(), "missing-mod"); !errors.Is(err, ErrModNotFound) {
+		t.Errorf("Releases() error = %v, want ErrModNotFound", err)
+	}
+}
@@ -2,25 +2,46 @@ package resolve
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+)
+
+var (
+	// ErrIntegrity is returned when fetched content doesn't match its sha256 pin
+	ErrIntegrity = errors.New("content does not match its sha256 pin")
 )
 
 // Fetcher defines the interface for fetching mod content from a source
 type Fetcher interface {
 	// Fetch retrieves mod content from the source and writes it to w.
 	// It should return the SHA256 hash of the content.
+	// If src.Revision is set, that exact revision must be fetched.
 	Fetch(ctx context.Context, src *Source, w io.Writer) (string, error)
 }
 
+// RevisionResolver is implemented by fetchers whose sources can move over time,
+// such as branches, pull requests and portal version ranges
+type RevisionResolver interface {
+	// ResolveRevision returns the immutable revision the source currently points to
+	ResolveRevision(ctx context.Context, src *Source) (string, error)
+}
+
 // ModInfo contains metadata about a mod
 type ModInfo struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Title   string `json:"title"`
-	Author  string `json:"author"`
-	Hash    string `json:"hash"`    // SHA256 hash of the mod file
-	Source  string `json:"source"`  // Original source specification
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	Title           string   `json:"title"`
+	Author          string   `json:"author"`
+	Contact         string   `json:"contact,omitempty"`
+	Homepage        string   `json:"homepage,omitempty"`
+	Description     string   `json:"description"`
+	Dependencies    []string `json:"dependencies"`
+	FactorioVersion string   `json:"factorio_version,omitempty"`
+	Hash            string   `json:"hash"`               // SHA256 hash of the mod file
+	Source          string   `json:"source"`             // Original source specification
+	Revision        string   `json:"revision,omitempty"` // Revision the source resolved to
 }
 
 // Resolver handles mod resolution from various sources
@@ -28,18 +49,86 @@ type Resolver struct {
 	fetchers map[SourceType]Fetcher
 }
 
-// NewResolver creates a new Resolver with the default fetchers
+// NewResolver creates a new Resolver with no fetchers registered
 func NewResolver() *Resolver {
 	return &Resolver{
 		fetchers: make(map[SourceType]Fetcher),
 	}
 }
 
+// NewDefaultResolver creates a new Resolver with a fetcher registered for every source type
+func NewDefaultResolver() *Resolver {
+	r := NewResolver()
+	r.RegisterFetcher(SourcePortal, NewPortalFetcher())
+	r.RegisterFetcher(SourceGitHub, NewGitHubFetcher())
+	r.RegisterFetcher(SourceGitHubPR, NewGitHubPRFetcher())
+	r.RegisterFetcher(SourceGitHubRelease, NewGitHubReleaseFetcher())
+	r.RegisterFetcher(SourceGit, NewGitFetcher())
+	r.RegisterFetcher(SourceFile, NewFileFetcher())
+	r.RegisterFetcher(SourceURL, NewURLFetcher())
+	return r
+}
+
 // RegisterFetcher registers a fetcher for a specific source type
 func (r *Resolver) RegisterFetcher(typ SourceType, f Fetcher) {
 	r.fetchers[typ] = f
 }
 
+// fetcher returns the fetcher registered for a source
+func (r *Resolver) fetcher(src *Source) (Fetcher, error) {
+	fetcher, ok := r.fetchers[src.Type]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher registered for source type %v", src.Type)
+	}
+	return fetcher, nil
+}
+
+// ResolveRevision pins a source to the revision it currently points to and
+// stores it in src.Revision. Sources that are already pinned, or whose fetcher
+// cannot resolve revisions, are returned unchanged.
+func (r *Resolver) ResolveRevision(ctx context.Context, src *Source) (string, error) {
+	if src.Revision != "" {
+		return src.Revision, nil
+	}
+
+	fetcher, err := r.fetcher(src)
+	if err != nil {
+		return "", err
+	}
+
+	resolver, ok := fetcher.(RevisionResolver)
+	if !ok {
+		return "", nil
+	}
+
+	revision, err := resolver.ResolveRevision(ctx, src)
+	if err != nil {
+		return "", fmt.Errorf("resolving revision: %w", err)
+	}
+	src.Revision = revision
+	return revision, nil
+}
+
+// Fetch downloads a source as-is, such as a whole repository archive, and
+// returns the SHA256 of the content. If the source pins a SHA256 and the
+// content doesn't match, ErrIntegrity is returned after the content has been
+// written, so callers must discard what they received.
+func (r *Resolver) Fetch(ctx context.Context, src *Source, w io.Writer) (string, error) {
+	fetcher, err := r.fetcher(src)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := fetcher.Fetch(ctx, src, w)
+	if err != nil {
+		return "", err
+	}
+	if src.SHA256 != "" && hash != src.SHA256 {
+		return "", fmt.Errorf("%w: got sha256 %s, want %s", ErrIntegrity, hash, src.SHA256)
+	}
+	return hash, nil
+}
+
 // Resolve fetches a mod from its source and returns its info and content
 func (r *Resolver) Resolve(ctx context.Context, spec string, w io.Writer) (*ModInfo, error) {
 	src, err := ParseSource(spec)
@@ -47,20 +136,45 @@ func (r *Resolver) Resolve(ctx context.Context, spec string, w io.Writer) (*ModI
 		return nil, err
 	}
 
-	fetcher, ok := r.fetchers[src.Type]
-	if !ok {
-		return nil, fmt.Errorf("no fetcher registered for source type %v", src.Type)
+	info, err := r.ResolveSource(ctx, src, w)
+	if err != nil {
+		return nil, err
 	}
+	info.Source = spec
+	return info, nil
+}
 
-	hash, err := fetcher.Fetch(ctx, src, w)
+// ResolveSource is Resolve for an already parsed source. The content is written
+// to w unchanged; the mod's info.json is read from it once the download completes.
+func (r *Resolver) ResolveSource(ctx context.Context, src *Source, w io.Writer) (*ModInfo, error) {
+	if _, err := r.ResolveRevision(ctx, src); err != nil {
+		return nil, err
+	}
+
+	// Keep a copy on disk so info.json can be read from the zip
+	tmp, err := os.CreateTemp("", "factctl-resolve-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash, err := r.Fetch(ctx, src, io.MultiWriter(w, tmp))
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: Extract mod info from info.json within the zip
-	// For now, return basic info
-	return &ModInfo{
-		Hash:   hash,
-		Source: spec,
-	}, nil
-}
\ No newline at end of file
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("reading temp file: %w", err)
+	}
+
+	info, err := ReadModInfo(tmp, size, src.SubPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading mod info: %w", err)
+	}
+
+	info.Hash = hash
+	info.Revision = src.Revision
+	return info, nil
+}
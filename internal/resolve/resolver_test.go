@@ -1,8 +1,10 @@
 package resolve
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -11,20 +13,69 @@ import (
 
 // mockFetcher implements Fetcher for testing
 type mockFetcher struct {
-	content string
-	hash    string
-	err     error
+	content  string
+	hash     string
+	revision string
+	err      error
+
+	// Revision requested by the last Fetch call
+	fetched string
 }
 
-func (m *mockFetcher) Fetch(_ context.Context, _ *Source, w io.Writer) (string, error) {
+func (m *mockFetcher) Fetch(_ context.Context, src *Source, w io.Writer) (string, error) {
 	if m.err != nil {
 		return "", m.err
 	}
+	m.fetched = src.Revision
 	io.WriteString(w, m.content)
 	return m.hash, nil
 }
 
+// revisionFetcher is a mockFetcher that also resolves revisions
+type revisionFetcher struct {
+	*mockFetcher
+}
+
+func (m revisionFetcher) ResolveRevision(_ context.Context, _ *Source) (string, error) {
+	return m.revision, nil
+}
+
+// testZip builds a zip archive containing the given info.json files, keyed by directory
+func testZip(t *testing.T, mods map[string]*ModInfo) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for dir, info := range mods {
+		name := "info.json"
+		if dir != "" {
+			name = dir + "/info.json"
+		}
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		if err := json.NewEncoder(f).Encode(info); err != nil {
+			t.Fatalf("Failed to write info.json: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return buf.String()
+}
+
 func TestResolver(t *testing.T) {
+	modZip := testZip(t, map[string]*ModInfo{
+		"test-mod_1.0.0": {
+			Name:            "test-mod",
+			Version:         "1.0.0",
+			Title:           "Test Mod",
+			FactorioVersion: "1.1",
+			Dependencies:    []string{"base >= 1.1"},
+		},
+	})
+
 	tests := []struct {
 		name      string
 		spec      string
@@ -37,11 +88,39 @@ func TestResolver(t *testing.T) {
 			name: "successful fetch",
 			spec: "portal:test-mod@1.0.0",
 			fetcher: &mockFetcher{
-				content: "test content",
+				content: modZip,
 				hash:    "testhash123",
 			},
 			wantHash:  "testhash123",
-			wantBytes: []byte("test content"),
+			wantBytes: []byte(modZip),
+		},
+		{
+			name: "not a mod archive",
+			spec: "portal:test-mod@1.0.0",
+			fetcher: &mockFetcher{
+				content: "test content",
+				hash:    "testhash123",
+			},
+			wantErr: true,
+		},
+		{
+			name: "matching sha256 pin",
+			spec: "portal:test-mod@1.0.0#sha256=" + strings.Repeat("ab", 32),
+			fetcher: &mockFetcher{
+				content: modZip,
+				hash:    strings.Repeat("ab", 32),
+			},
+			wantHash:  strings.Repeat("ab", 32),
+			wantBytes: []byte(modZip),
+		},
+		{
+			name: "mismatching sha256 pin",
+			spec: "portal:test-mod@1.0.0#sha256=" + strings.Repeat("ab", 32),
+			fetcher: &mockFetcher{
+				content: modZip,
+				hash:    strings.Repeat("cd", 32),
+			},
+			wantErr: true,
 		},
 		{
 			name: "fetcher error",
@@ -81,6 +160,14 @@ func TestResolver(t *testing.T) {
 				return
 			}
 
+			if info.Name != "test-mod" || info.Version != "1.0.0" || info.FactorioVersion != "1.1" || len(info.Dependencies) != 1 {
+				t.Errorf("Resolve() got info = %+v", info)
+			}
+
+			if info.Source != tt.spec {
+				t.Errorf("Resolve() got source = %v, want %v", info.Source, tt.spec)
+			}
+
 			if info.Hash != tt.wantHash {
 				t.Errorf("Resolve() got hash = %v, want %v", info.Hash, tt.wantHash)
 			}
@@ -90,4 +177,39 @@ func TestResolver(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestResolverRevision(t *testing.T) {
+	modZip := testZip(t, map[string]*ModInfo{
+		"repo-abc/mod-a": {Name: "mod-a", Version: "1.0.0"},
+		"repo-abc/mod-b": {Name: "mod-b", Version: "2.0.0"},
+	})
+
+	fetcher := revisionFetcher{&mockFetcher{content: modZip, hash: "hash", revision: "0123456789abcdef0123456789abcdef01234567"}}
+	r := NewResolver()
+	r.RegisterFetcher(SourceGitHub, fetcher)
+
+	// Several mods in the archive need a subdirectory
+	if _, err := r.Resolve(context.Background(), "gh:owner/repo@main", io.Discard); err == nil {
+		t.Error("Resolve() expected error for multi-mod archive but got nil")
+	}
+
+	info, err := r.Resolve(context.Background(), "gh:owner/repo/mod-b@main", io.Discard)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if info.Name != "mod-b" || info.Version != "2.0.0" {
+		t.Errorf("Resolve() got info = %+v, want mod-b 2.0.0", info)
+	}
+	if info.Revision != fetcher.revision || fetcher.fetched != fetcher.revision {
+		t.Errorf("Resolve() revision = %q, fetched %q, want %q", info.Revision, fetcher.fetched, fetcher.revision)
+	}
+
+	// A pinned source is fetched as-is
+	src := &Source{Type: SourceGitHub, Owner: "owner", Repo: "repo", SubPath: "mod-a", Revision: "pinned"}
+	if _, err := r.ResolveSource(context.Background(), src, io.Discard); err != nil {
+		t.Fatalf("ResolveSource() error = %v", err)
+	}
+	if fetcher.fetched != "pinned" {
+		t.Errorf("ResolveSource() fetched %q, want pinned revision", fetcher.fetched)
+	}
+}
@@ -1,6 +1,8 @@
 package resolve
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/url"
@@ -18,6 +20,19 @@ type Source struct {
 	Path    string   // For file sources
 	URL     string   // For URL sources
 	SubPath string   // Directory within repo containing the mod (for multi-mod repos)
+	Asset   string   // Asset name pattern for GitHub release sources
+
+	// Revision pins the source to an immutable revision (a commit SHA, or a release
+	// version for the portal). When set, fetchers download it instead of Version.
+	Revision string
+
+	// SHA256 is the expected hash of the fetched content, from a #sha256= suffix.
+	// Content that doesn't match is rejected.
+	SHA256 string
+
+	// FactorioVersion restricts portal releases to those targeting this game version.
+	// It is not part of the spec; callers set it from the instance config.
+	FactorioVersion string
 }
 
 // SourceType identifies the type of mod source
@@ -26,9 +41,10 @@ type SourceType int
 const (
 	SourceUnknown SourceType = iota
 	SourcePortal            // portal:<id>@<version|range>
-	SourceGitHub           // gh:<owner>/<repo>@<ref>
-	SourceGitHubPR        // ghpr:<owner>/<repo>#<pr>
-	SourceGit             // git:<host>/<repo>@<ref>
+	SourceGitHub           // gh:<owner>/<repo>[//<subdir>][@<ref>]
+	SourceGitHubPR        // ghpr:<owner>/<repo>[//<subdir>]#<pr>
+	SourceGitHubRelease   // ghrel:<owner>/<repo>[@<tag>|latest][/<asset-glob>]
+	SourceGit             // git:<host>/<repo>[//<subdir>][@<ref>]
 	SourceFile            // file:...
 	SourceURL             // url:...
 )
@@ -37,8 +53,41 @@ var (
 	ErrInvalidSource = errors.New("invalid source specification")
 )
 
-// ParseSource parses a mod source specification string into a Source struct
+// integritySuffix starts the hash that any source spec may end with
+const integritySuffix = "#sha256="
+
+// ParseSource parses a mod source specification string into a Source struct.
+// Any spec may end with #sha256=<hex> to pin the content it fetches.
 func ParseSource(spec string) (*Source, error) {
+	spec, hash, err := cutIntegrity(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := parseSourceType(spec)
+	if err != nil {
+		return nil, err
+	}
+	src.SHA256 = hash
+	return src, nil
+}
+
+// cutIntegrity splits the #sha256= suffix off a spec
+func cutIntegrity(spec string) (string, string, error) {
+	i := strings.LastIndex(spec, integritySuffix)
+	if i < 0 {
+		return spec, "", nil
+	}
+
+	hash := strings.ToLower(spec[i+len(integritySuffix):])
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+		return "", "", fmt.Errorf("%w: sha256 must be 64 hex digits", ErrInvalidSource)
+	}
+	return spec[:i], hash, nil
+}
+
+// parseSourceType parses a spec without its integrity suffix
+func parseSourceType(spec string) (*Source, error) {
 	parts := strings.SplitN(spec, ":", 2)
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("%w: missing source type", ErrInvalidSource)
@@ -50,10 +99,12 @@ func ParseSource(spec string) (*Source, error) {
 	switch srcType {
 	case "portal":
 		return parsePortalSource(srcSpec)
-	case "gh":
+	case "gh", "github":
 		return parseGitHubSource(srcSpec)
 	case "ghpr":
 		return parseGitHubPRSource(srcSpec)
+	case "ghrel":
+		return parseGitHubReleaseSource(srcSpec)
 	case "git":
 		return parseGitSource(srcSpec)
 	case "file":
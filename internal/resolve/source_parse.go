@@ -6,50 +6,63 @@ import (
 	"strings"
 )
 
-// parsePortalSource parses a portal:<id>@<version|range> specification
+// parsePortalSource parses a portal:<id>[@<version|range>] specification.
+// Without a version the newest compatible release is used.
 func parsePortalSource(spec string) (*Source, error) {
 	parts := strings.SplitN(spec, "@", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("%w: missing version in portal spec", ErrInvalidSource)
+	if parts[0] == "" {
+		return nil, fmt.Errorf("%w: missing mod name in portal spec", ErrInvalidSource)
+	}
+
+	version := "latest"
+	if len(parts) == 2 {
+		version = parts[1]
 	}
 
 	return &Source{
 		Type:    SourcePortal,
 		ID:      parts[0],
-		Version: parts[1],
+		Version: version,
 	}, nil
 }
 
-// parseGitHubSource parses a gh:<owner>/<repo>@<ref> specification
+// parseGitHubSource parses a gh:<owner>/<repo>[//<subdir>][@<ref>] specification.
+// Without a ref the repository's default branch is used. A single slash before
+// the subdirectory also works.
 func parseGitHubSource(spec string) (*Source, error) {
 	repoRef := strings.SplitN(spec, "@", 2)
-	if len(repoRef) != 2 {
-		return nil, fmt.Errorf("%w: missing ref in GitHub spec", ErrInvalidSource)
+	ref := ""
+	if len(repoRef) == 2 {
+		ref = repoRef[1]
+		if ref == "" {
+			return nil, fmt.Errorf("%w: empty ref in GitHub spec", ErrInvalidSource)
+		}
 	}
 
-	ownerRepo := strings.SplitN(repoRef[0], "/", 2)
-	if len(ownerRepo) != 2 {
-		return nil, fmt.Errorf("%w: invalid GitHub repo format", ErrInvalidSource)
+	owner, repo, subPath, err := parseGitHubRepo(repoRef[0])
+	if err != nil {
+		return nil, err
 	}
 
 	return &Source{
 		Type:    SourceGitHub,
-		Owner:   ownerRepo[0],
-		Repo:    ownerRepo[1],
-		Version: repoRef[1],
+		Owner:   owner,
+		Repo:    repo,
+		Version: ref,
+		SubPath: subPath,
 	}, nil
 }
 
-// parseGitHubPRSource parses a ghpr:<owner>/<repo>#<pr> specification
+// parseGitHubPRSource parses a ghpr:<owner>/<repo>[//<subdir>]#<pr> specification
 func parseGitHubPRSource(spec string) (*Source, error) {
 	repoPR := strings.SplitN(spec, "#", 2)
 	if len(repoPR) != 2 {
 		return nil, fmt.Errorf("%w: missing PR number in GitHub PR spec", ErrInvalidSource)
 	}
 
-	ownerRepo := strings.SplitN(repoPR[0], "/", 2)
-	if len(ownerRepo) != 2 {
-		return nil, fmt.Errorf("%w: invalid GitHub repo format", ErrInvalidSource)
+	owner, repo, subPath, err := parseGitHubRepo(repoPR[0])
+	if err != nil {
+		return nil, err
 	}
 
 	pr, err := strconv.Atoi(repoPR[1])
@@ -58,23 +71,103 @@ func parseGitHubPRSource(spec string) (*Source, error) {
 	}
 
 	return &Source{
-		Type:  SourceGitHubPR,
-		Owner: ownerRepo[0],
-		Repo:  ownerRepo[1],
-		PR:    pr,
+		Type:    SourceGitHubPR,
+		Owner:   owner,
+		Repo:    repo,
+		PR:      pr,
+		SubPath: subPath,
+	}, nil
+}
+
+// parseGitHubReleaseSource parses a ghrel:<owner>/<repo>[@<tag>|latest][/<asset-glob>]
+// specification. Without a tag the latest release is used; the asset glob
+// follows the first slash after the tag.
+func parseGitHubReleaseSource(spec string) (*Source, error) {
+	repo, tagAsset, hasTag := strings.Cut(spec, "@")
+	tag, asset := "latest", ""
+	if hasTag {
+		tag, asset, _ = strings.Cut(tagAsset, "/")
+		if tag == "" {
+			return nil, fmt.Errorf("%w: empty tag in GitHub release spec", ErrInvalidSource)
+		}
+	}
+
+	ownerRepo := strings.Split(repo, "/")
+	if len(ownerRepo) != 2 || ownerRepo[0] == "" || ownerRepo[1] == "" {
+		return nil, fmt.Errorf("%w: invalid GitHub repo format", ErrInvalidSource)
+	}
+
+	return &Source{
+		Type:    SourceGitHubRelease,
+		Owner:   ownerRepo[0],
+		Repo:    ownerRepo[1],
+		Version: tag,
+		Asset:   asset,
 	}, nil
 }
 
-// parseGitSource parses a git:<host>/<repo>@<ref> specification
+// parseGitHubRepo splits <owner>/<repo>[//<subdir>] into its parts
+func parseGitHubRepo(spec string) (owner, repo, subPath string, err error) {
+	spec, subPath = cutSubPath(spec)
+
+	ownerRepo := strings.SplitN(spec, "/", 3)
+	if len(ownerRepo) < 2 || ownerRepo[0] == "" || ownerRepo[1] == "" {
+		return "", "", "", fmt.Errorf("%w: invalid GitHub repo format", ErrInvalidSource)
+	}
+	if len(ownerRepo) == 3 {
+		if subPath != "" {
+			return "", "", "", fmt.Errorf("%w: subdirectory given twice in GitHub spec", ErrInvalidSource)
+		}
+		subPath = strings.Trim(ownerRepo[2], "/")
+	}
+	return ownerRepo[0], ownerRepo[1], subPath, nil
+}
+
+// cutSubPath splits a repository from the subdirectory following its "//".
+// The "//" of a URL scheme such as https:// doesn't count.
+func cutSubPath(spec string) (string, string) {
+	for i := 0; i+1 < len(spec); i++ {
+		if spec[i] != '/' || spec[i+1] != '/' || (i > 0 && spec[i-1] == ':') {
+			continue
+		}
+		return spec[:i], strings.Trim(spec[i+2:], "/")
+	}
+	return spec, ""
+}
+
+// parseGitSource parses a git:<remote>[//<subdir>][@<ref>] specification, where
+// the remote is a URL, user@host:path, a local path or host/path for HTTPS.
+// Without a ref the repository's default branch is used.
 func parseGitSource(spec string) (*Source, error) {
-	repoRef := strings.SplitN(spec, "@", 2)
-	if len(repoRef) != 2 {
-		return nil, fmt.Errorf("%w: missing ref in Git spec", ErrInvalidSource)
+	// The user in ssh://user@host/path and user@host:path is not a ref
+	pathStart := 0
+	if i := strings.Index(spec, "://"); i >= 0 {
+		pathStart = i + 3
+		if j := strings.Index(spec[pathStart:], "/"); j >= 0 {
+			pathStart += j
+		}
+	} else if i := strings.Index(spec, ":"); i >= 0 && strings.Contains(spec[:i], "@") && !strings.Contains(spec[:i], "/") {
+		pathStart = i
+	}
+
+	repo, ref := spec, ""
+	if i := strings.Index(spec[pathStart:], "@"); i >= 0 {
+		repo, ref = spec[:pathStart+i], spec[pathStart+i+1:]
+	}
+
+	repo, subPath := cutSubPath(repo)
+	if repo == "" {
+		return nil, fmt.Errorf("%w: missing repository in Git spec", ErrInvalidSource)
+	}
+	// git would read these as options
+	if strings.HasPrefix(repo, "-") || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("%w: Git repository and ref cannot start with '-'", ErrInvalidSource)
 	}
 
 	return &Source{
 		Type:    SourceGit,
-		ID:      repoRef[0],
-		Version: repoRef[1],
+		ID:      repo,
+		Version: ref,
+		SubPath: subPath,
 	}, nil
-}
\ No newline at end of file
+}
@@ -1,6 +1,9 @@
 package resolve
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestParseSource(t *testing.T) {
 	tests := []struct {
@@ -18,6 +21,20 @@ func TestParseSource(t *testing.T) {
 				Version: "^0.12",
 			},
 		},
+		{
+			name: "portal source without version",
+			spec: "portal:flib",
+			want: &Source{
+				Type:    SourcePortal,
+				ID:      "flib",
+				Version: "latest",
+			},
+		},
+		{
+			name:    "portal source without name",
+			spec:    "portal:@1.0.0",
+			wantErr: true,
+		},
 		{
 			name: "github source",
 			spec: "gh:Earendel/SpaceExploration@v0.6.151",
@@ -28,6 +45,57 @@ func TestParseSource(t *testing.T) {
 				Version: "v0.6.151",
 			},
 		},
+		{
+			name: "github alias without ref",
+			spec: "github:Arch666Angel/mods",
+			want: &Source{
+				Type:  SourceGitHub,
+				Owner: "Arch666Angel",
+				Repo:  "mods",
+			},
+		},
+		{
+			name: "github source with subfolder",
+			spec: "github:Arch666Angel/mods/angelsrefining@dev2.0",
+			want: &Source{
+				Type:    SourceGitHub,
+				Owner:   "Arch666Angel",
+				Repo:    "mods",
+				Version: "dev2.0",
+				SubPath: "angelsrefining",
+			},
+		},
+		{
+			name: "github source with subdirectory",
+			spec: "gh:Arch666Angel/mods//angelsrefining@dev2.0",
+			want: &Source{
+				Type:    SourceGitHub,
+				Owner:   "Arch666Angel",
+				Repo:    "mods",
+				Version: "dev2.0",
+				SubPath: "angelsrefining",
+			},
+		},
+		{
+			name: "github source with nested subdirectory",
+			spec: "gh:owner/monorepo//mods/core/",
+			want: &Source{
+				Type:    SourceGitHub,
+				Owner:   "owner",
+				Repo:    "monorepo",
+				SubPath: "mods/core",
+			},
+		},
+		{
+			name:    "github source with two subdirectories",
+			spec:    "gh:owner/repo/a//b",
+			wantErr: true,
+		},
+		{
+			name:    "github source without repo",
+			spec:    "gh:Earendel@main",
+			wantErr: true,
+		},
 		{
 			name: "github PR source",
 			spec: "ghpr:org/SpaceExploration#123",
@@ -38,6 +106,110 @@ func TestParseSource(t *testing.T) {
 				PR:    123,
 			},
 		},
+		{
+			name: "github PR source with subdirectory",
+			spec: "ghpr:modded-factorio/SeaBlock//SeaBlock#343",
+			want: &Source{
+				Type:    SourceGitHubPR,
+				Owner:   "modded-factorio",
+				Repo:    "SeaBlock",
+				PR:      343,
+				SubPath: "SeaBlock",
+			},
+		},
+		{
+			name: "github release source",
+			spec: "ghrel:Earendel/SpaceExploration",
+			want: &Source{
+				Type:    SourceGitHubRelease,
+				Owner:   "Earendel",
+				Repo:    "SpaceExploration",
+				Version: "latest",
+			},
+		},
+		{
+			name: "github release source with tag and asset glob",
+			spec: "ghrel:user/my-mod@v1.2.0/my-mod_*.zip",
+			want: &Source{
+				Type:    SourceGitHubRelease,
+				Owner:   "user",
+				Repo:    "my-mod",
+				Version: "v1.2.0",
+				Asset:   "my-mod_*.zip",
+			},
+		},
+		{
+			name:    "github release source with empty tag",
+			spec:    "ghrel:user/my-mod@",
+			wantErr: true,
+		},
+		{
+			name:    "github release source with subdirectory",
+			spec:    "ghrel:user/my-mod/mods",
+			wantErr: true,
+		},
+		{
+			name: "url source with sha256 pin",
+			spec: "url:https://example.com/mod.zip#sha256=" + strings.Repeat("AB", 32),
+			want: &Source{
+				Type:   SourceURL,
+				URL:    "https://example.com/mod.zip",
+				SHA256: strings.Repeat("ab", 32),
+			},
+		},
+		{
+			name: "github PR source with sha256 pin",
+			spec: "ghpr:org/SpaceExploration#123#sha256=" + strings.Repeat("0f", 32),
+			want: &Source{
+				Type:   SourceGitHubPR,
+				Owner:  "org",
+				Repo:   "SpaceExploration",
+				PR:     123,
+				SHA256: strings.Repeat("0f", 32),
+			},
+		},
+		{
+			name:    "sha256 pin that is too short",
+			spec:    "gh:owner/repo@v1.2#sha256=abc123",
+			wantErr: true,
+		},
+		{
+			name: "git URL with subdirectory",
+			spec: "git:https://example.com/mods.git//my-mod@v1.0",
+			want: &Source{
+				Type:    SourceGit,
+				ID:      "https://example.com/mods.git",
+				Version: "v1.0",
+				SubPath: "my-mod",
+			},
+		},
+		{
+			name: "git over ssh with a user",
+			spec: "git:ssh://git@git.example.com/user/repo.git@feature/x",
+			want: &Source{
+				Type:    SourceGit,
+				ID:      "ssh://git@git.example.com/user/repo.git",
+				Version: "feature/x",
+			},
+		},
+		{
+			name: "git scp-style remote",
+			spec: "git:git@codeberg.org:user/mods.git//core@v2",
+			want: &Source{
+				Type:    SourceGit,
+				ID:      "git@codeberg.org:user/mods.git",
+				Version: "v2",
+				SubPath: "core",
+			},
+		},
+		{
+			name: "git local path",
+			spec: "git:/srv/mirrors/mod.git",
+			want: &Source{
+				Type: SourceGit,
+				ID:   "/srv/mirrors/mod.git",
+			},
+		},
 		{
 			name: "git source",
 			spec: "git:gitlab.com/user/repo@main",
@@ -47,6 +219,16 @@ func TestParseSource(t *testing.T) {
 				Version: "main",
 			},
 		},
+		{
+			name:    "git ref looking like an option",
+			spec:    "git:/tmp/repo@--upload-pack=touch /tmp/pwned",
+			wantErr: true,
+		},
+		{
+			name:    "git remote looking like an option",
+			spec:    "git:-oProxyCommand=touch /tmp/pwned@host:repo",
+			wantErr: true,
+		},
 		{
 			name: "file source",
 			spec: "file:/path/to/mod.zip",
@@ -112,6 +294,15 @@ func TestParseSource(t *testing.T) {
 			if got.URL != tt.want.URL {
 				t.Errorf("ParseSource() got URL = %v, want %v", got.URL, tt.want.URL)
 			}
+			if got.SubPath != tt.want.SubPath {
+				t.Errorf("ParseSource() got SubPath = %v, want %v", got.SubPath, tt.want.SubPath)
+			}
+			if got.Asset != tt.want.Asset {
+				t.Errorf("ParseSource() got Asset = %v, want %v", got.Asset, tt.want.Asset)
+			}
+			if got.SHA256 != tt.want.SHA256 {
+				t.Errorf("ParseSource() got SHA256 = %v, want %v", got.SHA256, tt.want.SHA256)
+			}
 		})
 	}
 }
\ No newline at end of file
@@ -0,0 +1,112 @@
+package resolve
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// DefaultHostLimit is how many requests may be in flight to each limited host
+// at once, however many downloads run in parallel
+const DefaultHostLimit = 4
+
+// limitedHosts are capped so that parallel installs stay polite to the mod
+// portal and GitHub
+var limitedHosts = []string{
+	"mods.factorio.com",
+	"api.github.com",
+	"github.com",
+	"codeload.github.com",
+	"objects.githubusercontent.com",
+}
+
+// sharedTransport is used by every fetcher so the limits apply process-wide
+var sharedTransport = newLimitedTransport(http.DefaultTransport, hostLimitMap(DefaultHostLimit))
+
+// hostLimitMap gives every limited host the same limit
+func hostLimitMap(n int) map[string]int {
+	limits := make(map[string]int, len(limitedHosts))
+	for _, host := range limitedHosts {
+		limits[host] = n
+	}
+	return limits
+}
+
+// newHTTPClient returns an HTTP client that respects the per-host limits
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: sharedTransport}
+}
+
+// limitedTransport is an http.RoundTripper that bounds concurrent requests per host.
+// A request holds its host's slot until its response body is closed.
+type limitedTransport struct {
+	base   http.RoundTripper
+	limits map[string]int
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// newLimitedTransport wraps base with the given per-host limits.
+// Hosts without a limit are not restricted.
+func newLimitedTransport(base http.RoundTripper, limits map[string]int) *limitedTransport {
+	return &limitedTransport{
+		base:   base,
+		limits: limits,
+		slots:  make(map[string]chan struct{}),
+	}
+}
+
+// slot returns the semaphore for a host, or nil if the host is unlimited
+func (t *limitedTransport) slot(host string) chan struct{} {
+	limit, ok := t.limits[host]
+	if !ok || limit <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	slot, ok := t.slots[host]
+	if !ok {
+		slot = make(chan struct{}, limit)
+		t.slots[host] = slot
+	}
+	return slot
+}
+
+// RoundTrip waits for a free slot on the request's host and then sends the request
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	slot := t.slot(req.URL.Hostname())
+	if slot == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	select {
+	case slot <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-slot
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-slot }}
+	return resp, nil
+}
+
+// releasingBody frees a host slot once the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,77 @@
+package resolve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimitedTransport(t *testing.T) {
+	var active, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: newLimitedTransport(http.DefaultTransport, map[string]int{"127.0.0.1": 2}),
+	}
+
+	t.Run("limits concurrent requests", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					t.Errorf("Get() error = %v", err)
+					return
+				}
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+
+		if peak > 2 {
+			t.Errorf("peak concurrent requests = %d, want at most 2", peak)
+		}
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		// Hold both slots by leaving the bodies open
+		var bodies []*http.Response
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			bodies = append(bodies, resp)
+		}
+		defer func() {
+			for _, resp := range bodies {
+				resp.Body.Close()
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+		if _, err := client.Do(req); err == nil {
+			t.Error("Do() expected error while all slots are taken")
+		}
+	})
+}
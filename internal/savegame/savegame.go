@@ -0,0 +1,232 @@
+// Package savegame reads the header of Factorio save files.
+//
+// A save is a zip holding a directory named after the map. Its level.dat (or,
+// since Factorio 1.0, the zlib-compressed level.dat0) starts with a header: the
+// version of Factorio that wrote the save, the scenario it was started from, a
+// few map flags, and the mods that were active with their exact versions.
+package savegame
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+)
+
+// maxStringLength guards against allocating huge buffers for corrupt files
+const maxStringLength = 1 << 16
+
+// maxFlagsLength bounds the map flags between the scenario and the mod list
+const maxFlagsLength = 512
+
+// ErrInvalid is returned for data that is not a Factorio save
+var ErrInvalid = errors.New("invalid Factorio save")
+
+// Mod is a mod that was active when the map was saved
+type Mod struct {
+	Name    string
+	Version string
+
+	// CRC of the mod's files, which Factorio compares when joining a game
+	CRC uint32
+}
+
+// Header is the start of a save's level.dat
+type Header struct {
+	// Version of Factorio that wrote the save, as major.minor.patch
+	Version string
+
+	// Campaign and level the map was started from, and the mod defining it
+	Campaign string
+	Level    string
+	BaseMod  string
+
+	// Active mods in load order, built-in mods included
+	Mods []Mod
+}
+
+// Read reads the header of a save zip
+func Read(file string) (*Header, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening save: %w", err)
+	}
+	defer zr.Close()
+
+	var level *zip.File
+	for _, f := range zr.File {
+		switch path.Base(f.Name) {
+		case "level.dat0":
+			level = f
+		case "level.dat":
+			if level == nil {
+				level = f
+			}
+		}
+	}
+	if level == nil {
+		return nil, fmt.Errorf("%w: no level.dat in %s", ErrInvalid, file)
+	}
+
+	rc, err := level.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", level.Name, err)
+	}
+	defer rc.Close()
+
+	return Decode(rc)
+}
+
+// Decode reads a header from the start of a level.dat, which may be zlib
+// compressed
+func Decode(r io.Reader) (*Header, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x78 && (uint16(magic[0])<<8|uint16(magic[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	d := &decoder{r: br}
+	h, err := d.readHeader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return h, nil
+}
+
+// decoder reads the space-optimized encoding of level.dat
+type decoder struct {
+	r *bufio.Reader
+}
+
+func (d *decoder) readHeader() (*Header, error) {
+	var version [4]uint16
+	if err := binary.Read(d.r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("reading version: %v", err)
+	}
+	h := &Header{Version: fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2])}
+
+	// Unused flag written since Factorio 0.17
+	if version[0] > 0 || version[1] >= 17 {
+		if _, err := d.r.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if h.Campaign, err = d.readString(); err != nil {
+		return nil, fmt.Errorf("reading campaign: %v", err)
+	}
+	if h.Level, err = d.readString(); err != nil {
+		return nil, fmt.Errorf("reading level: %v", err)
+	}
+	if h.BaseMod, err = d.readString(); err != nil {
+		return nil, fmt.Errorf("reading base mod: %v", err)
+	}
+
+	if err := d.skipToMods(); err != nil {
+		return nil, err
+	}
+
+	count, err := d.readCount()
+	if err != nil {
+		return nil, fmt.Errorf("reading mod count: %v", err)
+	}
+	for i := uint32(0); i < count; i++ {
+		var mod Mod
+		if mod.Name, err = d.readString(); err != nil {
+			return nil, fmt.Errorf("reading mod name: %v", err)
+		}
+
+		var parts [3]uint16
+		for j := range parts {
+			if parts[j], err = d.readShort(); err != nil {
+				return nil, fmt.Errorf("reading version of %s: %v", mod.Name, err)
+			}
+		}
+		mod.Version = fmt.Sprintf("%d.%d.%d", parts[0], parts[1], parts[2])
+
+		if err := binary.Read(d.r, binary.LittleEndian, &mod.CRC); err != nil {
+			return nil, fmt.Errorf("reading CRC of %s: %v", mod.Name, err)
+		}
+		h.Mods = append(h.Mods, mod)
+	}
+	return h, nil
+}
+
+// skipToMods skips the map flags between the scenario and the mod list.
+// Factorio adds flags there from release to release, so rather than decoding
+// them the mod list is found by its first entry: base, which every map loads
+// before any other mod.
+func (d *decoder) skipToMods() error {
+	window, _ := d.r.Peek(maxFlagsLength)
+	base := []byte("\x04base")
+
+	for start := 0; ; {
+		i := bytes.Index(window[start:], base)
+		if i < 0 {
+			return errors.New("no mod list in header")
+		}
+		i += start
+
+		// The count before the entry takes one byte, or five from 255 mods on
+		switch {
+		case i >= 5 && window[i-5] == 0xFF && binary.LittleEndian.Uint32(window[i-4:i]) >= 0xFF:
+			_, err := d.r.Discard(i - 5)
+			return err
+		case i >= 1 && window[i-1] > 0 && window[i-1] < 0xFF:
+			_, err := d.r.Discard(i - 1)
+			return err
+		}
+		start = i + 1
+	}
+}
+
+// readCount reads a count that takes one byte below 255 and five bytes
+// otherwise
+func (d *decoder) readCount() (uint32, error) {
+	b, err := d.r.ReadByte()
+	if err != nil || b != 0xFF {
+		return uint32(b), err
+	}
+	var n uint32
+	err = binary.Read(d.r, binary.LittleEndian, &n)
+	return n, err
+}
+
+// readShort reads a number that takes one byte below 255 and three bytes
+// otherwise
+func (d *decoder) readShort() (uint16, error) {
+	b, err := d.r.ReadByte()
+	if err != nil || b != 0xFF {
+		return uint16(b), err
+	}
+	var n uint16
+	err = binary.Read(d.r, binary.LittleEndian, &n)
+	return n, err
+}
+
+// readString reads a string preceded by its length
+func (d *decoder) readString() (string, error) {
+	length, err := d.readCount()
+	if err != nil {
+		return "", err
+	}
+	if length > maxStringLength {
+		return "", fmt.Errorf("string of %d bytes is too long", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,110 @@
+package savegame
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testLevel returns the start of a level.dat written by Factorio 2.0.28
+func testLevel(mods []Mod) []byte {
+	var buf bytes.Buffer
+	str := func(s string) {
+		buf.WriteByte(byte(len(s)))
+		buf.WriteString(s)
+	}
+	short := func(n uint16) {
+		if n < 0xFF {
+			buf.WriteByte(byte(n))
+		} else {
+			buf.Write([]byte{0xFF, byte(n), byte(n >> 8)})
+		}
+	}
+
+	buf.Write([]byte{0x02, 0x00, 0x00, 0x00, 0x1c, 0x00, 0x00, 0x00}) // version
+	buf.WriteByte(0x00)                                               // unused flag
+	str("freeplay")
+	str("freeplay")
+	str("base")
+	buf.Write([]byte{0x01, 0x00, 0x00})       // difficulty, finished, player won
+	str("")                                   // next level
+	buf.Write([]byte{0x00, 0x00, 0x00, 0x00}) // continue and debug flags
+	buf.Write([]byte{0x02, 0x00, 0x1c})       // loaded from
+	buf.Write([]byte{0x4d, 0x13, 0x00})       // build and allowed commands
+
+	buf.WriteByte(byte(len(mods)))
+	for _, mod := range mods {
+		str(mod.Name)
+		var major, minor, patch uint16
+		fmt.Sscanf(mod.Version, "%d.%d.%d", &major, &minor, &patch)
+		short(major)
+		short(minor)
+		short(patch)
+		buf.Write([]byte{byte(mod.CRC), byte(mod.CRC >> 8), byte(mod.CRC >> 16), byte(mod.CRC >> 24)})
+	}
+
+	// Map data follows
+	buf.Write(bytes.Repeat([]byte{0x04, 'b', 'a', 's', 'e'}, 4))
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	want := &Header{
+		Version:  "2.0.28",
+		Campaign: "freeplay",
+		Level:    "freeplay",
+		BaseMod:  "base",
+		Mods: []Mod{
+			{Name: "base", Version: "2.0.300", CRC: 0x12345678},
+			{Name: "flib", Version: "0.16.2", CRC: 7},
+		},
+	}
+	level := testLevel(want.Mods)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(level)
+	zw.Close()
+
+	tests := []struct {
+		name  string
+		entry string
+		data  []byte
+	}{
+		{"compressed", "world/level.dat0", compressed.Bytes()},
+		{"uncompressed", "world/level.dat", level},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "world.zip")
+			f, err := os.Create(file)
+			if err != nil {
+				t.Fatalf("Failed to create save: %v", err)
+			}
+			zw := zip.NewWriter(f)
+			w, _ := zw.Create(tt.entry)
+			w.Write(tt.data)
+			zw.Close()
+			f.Close()
+
+			got, err := Read(file)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Read() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := Decode(bytes.NewReader(level[:20])); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Decode() of a truncated header error = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,141 @@
+package solver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DependencyKind describes how a dependency affects the mod set
+type DependencyKind int
+
+const (
+	// Required dependencies must be present ("name")
+	Required DependencyKind = iota
+	// Optional dependencies are used when present ("? name")
+	Optional
+	// HiddenOptional dependencies are optional and hidden in the game UI ("(?) name")
+	HiddenOptional
+	// Incompatible dependencies must not be present ("! name")
+	Incompatible
+	// RequiredNoLoadOrder dependencies must be present but don't affect load order ("~ name")
+	RequiredNoLoadOrder
+)
+
+// String returns the prefix used for the kind in info.json
+func (k DependencyKind) String() string {
+	switch k {
+	case Optional:
+		return "?"
+	case HiddenOptional:
+		return "(?)"
+	case Incompatible:
+		return "!"
+	case RequiredNoLoadOrder:
+		return "~"
+	default:
+		return ""
+	}
+}
+
+// Dependency is a parsed entry from the "dependencies" list of info.json
+type Dependency struct {
+	Kind       DependencyKind
+	Name       string
+	Constraint Constraint
+}
+
+// IsRequired reports whether the dependency must be installed
+func (d Dependency) IsRequired() bool {
+	return d.Kind == Required || d.Kind == RequiredNoLoadOrder
+}
+
+// IsOptional reports whether the dependency is optional (hidden or not)
+func (d Dependency) IsOptional() bool {
+	return d.Kind == Optional || d.Kind == HiddenOptional
+}
+
+// String returns the dependency in info.json format
+func (d Dependency) String() string {
+	var b strings.Builder
+	if d.Kind != Required {
+		b.WriteString(d.Kind.String())
+		b.WriteString(" ")
+	}
+	b.WriteString(d.Name)
+	if d.Constraint.Op != OpAny {
+		b.WriteString(" ")
+		b.WriteString(d.Constraint.String())
+	}
+	return b.String()
+}
+
+// dependencyPattern matches "<name> <op> <version>" after the prefix is removed.
+// Mod names may contain spaces, so the name is everything before the operator.
+var dependencyPattern = regexp.MustCompile(`^(.+?)\s*(<=|>=|<|>|=)\s*([0-9]+(?:\.[0-9]+){1,2})$`)
+
+// ParseDependency parses a dependency string using the full info.json grammar:
+//
+//	[prefix] name [op version]
+//
+// where prefix is one of "!", "?", "(?)" or "~" and op is one of <, <=, =, >=, >.
+func ParseDependency(s string) (Dependency, error) {
+	rest := strings.TrimSpace(s)
+	if rest == "" {
+		return Dependency{}, fmt.Errorf("empty dependency")
+	}
+
+	dep := Dependency{Kind: Required}
+	switch {
+	case strings.HasPrefix(rest, "(?)"):
+		dep.Kind = HiddenOptional
+		rest = rest[3:]
+	case strings.HasPrefix(rest, "?"):
+		dep.Kind = Optional
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "!"):
+		dep.Kind = Incompatible
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "~"):
+		dep.Kind = RequiredNoLoadOrder
+		rest = rest[1:]
+	}
+	rest = strings.TrimSpace(rest)
+
+	if m := dependencyPattern.FindStringSubmatch(rest); m != nil {
+		version, err := ParseVersion(m[3])
+		if err != nil {
+			return Dependency{}, fmt.Errorf("parsing dependency %q: %w", s, err)
+		}
+		dep.Name = strings.TrimSpace(m[1])
+		dep.Constraint = Constraint{Op: Op(m[2]), Version: version}
+	} else {
+		if strings.ContainsAny(rest, "<>=") {
+			return Dependency{}, fmt.Errorf("parsing dependency %q: malformed version constraint", s)
+		}
+		dep.Name = rest
+	}
+
+	if dep.Name == "" {
+		return Dependency{}, fmt.Errorf("parsing dependency %q: missing mod name", s)
+	}
+
+	if dep.Kind == Incompatible && dep.Constraint.Op != OpAny {
+		return Dependency{}, fmt.Errorf("parsing dependency %q: incompatibilities cannot have a version", s)
+	}
+
+	return dep, nil
+}
+
+// ParseDependencies parses every entry of an info.json dependency list
+func ParseDependencies(list []string) ([]Dependency, error) {
+	deps := make([]Dependency, 0, len(list))
+	for _, s := range list {
+		dep, err := ParseDependency(s)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
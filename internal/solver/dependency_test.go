@@ -0,0 +1,75 @@
+package solver
+
+import "testing"
+
+func TestParseDependency(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Dependency
+		wantErr bool
+	}{
+		{
+			input: "base >= 1.1",
+			want:  Dependency{Kind: Required, Name: "base", Constraint: Constraint{Op: OpGreaterEqual, Version: MustParseVersion("1.1")}},
+		},
+		{
+			input: "flib",
+			want:  Dependency{Kind: Required, Name: "flib"},
+		},
+		{
+			input: "? bobplates < 2.0.0",
+			want:  Dependency{Kind: Optional, Name: "bobplates", Constraint: Constraint{Op: OpLess, Version: MustParseVersion("2.0.0")}},
+		},
+		{
+			input: "(?)angelsrefining",
+			want:  Dependency{Kind: HiddenOptional, Name: "angelsrefining"},
+		},
+		{
+			input: "! space-exploration",
+			want:  Dependency{Kind: Incompatible, Name: "space-exploration"},
+		},
+		{
+			input: "~ mod with spaces = 0.3.1",
+			want:  Dependency{Kind: RequiredNoLoadOrder, Name: "mod with spaces", Constraint: Constraint{Op: OpEqual, Version: MustParseVersion("0.3.1")}},
+		},
+		{
+			input: "a>=1.0.0",
+			want:  Dependency{Kind: Required, Name: "a", Constraint: Constraint{Op: OpGreaterEqual, Version: MustParseVersion("1.0.0")}},
+		},
+		{input: "", wantErr: true},
+		{input: "? ", wantErr: true},
+		{input: "mod >= banana", wantErr: true},
+		{input: "! mod >= 1.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDependency(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseDependency() = %+v, expected error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ParseDependency() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseDependency() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencyString(t *testing.T) {
+	for _, input := range []string{"base >= 1.1", "? bobplates < 2.0.0", "(?) angels", "! se", "~ flib = 0.3.1", "flib"} {
+		dep, err := ParseDependency(input)
+		if err != nil {
+			t.Fatalf("ParseDependency(%q) error = %v", input, err)
+		}
+		if got := dep.String(); got != input {
+			t.Errorf("String() = %q, want %q", got, input)
+		}
+	}
+}
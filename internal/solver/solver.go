@@ -0,0 +1,418 @@
+// Package solver resolves a consistent set of Factorio mods from the
+// dependency declarations in each mod's info.json.
+package solver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSteps bounds the backtracking search so pathological inputs fail instead of hanging
+const maxSteps = 100000
+
+var (
+	// ErrTooComplex is returned when the search exceeds maxSteps
+	ErrTooComplex = errors.New("dependency resolution did not finish: too many combinations to try")
+)
+
+// Candidate is one installable version of a mod
+type Candidate struct {
+	Name    string
+	Version Version
+
+	// Factorio version the release targets (e.g. "1.1"), empty if unknown
+	FactorioVersion string
+
+	// Parsed dependencies from info.json
+	Dependencies []Dependency
+
+	// Source identifies where the candidate comes from; it is opaque to the solver
+	Source string
+
+	// Builtin is set for mods shipped with the game (base, space-age, ...)
+	Builtin bool
+}
+
+// String returns "name version" for display
+func (c *Candidate) String() string {
+	return fmt.Sprintf("%s %s", c.Name, c.Version)
+}
+
+// Registry provides the available versions of mods
+type Registry interface {
+	// Candidates returns the available versions of a mod in order of preference.
+	// An empty result means the mod is unknown.
+	Candidates(name string) ([]*Candidate, error)
+}
+
+// Problem describes a set of mods to resolve
+type Problem struct {
+	// Mods that must be part of the solution
+	Roots []string
+
+	// Factorio version of the instance (e.g. "1.1" or "2.0.28")
+	FactorioVersion string
+
+	// Mods shipped with the game; they are never looked up in the registry
+	Builtin []string
+
+	// Mods included when they can be resolved alongside the roots and
+	// skipped otherwise
+	Wanted []string
+
+	// Optional reports whether the optional dependencies of a mod should be
+	// included. They are skipped when they can't be resolved. If nil, no
+	// optional dependencies are included.
+	Optional func(name string) bool
+
+	// Where candidates come from
+	Registry Registry
+}
+
+// Solution is a consistent set of mods
+type Solution struct {
+	// Selected candidate for every mod in the solution, including builtin mods
+	Mods map[string]*Candidate
+
+	// Wanted mods and optional dependencies that were left out, with the reason
+	Skipped map[string]*ConflictError
+}
+
+// Names returns the names of all non-builtin mods in the solution, sorted
+func (s *Solution) Names() []string {
+	var names []string
+	for name, c := range s.Mods {
+		if !c.Builtin {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConflictError explains why no acceptable version of a mod could be chosen
+type ConflictError struct {
+	// Mod that could not be resolved
+	Mod string
+
+	// Requirements placed on the mod, e.g. "A needs B >= 2.0" or "C forbids B"
+	Requirements []string
+
+	// Why each available candidate was rejected
+	Rejected []string
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cannot resolve %s", e.Mod)
+	if len(e.Requirements) > 0 {
+		fmt.Fprintf(&b, ": %s", strings.Join(e.Requirements, ", "))
+	}
+	if len(e.Rejected) == 0 {
+		fmt.Fprintf(&b, "\n  - no versions of %s were found in any source", e.Mod)
+	}
+	for _, r := range e.Rejected {
+		fmt.Fprintf(&b, "\n  - %s", r)
+	}
+	return b.String()
+}
+
+// requirement is a dependency placed on a mod by another mod (or by the config)
+type requirement struct {
+	from string // empty for roots
+	dep  Dependency
+}
+
+// describe renders the requirement for conflict messages
+func (r requirement) describe() string {
+	if r.from == "" {
+		if r.dep.IsOptional() {
+			return fmt.Sprintf("the config wants %s", r.dep.Name)
+		}
+		return fmt.Sprintf("the config enables %s", r.dep.Name)
+	}
+	switch {
+	case r.dep.Kind == Incompatible:
+		return fmt.Sprintf("%s forbids %s", r.from, r.dep.Name)
+	case r.dep.IsOptional():
+		return fmt.Sprintf("%s optionally needs %s", r.from, strings.TrimSpace(r.dep.Name+" "+r.dep.Constraint.String()))
+	default:
+		return fmt.Sprintf("%s needs %s", r.from, strings.TrimSpace(r.dep.Name+" "+r.dep.Constraint.String()))
+	}
+}
+
+// state holds the search state while solving
+type state struct {
+	problem  *Problem
+	game     Version
+	builtin  map[string]bool
+	selected map[string]*Candidate
+	reqs     map[string][]requirement
+	cache    map[string][]*Candidate
+	skipped  map[string]*ConflictError
+	steps    int
+	conflict *ConflictError
+}
+
+// Solve finds a set of mods that satisfies every dependency, version constraint,
+// incompatibility and Factorio version requirement reachable from the roots.
+// Candidates are tried in the order the registry returns them.
+func Solve(p *Problem) (*Solution, error) {
+	game, err := ParseVersion(p.FactorioVersion)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Factorio version: %w", err)
+	}
+
+	s := &state{
+		problem:  p,
+		game:     game,
+		builtin:  make(map[string]bool),
+		selected: make(map[string]*Candidate),
+		reqs:     make(map[string][]requirement),
+		cache:    make(map[string][]*Candidate),
+		skipped:  make(map[string]*ConflictError),
+	}
+	for _, name := range p.Builtin {
+		s.builtin[name] = true
+	}
+
+	// Roots are required by the config itself
+	var pending []string
+	for _, root := range p.Roots {
+		s.reqs[root] = append(s.reqs[root], requirement{dep: Dependency{Kind: Required, Name: root}})
+		pending = append(pending, root)
+	}
+
+	// Wanted mods follow the roots so they never displace one
+	for _, name := range p.Wanted {
+		s.reqs[name] = append(s.reqs[name], requirement{dep: Dependency{Kind: Optional, Name: name}})
+		pending = append(pending, name)
+	}
+
+	if err := s.solve(pending); err != nil {
+		var conflict *ConflictError
+		if errors.As(err, &conflict) && s.conflict != nil {
+			return nil, s.conflict
+		}
+		return nil, err
+	}
+
+	mods := make(map[string]*Candidate, len(s.selected))
+	for name, c := range s.selected {
+		mods[name] = c
+	}
+	skipped := make(map[string]*ConflictError)
+	for name, conflict := range s.skipped {
+		if mods[name] == nil {
+			skipped[name] = conflict
+		}
+	}
+	return &Solution{Mods: mods, Skipped: skipped}, nil
+}
+
+// solve selects a candidate for the first unresolved mod in pending and recurses
+func (s *state) solve(pending []string) error {
+	for len(pending) > 0 && s.selected[pending[0]] != nil {
+		pending = pending[1:]
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	s.steps++
+	if s.steps > maxSteps {
+		return ErrTooComplex
+	}
+
+	name := pending[0]
+	candidates, err := s.candidates(name)
+	if err != nil {
+		return fmt.Errorf("listing versions of %s: %w", name, err)
+	}
+
+	// A dead end in a mod that can be skipped is not worth reporting
+	firstConflict := s.conflict
+
+	var rejected []string
+	for _, c := range candidates {
+		if reason := s.reject(c); reason != "" {
+			rejected = append(rejected, fmt.Sprintf("%s%s: %s", c, describeSource(c), reason))
+			continue
+		}
+
+		added := s.apply(c)
+
+		// Queue dependencies after everything already pending
+		next := make([]string, 0, len(pending)+len(c.Dependencies))
+		next = append(next, pending[1:]...)
+		for _, dep := range c.Dependencies {
+			if s.selected[dep.Name] != nil {
+				continue
+			}
+			if dep.IsRequired() || (dep.IsOptional() && s.wantsOptional(c.Name)) {
+				next = append(next, dep.Name)
+			}
+		}
+
+		err := s.solve(next)
+		if err == nil {
+			return nil
+		}
+
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) {
+			return err
+		}
+
+		s.undo(c, added)
+		rejected = append(rejected, fmt.Sprintf("%s%s: leads to a conflict with %s", c, describeSource(c), conflict.Mod))
+	}
+
+	conflict := &ConflictError{
+		Mod:          name,
+		Requirements: s.describeRequirements(name),
+		Rejected:     rejected,
+	}
+
+	// Mods nothing requires are left out instead
+	if !s.isRequired(name) {
+		s.conflict = firstConflict
+		s.skipped[name] = conflict
+		err := s.solve(pending[1:])
+		if err != nil {
+			delete(s.skipped, name)
+		}
+		return err
+	}
+
+	// Keep the first dead end; it is usually the most specific explanation
+	if s.conflict == nil {
+		s.conflict = conflict
+	}
+	return conflict
+}
+
+// wantsOptional reports whether the optional dependencies of a mod are included
+func (s *state) wantsOptional(name string) bool {
+	return s.problem.Optional != nil && s.problem.Optional(name)
+}
+
+// isRequired reports whether anything currently requires a mod
+func (s *state) isRequired(name string) bool {
+	for _, req := range s.reqs[name] {
+		if req.dep.IsRequired() {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the available versions of a mod, caching registry lookups
+func (s *state) candidates(name string) ([]*Candidate, error) {
+	if s.builtin[name] {
+		return []*Candidate{{Name: name, Version: s.game, Builtin: true}}, nil
+	}
+
+	if cached, ok := s.cache[name]; ok {
+		return cached, nil
+	}
+
+	candidates, err := s.problem.Registry.Candidates(name)
+	if err != nil {
+		return nil, err
+	}
+	s.cache[name] = candidates
+	return candidates, nil
+}
+
+// reject returns why a candidate cannot be selected, or "" if it can
+func (s *state) reject(c *Candidate) string {
+	// The release must target the instance's Factorio version
+	if c.FactorioVersion != "" {
+		target, err := ParseVersion(c.FactorioVersion)
+		if err != nil {
+			return fmt.Sprintf("invalid factorio_version %q", c.FactorioVersion)
+		}
+		if !target.SameRelease(s.game) {
+			return fmt.Sprintf("targets Factorio %s but the instance uses %s", c.FactorioVersion, s.problem.FactorioVersion)
+		}
+	}
+
+	// Everything already required of this mod must hold
+	for _, req := range s.reqs[c.Name] {
+		if req.dep.Kind == Incompatible {
+			return req.describe()
+		}
+		if !req.dep.Constraint.Allows(c.Version) {
+			return req.describe()
+		}
+	}
+
+	// Its own dependencies must agree with what is already chosen or required
+	for _, dep := range c.Dependencies {
+		if dep.Kind == Incompatible {
+			if s.selected[dep.Name] != nil {
+				return fmt.Sprintf("forbids %s, which is already selected", dep.Name)
+			}
+			for _, req := range s.reqs[dep.Name] {
+				if req.dep.IsRequired() {
+					return fmt.Sprintf("%s, %s forbids %s", req.describe(), c.Name, dep.Name)
+				}
+			}
+			continue
+		}
+
+		if selected := s.selected[dep.Name]; selected != nil && !dep.Constraint.Allows(selected.Version) {
+			return fmt.Sprintf("needs %s but %s is selected", dep, selected)
+		}
+	}
+
+	return ""
+}
+
+// apply selects a candidate and records the requirements it places on other mods.
+// It returns the names that received new requirements so undo can remove them.
+func (s *state) apply(c *Candidate) []string {
+	s.selected[c.Name] = c
+
+	var added []string
+	for _, dep := range c.Dependencies {
+		s.reqs[dep.Name] = append(s.reqs[dep.Name], requirement{from: c.Name, dep: dep})
+		added = append(added, dep.Name)
+	}
+	return added
+}
+
+// undo reverses apply
+func (s *state) undo(c *Candidate, added []string) {
+	delete(s.selected, c.Name)
+
+	// Requirements are appended in order, so remove them from the end
+	for i := len(added) - 1; i >= 0; i-- {
+		name := added[i]
+		reqs := s.reqs[name]
+		s.reqs[name] = reqs[:len(reqs)-1]
+		if len(s.reqs[name]) == 0 {
+			delete(s.reqs, name)
+		}
+	}
+}
+
+// describeRequirements lists every requirement placed on a mod
+func (s *state) describeRequirements(name string) []string {
+	var out []string
+	for _, req := range s.reqs[name] {
+		out = append(out, req.describe())
+	}
+	return out
+}
+
+// describeSource renders the candidate's source for conflict messages
+func describeSource(c *Candidate) string {
+	if c.Source == "" {
+		return ""
+	}
+	return fmt.Sprintf(" from %s", c.Source)
+}
@@ -0,0 +1,248 @@
+package solver
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mapRegistry implements Registry from a static map
+type mapRegistry map[string][]*Candidate
+
+func (r mapRegistry) Candidates(name string) ([]*Candidate, error) {
+	return r[name], nil
+}
+
+// candidate builds a test candidate
+func candidate(name, version, factorio string, deps ...string) *Candidate {
+	parsed, err := ParseDependencies(deps)
+	if err != nil {
+		panic(err)
+	}
+	return &Candidate{
+		Name:            name,
+		Version:         MustParseVersion(version),
+		FactorioVersion: factorio,
+		Dependencies:    parsed,
+		Source:          "test",
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		roots    []string
+		factorio string
+		registry mapRegistry
+		wanted   []string
+		optional []string // mods whose optional dependencies are included
+		want     map[string]string
+		skipped  []string
+		wantErr  []string
+	}{
+		{
+			name:     "transitive dependencies",
+			roots:    []string{"a"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "1.1", "base >= 1.1", "b >= 1.0.0")},
+				"b": {candidate("b", "1.2.0", "1.1", "~ c")},
+				"c": {candidate("c", "0.1.0", "1.1")},
+			},
+			want: map[string]string{"a": "1.0.0", "b": "1.2.0", "c": "0.1.0"},
+		},
+		{
+			name:     "optional dependencies are not installed",
+			roots:    []string{"a"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "1.1", "? b", "(?) c")},
+			},
+			want: map[string]string{"a": "1.0.0"},
+		},
+		{
+			name:     "optional dependencies of chosen mods",
+			roots:    []string{"pack"},
+			factorio: "1.1",
+			optional: []string{"pack"},
+			registry: mapRegistry{
+				"pack":       {candidate("pack", "1.0.0", "1.1", "? extra", "(?) hidden", "? missing")},
+				"extra":      {candidate("extra", "1.0.0", "1.1", "lib", "? not-wanted")},
+				"hidden":     {candidate("hidden", "1.0.0", "1.1")},
+				"lib":        {candidate("lib", "1.0.0", "1.1")},
+				"not-wanted": {candidate("not-wanted", "1.0.0", "1.1")},
+			},
+			want:    map[string]string{"pack": "1.0.0", "extra": "1.0.0", "hidden": "1.0.0", "lib": "1.0.0"},
+			skipped: []string{"missing"},
+		},
+		{
+			name:     "optional dependencies that conflict are skipped",
+			roots:    []string{"pack", "other"},
+			factorio: "1.1",
+			optional: []string{"pack"},
+			registry: mapRegistry{
+				"pack":  {candidate("pack", "1.0.0", "1.1", "? extra")},
+				"other": {candidate("other", "1.0.0", "1.1", "! extra")},
+				"extra": {candidate("extra", "1.0.0", "1.1")},
+			},
+			want:    map[string]string{"pack": "1.0.0", "other": "1.0.0"},
+			skipped: []string{"extra"},
+		},
+		{
+			name:     "wanted mods are skipped when they conflict",
+			roots:    []string{"a"},
+			wanted:   []string{"b", "c"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "1.1", "lib >= 2.0.0")},
+				"b": {candidate("b", "1.0.0", "1.1", "lib < 2.0.0")},
+				"c": {candidate("c", "1.0.0", "1.1", "lib")},
+				"lib": {
+					candidate("lib", "2.0.0", "1.1"),
+					candidate("lib", "1.0.0", "1.1"),
+				},
+			},
+			want:    map[string]string{"a": "1.0.0", "c": "1.0.0", "lib": "2.0.0"},
+			skipped: []string{"b"},
+		},
+		{
+			name:     "falls back to an older version that satisfies constraints",
+			roots:    []string{"a", "b"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "1.1", "lib < 2.0.0")},
+				"b": {candidate("b", "1.0.0", "1.1", "lib >= 1.5.0")},
+				"lib": {
+					candidate("lib", "2.1.0", "1.1"),
+					candidate("lib", "1.6.0", "1.1"),
+					candidate("lib", "1.4.0", "1.1"),
+				},
+			},
+			want: map[string]string{"a": "1.0.0", "b": "1.0.0", "lib": "1.6.0"},
+		},
+		{
+			name:     "skips releases for other Factorio versions",
+			roots:    []string{"a"},
+			factorio: "2.0",
+			registry: mapRegistry{
+				"a": {
+					candidate("a", "1.1.0", "1.1"),
+					candidate("a", "2.0.0", "2.0"),
+				},
+			},
+			want: map[string]string{"a": "2.0.0"},
+		},
+		{
+			name:     "backtracks out of an incompatibility",
+			roots:    []string{"a", "b"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {
+					candidate("a", "2.0.0", "1.1", "! b"),
+					candidate("a", "1.0.0", "1.1"),
+				},
+				"b": {candidate("b", "1.0.0", "1.1")},
+			},
+			want: map[string]string{"a": "1.0.0", "b": "1.0.0"},
+		},
+		{
+			name:     "optional constraint applies when the mod is present",
+			roots:    []string{"a", "b"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "1.1", "? b >= 2.0.0")},
+				"b": {
+					candidate("b", "1.0.0", "1.1"),
+					candidate("b", "2.0.0", "1.1"),
+				},
+			},
+			want: map[string]string{"a": "1.0.0", "b": "2.0.0"},
+		},
+		{
+			name:     "version conflict",
+			roots:    []string{"a", "c"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "1.1", "b >= 2.0")},
+				"c": {candidate("c", "1.0.0", "1.1", "! b")},
+				"b": {candidate("b", "2.0.0", "1.1")},
+			},
+			wantErr: []string{"a needs b >= 2.0", "c forbids b"},
+		},
+		{
+			name:     "missing mod",
+			roots:    []string{"a"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "1.1", "missing")},
+			},
+			wantErr: []string{"cannot resolve missing", "a needs missing", "no versions of missing"},
+		},
+		{
+			name:     "base version is checked",
+			roots:    []string{"a"},
+			factorio: "1.1",
+			registry: mapRegistry{
+				"a": {candidate("a", "1.0.0", "", "base >= 2.0")},
+			},
+			wantErr: []string{"cannot resolve", "base"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution, err := Solve(&Problem{
+				Roots:           tt.roots,
+				FactorioVersion: tt.factorio,
+				Builtin:         []string{"base"},
+				Wanted:          tt.wanted,
+				Optional: func(name string) bool {
+					return slices.Contains(tt.optional, name)
+				},
+				Registry: tt.registry,
+			})
+
+			if tt.wantErr != nil {
+				if err == nil {
+					t.Fatalf("Solve() = %v, expected error", solution.Names())
+				}
+				var conflict *ConflictError
+				if !errors.As(err, &conflict) {
+					t.Errorf("Solve() error type = %T, want *ConflictError", err)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Solve() error = %q, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Solve() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, name := range solution.Names() {
+				got[name] = solution.Mods[name].Version.String()
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Solve() = %v, want %v", got, tt.want)
+			}
+			for name, version := range tt.want {
+				if got[name] != version {
+					t.Errorf("Solve() %s = %q, want %q", name, got[name], version)
+				}
+			}
+			if len(solution.Skipped) != len(tt.skipped) {
+				t.Errorf("Solve() skipped %v, want %v", solution.Skipped, tt.skipped)
+			}
+			for _, name := range tt.skipped {
+				if solution.Skipped[name] == nil {
+					t.Errorf("Solve() did not skip %s", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package solver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a Factorio mod or game version (major.minor.patch)
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+
+	// Partial is set when the patch component was omitted (e.g. "1.1").
+	// Factorio treats such versions as "any patch release".
+	Partial bool
+}
+
+// ParseVersion parses a Factorio version string such as "1.1.87" or "2.0"
+func ParseVersion(s string) (Version, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q: expected major.minor[.patch]", s)
+	}
+
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 65535 {
+			return Version{}, fmt.Errorf("invalid version %q: bad component %q", s, part)
+		}
+		nums[i] = n
+	}
+
+	return Version{
+		Major:   nums[0],
+		Minor:   nums[1],
+		Patch:   nums[2],
+		Partial: len(parts) == 2,
+	}, nil
+}
+
+// MustParseVersion parses a version and panics on error (for tests and constants)
+func MustParseVersion(s string) Version {
+	v, err := ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower, equal or higher than o.
+// The Partial flag is ignored.
+func (v Version) Compare(o Version) int {
+	switch {
+	case v.Major != o.Major:
+		return cmpInt(v.Major, o.Major)
+	case v.Minor != o.Minor:
+		return cmpInt(v.Minor, o.Minor)
+	default:
+		return cmpInt(v.Patch, o.Patch)
+	}
+}
+
+// SameRelease reports whether v and o share the same major.minor release line
+func (v Version) SameRelease(o Version) bool {
+	return v.Major == o.Major && v.Minor == o.Minor
+}
+
+// String returns the version in Factorio's format
+func (v Version) String() string {
+	if v.Partial {
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func cmpInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Op is a version comparison operator used in dependency strings
+type Op string
+
+const (
+	OpAny          Op = ""
+	OpLess         Op = "<"
+	OpLessEqual    Op = "<="
+	OpEqual        Op = "="
+	OpGreaterEqual Op = ">="
+	OpGreater      Op = ">"
+)
+
+// Constraint restricts the acceptable versions of a mod
+type Constraint struct {
+	Op      Op
+	Version Version
+}
+
+// Allows reports whether v satisfies the constraint. If v is partial (such as
+// the game version "2.0") the constraint is satisfied when any patch release of
+// that line would satisfy it.
+func (c Constraint) Allows(v Version) bool {
+	if c.Op == OpAny {
+		return true
+	}
+
+	if v.Partial {
+		// Compare against the lowest and highest patch release of the line
+		lowest := Version{Major: v.Major, Minor: v.Minor}
+		highest := Version{Major: v.Major, Minor: v.Minor, Patch: 65535}
+		switch c.Op {
+		case OpLess, OpLessEqual:
+			return c.allowsExact(lowest)
+		case OpGreater, OpGreaterEqual:
+			return c.allowsExact(highest)
+		case OpEqual:
+			return v.SameRelease(c.Version)
+		}
+	}
+
+	return c.allowsExact(v)
+}
+
+func (c Constraint) allowsExact(v Version) bool {
+	cmp := v.Compare(c.Version)
+	switch c.Op {
+	case OpLess:
+		return cmp < 0
+	case OpLessEqual:
+		return cmp <= 0
+	case OpEqual:
+		return cmp == 0
+	case OpGreaterEqual:
+		return cmp >= 0
+	case OpGreater:
+		return cmp > 0
+	default:
+		return true
+	}
+}
+
+// String returns the constraint in Factorio's dependency format (e.g. ">= 1.2.0")
+func (c Constraint) String() string {
+	if c.Op == OpAny {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", c.Op, c.Version)
+}
+
+// Range is a set of constraints that must all hold, such as ">=1.2.0 <2.0.0".
+// An empty range allows every version.
+type Range []Constraint
+
+// ParseRange parses a version range. Constraints are separated by spaces or
+// commas; a bare version is an exact pin, and "latest", "*" or an empty string
+// allow any version. "^1.2" allows 1.2.0 up to (excluding) 2.0.0 and "~1.2.3"
+// allows 1.2.3 up to (excluding) 1.3.0; for 0.x versions "^" behaves like "~".
+func ParseRange(s string) (Range, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "latest" || s == "*" {
+		return nil, nil
+	}
+
+	fields := strings.FieldsFunc(s, func(c rune) bool { return c == ' ' || c == ',' })
+
+	var r Range
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		// Caret and tilde ranges expand to a lower and upper bound
+		if strings.HasPrefix(field, "^") || strings.HasPrefix(field, "~") {
+			version, err := ParseVersion(field[1:])
+			if err != nil {
+				return nil, fmt.Errorf("parsing range %q: %w", s, err)
+			}
+			version.Partial = false
+			upper := Version{Major: version.Major, Minor: version.Minor + 1}
+			if field[0] == '^' && version.Major > 0 {
+				upper = Version{Major: version.Major + 1}
+			}
+			r = append(r, Constraint{Op: OpGreaterEqual, Version: version}, Constraint{Op: OpLess, Version: upper})
+			continue
+		}
+
+		// Allow whitespace between the operator and the version (">= 1.2.0")
+		if strings.Trim(field, "<>=") == "" && i+1 < len(fields) {
+			i++
+			field += fields[i]
+		}
+
+		op := OpEqual
+		for _, candidate := range []Op{OpLessEqual, OpGreaterEqual, OpLess, OpGreater, OpEqual} {
+			if strings.HasPrefix(field, string(candidate)) {
+				op = candidate
+				field = strings.TrimPrefix(field, string(candidate))
+				break
+			}
+		}
+
+		version, err := ParseVersion(field)
+		if err != nil {
+			return nil, fmt.Errorf("parsing range %q: %w", s, err)
+		}
+		r = append(r, Constraint{Op: op, Version: version})
+	}
+	return r, nil
+}
+
+// Allows reports whether v satisfies every constraint in the range
+func (r Range) Allows(v Version) bool {
+	for _, c := range r {
+		if !c.Allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the range in the format accepted by ParseRange
+func (r Range) String() string {
+	if len(r) == 0 {
+		return "latest"
+	}
+	parts := make([]string, len(r))
+	for i, c := range r {
+		parts[i] = string(c.Op) + c.Version.String()
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,114 @@
+package solver
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "1.1.87", want: Version{Major: 1, Minor: 1, Patch: 87}},
+		{input: "2.0", want: Version{Major: 2, Minor: 0, Partial: true}},
+		{input: "v0.18.3", want: Version{Major: 0, Minor: 18, Patch: 3}},
+		{input: "1", wantErr: true},
+		{input: "1.2.3.4", wantErr: true},
+		{input: "1.x.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseVersion(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("ParseVersion() expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ParseVersion() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraintAllows(t *testing.T) {
+	tests := []struct {
+		op      Op
+		version string
+		check   string
+		want    bool
+	}{
+		{OpAny, "1.0.0", "0.0.1", true},
+		{OpGreaterEqual, "1.2.0", "1.2.0", true},
+		{OpGreaterEqual, "1.2.0", "1.1.9", false},
+		{OpGreater, "1.2.0", "1.2.0", false},
+		{OpLess, "2.0.0", "1.99.99", true},
+		{OpLessEqual, "2.0.0", "2.0.1", false},
+		{OpEqual, "1.0.3", "1.0.3", true},
+		{OpEqual, "1.0.3", "1.0.4", false},
+		// Partial game versions match if any patch release would
+		{OpGreaterEqual, "2.0.7", "2.0", true},
+		{OpLess, "2.0.7", "2.0", true},
+		{OpGreaterEqual, "2.1.0", "2.0", false},
+		{OpEqual, "1.1.0", "1.1", true},
+	}
+
+	for _, tt := range tests {
+		c := Constraint{Op: tt.op, Version: MustParseVersion(tt.version)}
+		if got := c.Allows(MustParseVersion(tt.check)); got != tt.want {
+			t.Errorf("Constraint{%s %s}.Allows(%s) = %v, want %v", tt.op, tt.version, tt.check, got, tt.want)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		input   string
+		allowed []string
+		denied  []string
+		wantErr bool
+	}{
+		{input: "latest", allowed: []string{"0.0.1", "9.9.9"}},
+		{input: "", allowed: []string{"1.0.0"}},
+		{input: "1.2.3", allowed: []string{"1.2.3"}, denied: []string{"1.2.4"}},
+		{input: "=1.2.3", allowed: []string{"1.2.3"}, denied: []string{"1.2.2"}},
+		{input: ">=1.2.0 <2.0.0", allowed: []string{"1.2.0", "1.9.9"}, denied: []string{"1.1.9", "2.0.0"}},
+		{input: ">= 1.2.0, < 2.0.0", allowed: []string{"1.5.0"}, denied: []string{"2.1.0"}},
+		{input: ">1.0", allowed: []string{"1.0.1"}, denied: []string{"1.0.0"}},
+		{input: "^1.2", allowed: []string{"1.2.0", "1.9.0"}, denied: []string{"1.1.9", "2.0.0"}},
+		{input: "^0.12", allowed: []string{"0.12.4"}, denied: []string{"0.13.0"}},
+		{input: "~1.2.3", allowed: []string{"1.2.9"}, denied: []string{"1.2.2", "1.3.0"}},
+		{input: ">=x", wantErr: true},
+		{input: "^", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			r, err := ParseRange(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("ParseRange() expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRange() error = %v", err)
+			}
+			for _, v := range tt.allowed {
+				if !r.Allows(MustParseVersion(v)) {
+					t.Errorf("ParseRange(%q).Allows(%s) = false, want true", tt.input, v)
+				}
+			}
+			for _, v := range tt.denied {
+				if r.Allows(MustParseVersion(v)) {
+					t.Errorf("ParseRange(%q).Allows(%s) = true, want false", tt.input, v)
+				}
+			}
+		})
+	}
+}